@@ -0,0 +1,208 @@
+package m3u8
+
+/*
+ This file defines typed accessors for attribute values of EXT-X-STREAM-INF,
+ EXT-X-I-FRAME-STREAM-INF and EXT-X-MEDIA that are stored as strings.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAttribute = errors.New("invalid attribute value")
+
+// Video layout specifiers of REQ-VIDEO-LAYOUT.
+const (
+	VideoChannelsStereo = "CH-STEREO" // Stereoscopic video
+	VideoChannelsMono   = "CH-MONO"   // Monoscopic video
+)
+
+// GetResolution returns the width and height of RESOLUTION, which are zero if it is not set.
+func (vp *VariantParams) GetResolution() (width, height int, err error) {
+	if vp.Resolution == "" {
+		return 0, 0, nil
+	}
+	width, height, ok := parseResolution(vp.Resolution)
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: RESOLUTION=%s", ErrInvalidAttribute, vp.Resolution)
+	}
+	return width, height, nil
+}
+
+// SetResolution sets RESOLUTION to WxH, or removes it if both are zero.
+// Other dimensions must be positive.
+func (vp *VariantParams) SetResolution(width, height int) error {
+	if width == 0 && height == 0 {
+		vp.Resolution = ""
+		return nil
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("%w: RESOLUTION=%dx%d", ErrInvalidAttribute, width, height)
+	}
+	vp.Resolution = fmt.Sprintf("%dx%d", width, height)
+	return nil
+}
+
+// GetVideoRange returns VIDEO-RANGE, which may be empty or invalid when decoded in non-strict mode.
+func (vp *VariantParams) GetVideoRange() VideoRange {
+	return VideoRange(vp.VideoRange)
+}
+
+// SetVideoRange sets VIDEO-RANGE, or removes it if r is empty.
+func (vp *VariantParams) SetVideoRange(r VideoRange) error {
+	if r != "" && !r.Valid() {
+		return fmt.Errorf("%w: VIDEO-RANGE=%s", ErrInvalidAttribute, r)
+	}
+	vp.VideoRange = string(r)
+	return nil
+}
+
+// GetHDCPLevel returns HDCP-LEVEL, which may be empty or invalid when decoded in non-strict mode.
+func (vp *VariantParams) GetHDCPLevel() HDCPLevel {
+	return HDCPLevel(vp.HDCPLevel)
+}
+
+// SetHDCPLevel sets HDCP-LEVEL, or removes it if l is empty.
+func (vp *VariantParams) SetHDCPLevel(l HDCPLevel) error {
+	if l != "" && !l.Valid() {
+		return fmt.Errorf("%w: HDCP-LEVEL=%s", ErrInvalidAttribute, l)
+	}
+	vp.HDCPLevel = string(l)
+	return nil
+}
+
+// GetAllowedCPC returns ALLOWED-CPC as a map from KEYFORMAT to its allowed Content
+// Protection Configuration labels. It is nil if ALLOWED-CPC is not set.
+func (vp *VariantParams) GetAllowedCPC() (map[string][]string, error) {
+	return parseAllowedCPC(vp.AllowedCPC)
+}
+
+// SetAllowedCPC sets ALLOWED-CPC from a map from KEYFORMAT to Content Protection
+// Configuration labels, with the key formats sorted. An empty map removes it.
+func (vp *VariantParams) SetAllowedCPC(cpc map[string][]string) error {
+	keyformats := make([]string, 0, len(cpc))
+	for kf := range cpc {
+		keyformats = append(keyformats, kf)
+	}
+	slices.Sort(keyformats)
+	entries := make([]string, 0, len(cpc))
+	for _, kf := range keyformats {
+		if kf == "" || strings.ContainsAny(kf, `,"`) || len(cpc[kf]) == 0 {
+			return fmt.Errorf("%w: ALLOWED-CPC for KEYFORMAT %q", ErrInvalidAttribute, kf)
+		}
+		for _, label := range cpc[kf] {
+			if !validListItem(label, `,/:"`) {
+				return fmt.Errorf("%w: ALLOWED-CPC label %q", ErrInvalidAttribute, label)
+			}
+		}
+		entries = append(entries, kf+":"+strings.Join(cpc[kf], "/"))
+	}
+	vp.AllowedCPC = strings.Join(entries, ",")
+	return nil
+}
+
+// GetReqVideoLayout returns the video layout specifiers of REQ-VIDEO-LAYOUT, such as CH-STEREO.
+func (vp *VariantParams) GetReqVideoLayout() []string {
+	return splitList(vp.ReqVideoLayout)
+}
+
+// SetReqVideoLayout sets REQ-VIDEO-LAYOUT to the video layout specifiers, or removes it
+// if there are none. Specifiers start with CH- for video channels or PROJ- for projections.
+func (vp *VariantParams) SetReqVideoLayout(specifiers ...string) error {
+	value := strings.Join(specifiers, ",")
+	if err := checkReqVideoLayout(value); err != nil {
+		return err
+	}
+	vp.ReqVideoLayout = value
+	return nil
+}
+
+// GetCharacteristics returns the Uniform Type Identifiers of CHARACTERISTICS.
+func (alt *Alternative) GetCharacteristics() []string {
+	return splitList(alt.Characteristics)
+}
+
+// SetCharacteristics sets CHARACTERISTICS to the Uniform Type Identifiers, such as
+// public.accessibility.describes-video, or removes it if there are none.
+func (alt *Alternative) SetCharacteristics(utis ...string) error {
+	for _, uti := range utis {
+		if !validListItem(uti, `,"`) {
+			return fmt.Errorf("%w: CHARACTERISTICS %q", ErrInvalidAttribute, uti)
+		}
+	}
+	alt.Characteristics = strings.Join(utis, ",")
+	return nil
+}
+
+// parseResolution parses a RESOLUTION value WxH.
+func parseResolution(s string) (width, height int, ok bool) {
+	w, h, found := strings.Cut(s, "x")
+	if !found {
+		return 0, 0, false
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	return width, height, errW == nil && errH == nil && width > 0 && height > 0
+}
+
+// parseAllowedCPC parses ALLOWED-CPC entries KEYFORMAT:CPC[/CPC...] separated by commas.
+// The KEYFORMAT is split at the last colon, since it may be a URN.
+func parseAllowedCPC(s string) (map[string][]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	cpc := make(map[string][]string)
+	for _, entry := range strings.Split(s, ",") {
+		i := strings.LastIndexByte(entry, ':')
+		if i <= 0 || i == len(entry)-1 {
+			return nil, fmt.Errorf("%w: ALLOWED-CPC entry %q", ErrInvalidAttribute, entry)
+		}
+		for _, label := range strings.Split(entry[i+1:], "/") {
+			if !validListItem(label, `,/:"`) {
+				return nil, fmt.Errorf("%w: ALLOWED-CPC label %q", ErrInvalidAttribute, label)
+			}
+			cpc[entry[:i]] = append(cpc[entry[:i]], label)
+		}
+	}
+	return cpc, nil
+}
+
+// checkReqVideoLayout checks that every specifier of a REQ-VIDEO-LAYOUT value has
+// parameters starting with CH- or PROJ-.
+func checkReqVideoLayout(s string) error {
+	for _, specifier := range splitList(s) {
+		for _, param := range strings.Split(specifier, "/") {
+			if !strings.HasPrefix(param, "CH-") && !strings.HasPrefix(param, "PROJ-") {
+				return fmt.Errorf("%w: REQ-VIDEO-LAYOUT %q", ErrInvalidAttribute, specifier)
+			}
+		}
+	}
+	return nil
+}
+
+// checkCharacteristics checks that a CHARACTERISTICS value has no empty UTI.
+func checkCharacteristics(s string) error {
+	for _, uti := range splitList(s) {
+		if uti == "" {
+			return fmt.Errorf("%w: CHARACTERISTICS %q", ErrInvalidAttribute, s)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma-separated attribute value, giving nil for an empty value.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// validListItem tells if s is a non-empty item of a list without any of the separators.
+func validListItem(s, separators string) bool {
+	return s != "" && !strings.ContainsAny(s, separators)
+}
@@ -0,0 +1,115 @@
+package m3u8
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestVariantParamsTypedValues(t *testing.T) {
+	is := is.New(t)
+	var vp VariantParams
+	w, h, err := vp.GetResolution()
+	is.NoErr(err)
+	is.Equal(w+h, 0) // no resolution
+	is.NoErr(vp.SetResolution(1920, 1080))
+	is.Equal(vp.Resolution, "1920x1080")
+	is.True(errors.Is(vp.SetResolution(0, 720), ErrInvalidAttribute))     // width must be positive
+	is.True(errors.Is(vp.SetResolution(1280, -720), ErrInvalidAttribute)) // height must be positive
+	is.Equal(vp.Resolution, "1920x1080")                                  // unchanged after an invalid value
+	w, h, err = vp.GetResolution()
+	is.NoErr(err)
+	is.Equal(w, 1920)
+	is.Equal(h, 1080)
+	vp.Resolution = "1920*1080"
+	_, _, err = vp.GetResolution()
+	is.True(errors.Is(err, ErrInvalidAttribute))
+
+	is.NoErr(vp.SetVideoRange(VideoRangePQ))
+	is.Equal(vp.VideoRange, "PQ")
+	is.Equal(vp.GetVideoRange(), VideoRangePQ)
+	is.True(errors.Is(vp.SetVideoRange("HDR"), ErrInvalidAttribute))
+	is.Equal(vp.VideoRange, "PQ") // unchanged after an invalid value
+
+	is.NoErr(vp.SetHDCPLevel(HDCPLevelType1))
+	is.Equal(vp.GetHDCPLevel(), HDCPLevelType1)
+	is.True(errors.Is(vp.SetHDCPLevel("TYPE-2"), ErrInvalidAttribute))
+	is.NoErr(vp.SetHDCPLevel(""))
+	is.Equal(vp.HDCPLevel, "")
+
+	is.NoErr(vp.SetReqVideoLayout(VideoChannelsStereo, VideoChannelsMono))
+	is.Equal(vp.ReqVideoLayout, "CH-STEREO,CH-MONO")
+	is.Equal(vp.GetReqVideoLayout(), []string{"CH-STEREO", "CH-MONO"})
+	is.True(errors.Is(vp.SetReqVideoLayout("STEREO"), ErrInvalidAttribute))
+}
+
+func TestAllowedCPC(t *testing.T) {
+	is := is.New(t)
+	var vp VariantParams
+	cpc, err := vp.GetAllowedCPC()
+	is.NoErr(err)
+	is.Equal(cpc, nil) // no ALLOWED-CPC
+
+	is.NoErr(vp.SetAllowedCPC(map[string][]string{
+		KeyformatWidevine: {"HW"},
+		KeyformatFairPlay: {"SMART-TV", "PC"},
+	}))
+	is.Equal(vp.AllowedCPC,
+		"com.apple.streamingkeydelivery:SMART-TV/PC,urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed:HW")
+	cpc, err = vp.GetAllowedCPC()
+	is.NoErr(err)
+	is.Equal(cpc[KeyformatFairPlay], []string{"SMART-TV", "PC"})
+	is.Equal(cpc[KeyformatWidevine], []string{"HW"}) // URN key format split at the last colon
+
+	is.True(errors.Is(vp.SetAllowedCPC(map[string][]string{"": {"HW"}}), ErrInvalidAttribute))
+	is.True(errors.Is(vp.SetAllowedCPC(map[string][]string{"com.example": {"A/B"}}), ErrInvalidAttribute))
+	for _, s := range []string{"KF:/X", "KF:A//B"} {
+		vp.AllowedCPC = s
+		_, err = vp.GetAllowedCPC()
+		is.True(errors.Is(err, ErrInvalidAttribute)) // empty label must be rejected
+	}
+	vp.AllowedCPC = "com.example"
+	_, err = vp.GetAllowedCPC()
+	is.True(errors.Is(err, ErrInvalidAttribute))
+}
+
+func TestCharacteristics(t *testing.T) {
+	is := is.New(t)
+	var alt Alternative
+	is.Equal(alt.GetCharacteristics(), nil)
+	is.NoErr(alt.SetCharacteristics("public.accessibility.transcribes-spoken-dialog", "public.easy-to-read"))
+	is.Equal(alt.Characteristics, "public.accessibility.transcribes-spoken-dialog,public.easy-to-read")
+	is.Equal(alt.GetCharacteristics(), []string{"public.accessibility.transcribes-spoken-dialog",
+		"public.easy-to-read"})
+	is.True(errors.Is(alt.SetCharacteristics("a,b"), ErrInvalidAttribute))
+	is.True(errors.Is(alt.SetCharacteristics(""), ErrInvalidAttribute))
+}
+
+func TestDecodeInvalidAttributeValues(t *testing.T) {
+	cases := []struct {
+		desc string
+		line string
+	}{
+		{"resolution", `#EXT-X-STREAM-INF:BANDWIDTH=1000,RESOLUTION=1280`},
+		{"video range", `#EXT-X-STREAM-INF:BANDWIDTH=1000,VIDEO-RANGE=HDR`},
+		{"hdcp level", `#EXT-X-STREAM-INF:BANDWIDTH=1000,HDCP-LEVEL=TYPE-2`},
+		{"allowed cpc", `#EXT-X-STREAM-INF:BANDWIDTH=1000,ALLOWED-CPC="com.example"`},
+		{"allowed cpc label", `#EXT-X-STREAM-INF:BANDWIDTH=1000,ALLOWED-CPC="com.example:A//B"`},
+		{"req video layout", `#EXT-X-STREAM-INF:BANDWIDTH=1000,REQ-VIDEO-LAYOUT="STEREO"`},
+		{"characteristics", `#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",` +
+			`CHARACTERISTICS="public.easy-to-read,",URI="en.m3u8"`},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			is := is.New(t)
+			input := "#EXTM3U\n" + c.line + "\nvideo.m3u8\n"
+			p := NewMasterPlaylist()
+			err := p.Decode(*bytes.NewBufferString(input), true)
+			is.True(errors.Is(err, ErrInvalidAttribute)) // strict mode must report the value
+			p = NewMasterPlaylist()
+			is.NoErr(p.Decode(*bytes.NewBufferString(input), false)) // non-strict mode keeps the value
+		})
+	}
+}
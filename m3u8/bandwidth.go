@@ -0,0 +1,153 @@
+package m3u8
+
+/*
+ This file defines computation of BANDWIDTH and AVERAGE-BANDWIDTH from media playlists.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrSegmentSizeUnknown = errors.New("segment size unknown")
+var ErrNoChunklist = errors.New("no media playlist")
+
+// SegmentSizeFunc returns the size in bytes of a segment that is not a byte range,
+// e.g. from the file system or a HEAD request.
+type SegmentSizeFunc func(seg *MediaSegment) (int64, error)
+
+// Bitrates are the peak and average segment bit rates of a stream in bits per second.
+type Bitrates struct {
+	Peak    uint32 // Peak segment bit rate, as for BANDWIDTH
+	Average uint32 // Average segment bit rate, as for AVERAGE-BANDWIDTH
+}
+
+// Bitrates computes the peak and average segment bit rates of the segments of the
+// playlist, as defined for BANDWIDTH and AVERAGE-BANDWIDTH. The peak is the largest bit
+// rate of a contiguous set of segments with a total duration between 0.5 and 1.5 times
+// the target duration. The size of a segment is its byte range length if set, else it
+// is given by size, which can be nil if all segments are byte ranges.
+func (p *MediaPlaylist) Bitrates(size SegmentSizeFunc) (Bitrates, error) {
+	segs := p.GetAllSegments()
+	if len(segs) == 0 {
+		return Bitrates{}, ErrPlaylistEmpty
+	}
+	bits := make([]float64, len(segs))
+	var totalBits, totalDuration float64
+	for i, seg := range segs {
+		n := seg.Limit
+		if n <= 0 {
+			if size == nil {
+				return Bitrates{}, fmt.Errorf("%w: %s", ErrSegmentSizeUnknown, seg.URI)
+			}
+			var err error
+			if n, err = size(seg); err != nil {
+				return Bitrates{}, fmt.Errorf("%w: %s: %w", ErrSegmentSizeUnknown, seg.URI, err)
+			}
+		}
+		bits[i] = float64(n) * 8
+		totalBits += bits[i]
+		totalDuration += seg.Duration
+	}
+
+	target := float64(p.TargetDuration)
+	if target == 0 {
+		target = math.Ceil(maxSegmentDuration(segs))
+	}
+	var peak float64
+	for i := range segs {
+		var sumBits, sumDuration float64
+		for j := i; j < len(segs); j++ {
+			sumBits += bits[j]
+			sumDuration += segs[j].Duration
+			if sumDuration > 1.5*target {
+				break
+			}
+			if sumDuration >= 0.5*target && sumBits/sumDuration > peak {
+				peak = sumBits / sumDuration
+			}
+		}
+	}
+	if peak == 0 {
+		// No set of segments has a duration in the range, use single segments
+		for i, seg := range segs {
+			if seg.Duration > 0 && bits[i]/seg.Duration > peak {
+				peak = bits[i] / seg.Duration
+			}
+		}
+	}
+	var average float64
+	if totalDuration > 0 {
+		average = totalBits / totalDuration
+	}
+	return Bitrates{Peak: uint32(math.Ceil(peak)), Average: uint32(math.Ceil(average))}, nil
+}
+
+// VariantBitrates computes BANDWIDTH and AVERAGE-BANDWIDTH of a variant from its
+// Chunklist. For each rendition group the variant refers to, the highest bit rates
+// among the renditions with a URI are added, which requires their Chunklist.
+// Renditions without URI are part of the variant stream.
+func (p *MasterPlaylist) VariantBitrates(v *Variant, size SegmentSizeFunc) (Bitrates, error) {
+	if v.Chunklist == nil {
+		return Bitrates{}, fmt.Errorf("%w: variant %s", ErrNoChunklist, v.URI)
+	}
+	b, err := v.Chunklist.Bitrates(size)
+	if err != nil {
+		return Bitrates{}, fmt.Errorf("variant %s: %w", v.URI, err)
+	}
+	if v.Iframe {
+		return b, nil
+	}
+	highest := make(map[string]Bitrates)
+	for _, alt := range p.variantRenditions(v) {
+		if alt == nil || alt.URI == "" {
+			continue
+		}
+		if alt.Chunklist == nil {
+			return Bitrates{}, fmt.Errorf("%w: rendition %s", ErrNoChunklist, alt.URI)
+		}
+		ab, err := alt.Chunklist.Bitrates(size)
+		if err != nil {
+			return Bitrates{}, fmt.Errorf("rendition %s: %w", alt.URI, err)
+		}
+		h := highest[alt.Type]
+		h.Peak = max(h.Peak, ab.Peak)
+		h.Average = max(h.Average, ab.Average)
+		highest[alt.Type] = h
+	}
+	for _, h := range highest {
+		b.Peak += h.Peak
+		b.Average += h.Average
+	}
+	return b, nil
+}
+
+// UpdateBandwidths sets BANDWIDTH and AVERAGE-BANDWIDTH of all variants, including
+// I-frame variants, from VariantBitrates. No variant is changed if one fails.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) UpdateBandwidths(size SegmentSizeFunc) error {
+	bitrates := make([]Bitrates, len(p.Variants))
+	for i, v := range p.Variants {
+		b, err := p.VariantBitrates(v, size)
+		if err != nil {
+			return err
+		}
+		bitrates[i] = b
+	}
+	for i, v := range p.Variants {
+		v.Bandwidth = bitrates[i].Peak
+		v.AverageBandwidth = bitrates[i].Average
+	}
+	p.buf.Reset()
+	return nil
+}
+
+// maxSegmentDuration returns the longest duration of the segments.
+func maxSegmentDuration(segs []*MediaSegment) float64 {
+	var d float64
+	for _, seg := range segs {
+		d = max(d, seg.Duration)
+	}
+	return d
+}
@@ -0,0 +1,88 @@
+package m3u8
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// sizedPlaylist returns a VOD playlist with a segment of each size, all 4s long but the last,
+// which is 2s long. With byteRange, the sizes are byte range lengths.
+func sizedPlaylist(t *testing.T, byteRange bool, sizes ...int64) *MediaPlaylist {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, uint(len(sizes)))
+	is.NoErr(err)
+	for i, size := range sizes {
+		seg := &MediaSegment{URI: "seg.mp4", Duration: 4}
+		if i == len(sizes)-1 {
+			seg.Duration = 2
+		}
+		if byteRange {
+			seg.Limit = size
+		} else {
+			seg.Title = string(rune('a' + i))
+		}
+		is.NoErr(p.AppendSegment(seg))
+	}
+	p.SetTargetDuration(4)
+	p.Close()
+	return p
+}
+
+func TestMediaPlaylistBitrates(t *testing.T) {
+	is := is.New(t)
+	p := sizedPlaylist(t, true, 1000000, 2000000, 1000000, 500000)
+	b, err := p.Bitrates(nil)
+	is.NoErr(err)
+	is.Equal(b.Peak, uint32(4000000))    // second segment
+	is.Equal(b.Average, uint32(2571429)) // 36 Mbit in 14s, rounded up
+
+	sizes := map[string]int64{"a": 100000, "b": 100000, "c": 300000, "d": 50000}
+	p = sizedPlaylist(t, false, 0, 0, 0, 0)
+	b, err = p.Bitrates(func(seg *MediaSegment) (int64, error) { return sizes[seg.Title], nil })
+	is.NoErr(err)
+	is.Equal(b.Peak, uint32(600000))
+	is.Equal(b.Average, uint32(314286))
+
+	_, err = p.Bitrates(nil)
+	is.True(errors.Is(err, ErrSegmentSizeUnknown)) // no size without byte range or callback
+	failed := errors.New("not found")
+	_, err = p.Bitrates(func(*MediaSegment) (int64, error) { return 0, failed })
+	is.True(errors.Is(err, ErrSegmentSizeUnknown))
+	is.True(errors.Is(err, failed))
+
+	empty, _ := NewMediaPlaylist(0, 1)
+	_, err = empty.Bitrates(nil)
+	is.True(errors.Is(err, ErrPlaylistEmpty))
+}
+
+func TestUpdateBandwidths(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	en := &Alternative{Type: RenditionAudio, GroupId: "aac", Name: "English", Default: true, URI: "en.m3u8",
+		Chunklist: sizedPlaylist(t, true, 64000, 64000, 64000, 32000)}
+	sv := &Alternative{Type: RenditionAudio, GroupId: "aac", Name: "Swedish", URI: "sv.m3u8",
+		Chunklist: sizedPlaylist(t, true, 96000, 96000, 96000, 48000)}
+	is.NoErr(p.AddRendition(en))
+	is.NoErr(p.AddRendition(sv))
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionClosedCaptions, GroupId: "cc", Name: "English",
+		InstreamId: "CC1"}))
+	p.Append("video.m3u8", sizedPlaylist(t, true, 1000000, 2000000, 1000000, 500000),
+		VariantParams{Audio: "aac", Captions: "cc"})
+	p.Append("iframe.m3u8", sizedPlaylist(t, true, 20000, 20000, 20000, 10000), VariantParams{Iframe: true})
+
+	b, err := p.VariantBitrates(p.Variants[0], nil)
+	is.NoErr(err)
+	is.Equal(b, Bitrates{Peak: 4192000, Average: 2763429}) // video and the highest audio rendition
+
+	is.NoErr(p.UpdateBandwidths(nil))
+	is.Equal(p.Variants[0].Bandwidth, uint32(4192000))
+	is.Equal(p.Variants[0].AverageBandwidth, uint32(2763429))
+	is.Equal(p.Variants[1].Bandwidth, uint32(40000))
+
+	sv.Chunklist = nil
+	err = p.UpdateBandwidths(nil)
+	is.True(errors.Is(err, ErrNoChunklist))            // rendition with URI needs its media playlist
+	is.Equal(p.Variants[0].Bandwidth, uint32(4192000)) // unchanged on error
+}
@@ -0,0 +1,228 @@
+package m3u8
+
+/*
+ This file defines building a master playlist from media playlists and their metadata.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMediaInfo = errors.New("invalid media playlist info")
+
+// MediaInfo is a media playlist with the metadata needed to refer to it from a master
+// playlist, e.g. from a sidecar file of a packager.
+type MediaInfo struct {
+	Playlist         *MediaPlaylist // Media playlist, used for bit rates and EXT-X-INDEPENDENT-SEGMENTS
+	URI              string         // URI of the media playlist
+	Type             string         // RenditionVideo, RenditionAudio or RenditionSubtitles
+	IFrame           bool           // I-frame playlist of a video
+	Codecs           string         // CODECS of the media
+	Resolution       string         // RESOLUTION (WxH) of video
+	FrameRate        float64        // FRAME-RATE of video
+	VideoRange       string         // VIDEO-RANGE of video
+	HDCPLevel        string         // HDCP-LEVEL of video
+	Bandwidth        uint32         // Peak bit rate, computed from Playlist if 0
+	AverageBandwidth uint32         // Average bit rate, computed from Playlist if Bandwidth is 0
+	Language         string         // LANGUAGE of audio and subtitles
+	Name             string         // NAME of audio and subtitles, Language if empty
+	GroupId          string         // GROUP-ID of audio and subtitles, derived from the codec if empty
+	Default          bool           // DEFAULT=YES for audio and subtitles, else the first of a group
+	Channels         *Channels      // CHANNELS of audio
+}
+
+// BuildOptions configures BuildMasterPlaylist.
+type BuildOptions struct {
+	SegmentSize SegmentSizeFunc // Sizes of segments without byte range, for computing bit rates
+}
+
+// BuildMasterPlaylist builds a master playlist from media playlists. Audio and subtitles
+// become EXT-X-MEDIA renditions, grouped by GroupId, and there is a variant for every
+// combination of a video, an audio group and a subtitles group.
+// I-frame playlists become I-frame variants. BANDWIDTH and AVERAGE-BANDWIDTH are the sum
+// of the bit rates of the video and the highest of the audio group, subtitles are not
+// counted. EXT-X-INDEPENDENT-SEGMENTS is set if all media playlists have it, and the
+// version is set by CalcMinVersion.
+func BuildMasterPlaylist(inputs []MediaInfo, opts BuildOptions) (*MasterPlaylist, error) {
+	p := NewMasterPlaylist()
+	var videos, iframes []*MediaInfo
+	bitrates := make(map[*MediaInfo]Bitrates)
+	groupBitrates := make(map[string]Bitrates)
+	groupCodecs := make(map[string][]string)
+	var audioGroups, subtitleGroups []string
+	independent := len(inputs) > 0
+
+	for i := range inputs {
+		in := &inputs[i]
+		if in.URI == "" {
+			return nil, fmt.Errorf("%w: no URI", ErrInvalidMediaInfo)
+		}
+		independent = independent && in.Playlist != nil && in.Playlist.IndependentSegments()
+		if in.Type != RenditionSubtitles {
+			b, err := in.bitrates(opts.SegmentSize)
+			if err != nil {
+				return nil, err
+			}
+			bitrates[in] = b
+		}
+		switch {
+		case in.Type == RenditionVideo && in.IFrame:
+			iframes = append(iframes, in)
+		case in.Type == RenditionVideo:
+			videos = append(videos, in)
+		case in.Type == RenditionAudio || in.Type == RenditionSubtitles:
+			alt := in.alternative()
+			if err := p.AddRendition(alt); err != nil {
+				return nil, fmt.Errorf("%s: %w", in.URI, err)
+			}
+			if in.Type == RenditionSubtitles {
+				if !slices.Contains(subtitleGroups, alt.GroupId) {
+					subtitleGroups = append(subtitleGroups, alt.GroupId)
+				}
+				continue
+			}
+			if !slices.Contains(audioGroups, alt.GroupId) {
+				audioGroups = append(audioGroups, alt.GroupId)
+			}
+			h := groupBitrates[alt.GroupId]
+			h.Peak = max(h.Peak, bitrates[in].Peak)
+			h.Average = max(h.Average, bitrates[in].Average)
+			groupBitrates[alt.GroupId] = h
+			groupCodecs[alt.GroupId] = appendCodecs(groupCodecs[alt.GroupId], in.Codecs)
+		default:
+			return nil, fmt.Errorf("%w: %s has TYPE=%s", ErrInvalidMediaInfo, in.URI, in.Type)
+		}
+	}
+	if len(videos) == 0 {
+		return nil, fmt.Errorf("%w: no video", ErrInvalidMediaInfo)
+	}
+	setDefaultRenditions(p)
+
+	if len(audioGroups) == 0 {
+		audioGroups = []string{""}
+	}
+	if len(subtitleGroups) == 0 {
+		subtitleGroups = []string{""}
+	}
+	for _, v := range videos {
+		for _, audio := range audioGroups {
+			for _, subtitles := range subtitleGroups {
+				params := v.variantParams(bitrates[v])
+				params.Audio = audio
+				params.Subtitles = subtitles
+				if audio != "" {
+					codecs := appendCodecs(nil, v.Codecs)
+					params.Codecs = strings.Join(appendCodecs(codecs, groupCodecs[audio]...), ",")
+					params.Bandwidth += groupBitrates[audio].Peak
+					params.AverageBandwidth += groupBitrates[audio].Average
+				}
+				p.Append(v.URI, v.Playlist, params)
+			}
+		}
+	}
+	for _, v := range iframes {
+		params := v.variantParams(bitrates[v])
+		params.Iframe = true
+		params.FrameRate = 0
+		p.Append(v.URI, v.Playlist, params)
+	}
+	p.UpdateAlternatives()
+	p.SetIndependentSegments(independent)
+	ver, _ := p.CalcMinVersion()
+	p.SetVersion(ver)
+	return p, nil
+}
+
+// bitrates returns the bit rates of the metadata, or else of the media playlist.
+func (in *MediaInfo) bitrates(size SegmentSizeFunc) (Bitrates, error) {
+	if in.Bandwidth > 0 {
+		return Bitrates{Peak: in.Bandwidth, Average: in.AverageBandwidth}, nil
+	}
+	if in.Playlist == nil {
+		return Bitrates{}, fmt.Errorf("%w: %s", ErrNoChunklist, in.URI)
+	}
+	b, err := in.Playlist.Bitrates(size)
+	if err != nil {
+		return Bitrates{}, fmt.Errorf("%s: %w", in.URI, err)
+	}
+	return b, nil
+}
+
+// variantParams returns the parameters of a variant for a video.
+func (in *MediaInfo) variantParams(b Bitrates) VariantParams {
+	return VariantParams{
+		Bandwidth:        b.Peak,
+		AverageBandwidth: b.Average,
+		Codecs:           in.Codecs,
+		Resolution:       in.Resolution,
+		FrameRate:        in.FrameRate,
+		HDCPLevel:        in.HDCPLevel,
+		VideoRange:       in.VideoRange,
+	}
+}
+
+// alternative returns the rendition for audio or subtitles.
+func (in *MediaInfo) alternative() *Alternative {
+	alt := &Alternative{
+		Type:       in.Type,
+		URI:        in.URI,
+		GroupId:    in.GroupId,
+		Language:   in.Language,
+		Name:       in.Name,
+		Default:    in.Default,
+		Autoselect: true,
+		Channels:   in.Channels,
+		Chunklist:  in.Playlist,
+	}
+	if alt.Name == "" {
+		alt.Name = in.Language
+	}
+	if alt.Name == "" {
+		alt.Name = in.URI
+	}
+	if alt.GroupId == "" {
+		alt.GroupId = defaultGroupId(in)
+	}
+	return alt
+}
+
+// defaultGroupId returns a GROUP-ID from the type, codec and channels, e.g. audio-mp4a-2.
+func defaultGroupId(in *MediaInfo) string {
+	if in.Type == RenditionSubtitles {
+		return "subs"
+	}
+	id := "audio"
+	first, _, _ := strings.Cut(in.Codecs, ",")
+	if typ, _, _ := strings.Cut(strings.TrimSpace(first), "."); typ != "" {
+		id += "-" + typ
+	}
+	if in.Channels != nil && in.Channels.Amount > 0 {
+		id += "-" + strconv.Itoa(in.Channels.Amount)
+	}
+	return id
+}
+
+// setDefaultRenditions makes the first rendition of every group without DEFAULT=YES the default.
+func setDefaultRenditions(p *MasterPlaylist) {
+	for _, g := range p.RenditionGroups {
+		if !slices.ContainsFunc(g.Renditions, func(alt *Alternative) bool { return alt.Default }) {
+			g.Renditions[0].Default = true
+		}
+	}
+}
+
+// appendCodecs appends the codecs of CODECS values not already in codecs.
+func appendCodecs(codecs []string, values ...string) []string {
+	for _, s := range values {
+		for _, c := range strings.Split(s, ",") {
+			if c = strings.TrimSpace(c); c != "" && !slices.Contains(codecs, c) {
+				codecs = append(codecs, c)
+			}
+		}
+	}
+	return codecs
+}
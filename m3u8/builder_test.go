@@ -0,0 +1,107 @@
+package m3u8
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestBuildMasterPlaylist(t *testing.T) {
+	is := is.New(t)
+	independent := func(p *MediaPlaylist) *MediaPlaylist {
+		p.SetIndependentSegments(true)
+		return p
+	}
+	inputs := []MediaInfo{
+		{Type: RenditionVideo, URI: "v720.m3u8", Codecs: "avc1.64001f", Resolution: "1280x720", FrameRate: 25,
+			Playlist: independent(sizedPlaylist(t, true, 1000000, 2000000, 1000000, 500000))},
+		{Type: RenditionVideo, URI: "v1080.m3u8", Codecs: "avc1.640028", Resolution: "1920x1080", FrameRate: 25,
+			Bandwidth: 6000000, AverageBandwidth: 5000000, Playlist: independent(sizedPlaylist(t, true, 1))},
+		{Type: RenditionVideo, IFrame: true, URI: "v720_iframe.m3u8", Codecs: "avc1.64001f",
+			Resolution: "1280x720", Playlist: independent(sizedPlaylist(t, true, 20000, 20000, 20000, 10000))},
+		{Type: RenditionAudio, URI: "en.m3u8", Codecs: "mp4a.40.2", Language: "en", Channels: &Channels{Amount: 2},
+			Playlist: independent(sizedPlaylist(t, true, 64000, 64000, 64000, 32000))},
+		{Type: RenditionAudio, URI: "sv.m3u8", Codecs: "mp4a.40.2", Language: "sv", Name: "Svenska",
+			Channels: &Channels{Amount: 2}, Bandwidth: 192000, AverageBandwidth: 192000,
+			Playlist: independent(sizedPlaylist(t, true, 1))},
+		{Type: RenditionAudio, URI: "ec3.m3u8", Codecs: "ec-3", Language: "en", Channels: &Channels{Amount: 6},
+			Bandwidth: 384000, AverageBandwidth: 384000, Playlist: independent(sizedPlaylist(t, true, 1))},
+		{Type: RenditionSubtitles, URI: "subs_en.m3u8", Language: "en",
+			Playlist: independent(sizedPlaylist(t, false, 0))},
+	}
+	p, err := BuildMasterPlaylist(inputs, BuildOptions{})
+	is.NoErr(err)
+	want := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-mp4a-2",NAME="en",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-mp4a-2",NAME="Svenska",LANGUAGE="sv",DEFAULT=NO,AUTOSELECT=YES,CHANNELS="2",URI="sv.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-ec-3-6",NAME="en",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="6",URI="ec3.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="en",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,URI="subs_en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=4192000,AVERAGE-BANDWIDTH=2763429,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=25.000,AUDIO="audio-mp4a-2",SUBTITLES="subs"
+v720.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=4384000,AVERAGE-BANDWIDTH=2955429,CODECS="avc1.64001f,ec-3",RESOLUTION=1280x720,FRAME-RATE=25.000,AUDIO="audio-ec-3-6",SUBTITLES="subs"
+v720.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=6192000,AVERAGE-BANDWIDTH=5192000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=25.000,AUDIO="audio-mp4a-2",SUBTITLES="subs"
+v1080.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=6384000,AVERAGE-BANDWIDTH=5384000,CODECS="avc1.640028,ec-3",RESOLUTION=1920x1080,FRAME-RATE=25.000,AUDIO="audio-ec-3-6",SUBTITLES="subs"
+v1080.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=40000,AVERAGE-BANDWIDTH=40000,CODECS="avc1.64001f",RESOLUTION=1280x720,URI="v720_iframe.m3u8"
+`
+	is.Equal(p.String(), want)
+	is.Equal(p.Variants[0].Chunklist, inputs[0].Playlist)
+	is.Equal(p.RenditionGroups[0].Renditions[0].Chunklist, inputs[3].Playlist)
+	is.Equal(len(p.Variants[0].Alternatives), 3) // two audio renditions and the subtitles
+	is.NoErr(p.ValidateRenditionGroups())
+
+	inputs[6].Playlist.SetIndependentSegments(false)
+	p, err = BuildMasterPlaylist(inputs, BuildOptions{})
+	is.NoErr(err)
+	is.True(!p.IndependentSegments()) // not all media playlists have independent segments
+}
+
+func TestBuildMasterPlaylistSubtitleGroups(t *testing.T) {
+	is := is.New(t)
+	inputs := []MediaInfo{
+		{Type: RenditionVideo, URI: "v.m3u8", Codecs: "avc1.64001f", Bandwidth: 1000000},
+		{Type: RenditionAudio, URI: "en.m3u8", Codecs: "mp4a.40.2", Language: "en", Bandwidth: 64000},
+		{Type: RenditionSubtitles, URI: "subs_en.vtt.m3u8", Language: "en", GroupId: "vtt"},
+		{Type: RenditionSubtitles, URI: "subs_en.ttml.m3u8", Language: "en", GroupId: "ttml"},
+	}
+	p, err := BuildMasterPlaylist(inputs, BuildOptions{})
+	is.NoErr(err)
+	is.Equal(len(p.Variants), 2) // a variant for each subtitles group
+	is.Equal(p.Variants[0].Subtitles, "vtt")
+	is.Equal(p.Variants[1].Subtitles, "ttml")
+	for _, v := range p.Variants {
+		is.Equal(v.Audio, "audio-mp4a")
+		is.Equal(v.Bandwidth, uint32(1064000))
+		is.Equal(len(v.Alternatives), 2) // the audio and its own subtitles
+		is.Equal(v.Alternatives[1].GroupId, v.Subtitles)
+	}
+	is.NoErr(p.ValidateRenditionGroups())
+}
+
+func TestBuildMasterPlaylistErrors(t *testing.T) {
+	video := MediaInfo{Type: RenditionVideo, URI: "v.m3u8", Bandwidth: 1000}
+	cases := []struct {
+		desc   string
+		inputs []MediaInfo
+		err    error
+	}{
+		{"no video", []MediaInfo{{Type: RenditionAudio, URI: "a.m3u8", Bandwidth: 1000}}, ErrInvalidMediaInfo},
+		{"no URI", []MediaInfo{{Type: RenditionVideo, Bandwidth: 1000}}, ErrInvalidMediaInfo},
+		{"bad type", []MediaInfo{video, {Type: "TEXT", URI: "t.m3u8", Bandwidth: 1000}}, ErrInvalidMediaInfo},
+		{"no bit rate", []MediaInfo{{Type: RenditionVideo, URI: "v.m3u8"}}, ErrNoChunklist},
+		{"duplicate name", []MediaInfo{video, {Type: RenditionAudio, URI: "a.m3u8", Language: "en", Bandwidth: 1},
+			{Type: RenditionAudio, URI: "b.m3u8", Language: "en", Bandwidth: 1}}, ErrInvalidRendition},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			is := is.New(t)
+			_, err := BuildMasterPlaylist(c.inputs, BuildOptions{})
+			is.True(errors.Is(err, c.err)) // build must fail
+		})
+	}
+}
@@ -20,12 +20,11 @@ func (p *MasterPlaylist) CalcMinVersion() (ver uint8, reason string) {
 	// A Multivariant Playlist MUST indicate an EXT-X-VERSION of 7 or higher
 	// if it contains:
 	// *  "SERVICE" values for the INSTREAM-ID attribute of the EXT-X-MEDIA
-	for _, variant := range p.Variants {
-		for _, alt := range variant.Alternatives {
-			if strings.HasPrefix(alt.InstreamId, "SERVICE") {
-				updateMin(&ver, &reason, 7, "SERVICE value for the INSTREAM-ID attribute of the EXT-X-MEDIA")
-				break
-			}
+	renditions := p.allRenditions()
+	for _, alt := range renditions {
+		if strings.HasPrefix(alt.InstreamId, "SERVICE") {
+			updateMin(&ver, &reason, 7, "SERVICE value for the INSTREAM-ID attribute of the EXT-X-MEDIA")
+			break
 		}
 	}
 
@@ -51,13 +50,11 @@ func (p *MasterPlaylist) CalcMinVersion() (ver uint8, reason string) {
 	// contains:
 	// * An EXT-X-MEDIA tag with INSTREAM-ID attribute for non CLOSED-
 	// CAPTIONS TYPE.
-	for _, variant := range p.Variants {
-		for _, alt := range variant.Alternatives {
-			if (alt.Type != "CLOSED-CAPTIONS") && (alt.InstreamId != "") {
-				updateMin(&ver, &reason, 13,
-					"EXT-X-MEDIA tag with INSTREAM-ID attribute for non CLOSED-CAPTIONS TYPE")
-				break
-			}
+	for _, alt := range renditions {
+		if (alt.Type != "CLOSED-CAPTIONS") && (alt.InstreamId != "") {
+			updateMin(&ver, &reason, 13,
+				"EXT-X-MEDIA tag with INSTREAM-ID attribute for non CLOSED-CAPTIONS TYPE")
+			break
 		}
 	}
 
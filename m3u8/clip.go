@@ -0,0 +1,237 @@
+package m3u8
+
+/*
+ This file defines functions for clipping VOD media playlists to a time range.
+*/
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrClipRangeEmpty = errors.New("clip range selects no segments")
+var ErrNoProgramDateTime = errors.New("playlist has no EXT-X-PROGRAM-DATE-TIME")
+var ErrClipNotVOD = errors.New("only VOD or closed playlists can be clipped")
+
+// ClipOptions controls how a media playlist is clipped.
+type ClipOptions struct {
+	// SetStart sets EXT-X-START with PRECISE=YES to the start of the range, which may be
+	// inside the first segment, so that playback starts exactly there. A range that starts
+	// before the first segment gets no EXT-X-START, since playback starts there anyway.
+	SetStart bool
+}
+
+// Clip returns a new media playlist with the segments covering the range [start, end)
+// in media time, i.e. seconds from the start of the first segment. p is not changed.
+// It returns ErrClipNotVOD for a live or EVENT playlist that is not closed, since its
+// segments may still change.
+//
+// The effective EXT-X-MAP and EXT-X-KEY tags are carried into the first segment of the
+// new playlist, and EXT-X-MEDIA-SEQUENCE and EXT-X-DISCONTINUITY-SEQUENCE are adjusted
+// for the removed segments. An EXT-X-DISCONTINUITY in front of the first retained
+// segment is counted in EXT-X-DISCONTINUITY-SEQUENCE instead, unless it is the first
+// segment of p, where it is kept. The target duration is recalculated,
+// partial segments and preload hints are dropped, and EXT-X-DATERANGE tags outside the
+// range are removed if the playlist has EXT-X-PROGRAM-DATE-TIME.
+//
+// The new playlist has a window size of 0 and a capacity of its number of segments.
+func (p *MediaPlaylist) Clip(start, end float64, opts ClipOptions) (*MediaPlaylist, error) {
+	if !p.clippable() {
+		return nil, ErrClipNotVOD
+	}
+	if end <= start {
+		return nil, ErrClipRangeEmpty
+	}
+	segs := p.GetAllSegments()
+	first, last := -1, -1
+	var firstStart, segStart float64
+	for i, seg := range segs {
+		segEnd := segStart + seg.Duration
+		if segEnd > start && segStart < end {
+			if first < 0 {
+				first = i
+				firstStart = segStart
+			}
+			last = i
+		}
+		segStart = segEnd
+	}
+	if first < 0 {
+		return nil, ErrClipRangeEmpty
+	}
+	return p.clip(segs, first, last, start-firstStart, opts), nil
+}
+
+// ClipDateTime returns a new media playlist with the segments covering the wall-clock
+// range [start, end) as given by EXT-X-PROGRAM-DATE-TIME. Segments without an explicit
+// program date time get one interpolated from the closest preceding segment that has one,
+// or from the first one for leading segments. Otherwise, it works like Clip.
+func (p *MediaPlaylist) ClipDateTime(start, end time.Time, opts ClipOptions) (*MediaPlaylist, error) {
+	if !p.clippable() {
+		return nil, ErrClipNotVOD
+	}
+	if !end.After(start) {
+		return nil, ErrClipRangeEmpty
+	}
+	segs := p.GetAllSegments()
+	pdts := programDateTimes(segs)
+	if pdts == nil {
+		return nil, ErrNoProgramDateTime
+	}
+	first, last := -1, -1
+	for i, seg := range segs {
+		segEnd := pdts[i].Add(durationOf(seg.Duration))
+		if segEnd.After(start) && pdts[i].Before(end) {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return nil, ErrClipRangeEmpty
+	}
+	return p.clip(segs, first, last, start.Sub(pdts[first]).Seconds(), opts), nil
+}
+
+// clippable tells if the playlist is VOD or closed.
+func (p *MediaPlaylist) clippable() bool {
+	return p.Closed || p.MediaType == VOD
+}
+
+// clip builds the playlist of segs[first:last+1]. startOffset is the start of the
+// range relative to the start of segs[first], and negative if the range starts before it.
+func (p *MediaPlaylist) clip(segs []*MediaSegment, first, last int, startOffset float64,
+	opts ClipOptions) *MediaPlaylist {
+	pdts := programDateTimes(segs)
+	// State in effect for the first retained segment
+	effMap := p.Map
+	effKeys := p.Keys
+	discontinuities := uint64(0)
+	for i := 0; i <= first; i++ {
+		if segs[i].Map != nil {
+			effMap = segs[i].Map
+		}
+		if len(segs[i].Keys) > 0 {
+			effKeys = segs[i].Keys
+		}
+		if i > 0 && segs[i].Discontinuity {
+			discontinuities++
+		}
+	}
+
+	c := p.Clone()
+	all := c.GetAllSegments()
+	n := uint(last - first + 1)
+	putSegmentSlice(&c.Segments)
+	c.Segments = getSegmentSlice(n)
+	for i, seg := range all {
+		if i < first || i > last {
+			releaseSegment(seg)
+			continue
+		}
+		c.Segments[i-first] = seg
+	}
+	c.head, c.tail, c.count, c.capacity, c.winsize, c.windowDuration = 0, 0, n, n, 0, 0
+	c.segmentsDuration = 0
+	for _, seg := range c.Segments {
+		c.segmentsDuration += seg.Duration
+	}
+	c.skippedSegments = 0
+	c.PartialSegments = nil
+	c.PreloadHints = nil
+	c.PreloadMapHint = nil
+
+	firstSeg := c.Segments[0]
+	c.SeqNo = firstSeg.SeqId
+	c.SegmentIndexing = SegmentIndexing{NextMSNIndex: c.SeqNo + uint64(n)}
+	c.DiscontinuitySeq = p.DiscontinuitySeq + discontinuities
+	if first > 0 {
+		firstSeg.Discontinuity = false
+	}
+	c.Map = cloneMap(effMap, make(map[*Map]*Map))
+	firstSeg.Map = c.Map
+	c.Keys = append([]Key(nil), effKeys...)
+	firstSeg.Keys = append([]Key(nil), effKeys...)
+	if pdts != nil && firstSeg.ProgramDateTime.IsZero() {
+		firstSeg.ProgramDateTime = pdts[first]
+	}
+	if opts.SetStart {
+		c.StartTime = max(startOffset, 0)
+		c.StartTimePrecise = true
+	}
+	if pdts != nil {
+		windowStart := pdts[first]
+		windowEnd := pdts[last].Add(durationOf(segs[last].Duration))
+		c.DateRanges = dateRangesInWindow(c.DateRanges, windowStart, windowEnd)
+	}
+	if last != len(segs)-1 {
+		c.TrailingDateRanges = nil
+	}
+	c.TargetDuration = c.CalculateTargetDuration(c.ver)
+	c.buf.Reset()
+	return c
+}
+
+// programDateTimes returns the program date time of every segment, or nil if no segment
+// has one. Missing values are interpolated from the closest preceding segment with a
+// program date time, and extrapolated backwards from the first one for leading segments.
+func programDateTimes(segs []*MediaSegment) []time.Time {
+	firstPDT := -1
+	for i, seg := range segs {
+		if !seg.ProgramDateTime.IsZero() {
+			firstPDT = i
+			break
+		}
+	}
+	if firstPDT < 0 {
+		return nil
+	}
+	pdts := make([]time.Time, len(segs))
+	pdts[firstPDT] = segs[firstPDT].ProgramDateTime
+	for i := firstPDT - 1; i >= 0; i-- {
+		pdts[i] = pdts[i+1].Add(-durationOf(segs[i].Duration))
+	}
+	for i := firstPDT + 1; i < len(segs); i++ {
+		if !segs[i].ProgramDateTime.IsZero() {
+			pdts[i] = segs[i].ProgramDateTime
+		} else {
+			pdts[i] = pdts[i-1].Add(durationOf(segs[i-1].Duration))
+		}
+	}
+	return pdts
+}
+
+// dateRangesInWindow returns the date ranges overlapping [start, end).
+// Date ranges without an end are kept if they start before end.
+func dateRangesInWindow(drs []*DateRange, start, end time.Time) []*DateRange {
+	var out []*DateRange
+	for _, dr := range drs {
+		if !dr.StartDate.Before(end) {
+			continue
+		}
+		if drEnd := dateRangeEnd(dr); drEnd != nil && !drEnd.After(start) {
+			continue
+		}
+		out = append(out, dr)
+	}
+	return out
+}
+
+// dateRangeEnd returns the END-DATE of a date range, or START-DATE plus DURATION.
+// It returns nil for a date range without an end.
+func dateRangeEnd(dr *DateRange) *time.Time {
+	switch {
+	case dr.EndDate != nil:
+		return dr.EndDate
+	case dr.Duration != nil:
+		end := dr.StartDate.Add(durationOf(*dr.Duration))
+		return &end
+	}
+	return nil
+}
+
+// durationOf converts seconds to a time.Duration.
+func durationOf(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
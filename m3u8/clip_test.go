@@ -0,0 +1,157 @@
+package m3u8
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// newClipTestPlaylist returns a VOD playlist with 6 segments of 4s, a map change at
+// segment 2, a key change at segment 3 and a discontinuity at segment 4.
+func newClipTestPlaylist(t *testing.T) *MediaPlaylist {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 6)
+	is.NoErr(err) // must create playlist
+	p.SeqNo = 10
+	p.DiscontinuitySeq = 2
+	p.SetDefaultMap("init1.mp4", 0, 0)
+	is.NoErr(p.SetDefaultKey("AES-128", "key1", "", "", ""))
+	pdt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		is.NoErr(p.Append("seg"+string(rune('0'+i))+".m4s", 4, ""))
+		switch i {
+		case 0:
+			is.NoErr(p.SetProgramDateTime(pdt))
+		case 2:
+			is.NoErr(p.SetMap("init2.mp4", 0, 0))
+		case 3:
+			is.NoErr(p.SetKey("AES-128", "key2", "", "", ""))
+		case 4:
+			is.NoErr(p.SetDiscontinuity())
+		}
+	}
+	p.DateRanges = []*DateRange{
+		{ID: "early", StartDate: pdt, EndDate: ptrTime(pdt.Add(2 * time.Second))},
+		{ID: "late", StartDate: pdt.Add(13 * time.Second)},
+	}
+	p.Close()
+	return p
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestClip(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	want := p.String()
+
+	c, err := p.Clip(13, 21, ClipOptions{SetStart: true})
+	is.NoErr(err)
+	is.Equal(p.String(), want) // original must not change
+	is.Equal(c.Count(), uint(3))
+	is.Equal(c.SeqNo, uint64(13))
+	is.Equal(c.DiscontinuitySeq, uint64(2)) // discontinuity at segment 4 is kept
+	is.Equal(c.Map.URI, "init2.mp4")
+	is.Equal(c.Keys[0].URI, "key2")
+	is.Equal(c.StartTime, 1.0)
+	is.True(c.StartTimePrecise)
+	is.Equal(c.TargetDuration, uint(4))
+	is.Equal(c.Segments[0].ProgramDateTime, time.Date(2025, 1, 1, 12, 0, 12, 0, time.UTC))
+	is.Equal(len(c.DateRanges), 1) // only the daterange inside the window is kept
+	is.Equal(c.DateRanges[0].ID, "late")
+
+	out := c.String()
+	is.Equal(strings.Count(out, "#EXT-X-MAP:"), 1)
+	is.Equal(strings.Count(out, "#EXT-X-KEY:"), 1)
+	is.Equal(strings.Count(out, "#EXT-X-DISCONTINUITY\n"), 1)
+	is.True(strings.Contains(out, "#EXT-X-MEDIA-SEQUENCE:13\n"))
+	is.True(strings.Contains(out, "#EXT-X-START:TIME-OFFSET=1.000,PRECISE=YES\n"))
+	is.True(strings.HasSuffix(out, "seg5.m4s\n#EXT-X-ENDLIST\n#EXT-X-DATERANGE:ID=\"late\",START-DATE=\"2025-01-01T12:00:13Z\"\n"))
+}
+
+func TestClipDiscontinuityInFront(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	c, err := p.Clip(16, 20, ClipOptions{})
+	is.NoErr(err)
+	is.Equal(c.Count(), uint(1))
+	is.Equal(c.DiscontinuitySeq, uint64(3)) // leading discontinuity is counted
+	is.True(!c.Segments[0].Discontinuity)
+	is.Equal(c.StartTime, 0.0)
+}
+
+func TestClipDateTime(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	start := time.Date(2025, 1, 1, 12, 0, 5, 0, time.UTC)
+	c, err := p.ClipDateTime(start, start.Add(4*time.Second), ClipOptions{SetStart: true})
+	is.NoErr(err)
+	is.Equal(c.Count(), uint(2))
+	is.Equal(c.Segments[0].URI, "seg1.m4s")
+	is.Equal(c.Map.URI, "init1.mp4")
+	is.Equal(c.StartTime, 1.0)
+
+	_, err = p.ClipDateTime(start.Add(time.Hour), start.Add(2*time.Hour), ClipOptions{})
+	is.True(errors.Is(err, ErrClipRangeEmpty)) // range after the playlist
+
+	np, _ := NewMediaPlaylist(0, 1)
+	is.NoErr(np.Append("a.ts", 4, ""))
+	np.Close()
+	_, err = np.ClipDateTime(start, start.Add(time.Second), ClipOptions{})
+	is.True(errors.Is(err, ErrNoProgramDateTime)) // no PDT in playlist
+}
+
+func TestClipStartBeforeFirstSegment(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	c, err := p.Clip(-5, 6, ClipOptions{SetStart: true})
+	is.NoErr(err)
+	is.Equal(c.Count(), uint(2))
+	is.Equal(c.StartTime, 0.0)                             // clamped to the first segment
+	is.True(!strings.Contains(c.String(), "#EXT-X-START")) // playback starts at the beginning anyway
+
+	start := time.Date(2025, 1, 1, 11, 59, 0, 0, time.UTC)
+	c, err = p.ClipDateTime(start, start.Add(time.Minute+time.Second), ClipOptions{SetStart: true})
+	is.NoErr(err)
+	is.Equal(c.Count(), uint(1))
+	is.Equal(c.StartTime, 0.0)
+}
+
+func TestClipEmptyRange(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	_, err := p.Clip(5, 5, ClipOptions{})
+	is.True(errors.Is(err, ErrClipRangeEmpty))
+	_, err = p.Clip(100, 200, ClipOptions{})
+	is.True(errors.Is(err, ErrClipRangeEmpty))
+}
+
+func TestClipDiscontinuityOnFirstSegment(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	p.Segments[0].Discontinuity = true
+	c, err := p.Clip(0, 4, ClipOptions{})
+	is.NoErr(err)
+	is.Equal(c.DiscontinuitySeq, uint64(2)) // not counted
+	is.True(c.Segments[0].Discontinuity)    // but kept
+}
+
+func TestClipNotVOD(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(3, 3)
+	is.NoErr(err)
+	is.NoErr(p.Append("a.ts", 4, ""))
+	_, err = p.Clip(0, 4, ClipOptions{})
+	is.True(errors.Is(err, ErrClipNotVOD)) // live playlist
+	_, err = p.ClipDateTime(time.Now(), time.Now().Add(time.Second), ClipOptions{})
+	is.True(errors.Is(err, ErrClipNotVOD))
+
+	p.MediaType = VOD
+	_, err = p.Clip(0, 4, ClipOptions{})
+	is.NoErr(err) // VOD playlist without EXT-X-ENDLIST yet
+}
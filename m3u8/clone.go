@@ -0,0 +1,217 @@
+package m3u8
+
+/*
+ This file defines functions for copying playlists.
+*/
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the media playlist, which can be changed without
+// affecting p, e.g. to rewrite its URIs per request. Custom tags and custom decoders
+// are shared, since they are provided by the user. The copy has its own cache.
+func (p *MediaPlaylist) Clone() *MediaPlaylist {
+	c := *p
+	c.buf = getBuffer()
+	mapCopies := make(map[*Map]*Map)
+	c.Map = cloneMap(p.Map, mapCopies)
+	c.Keys = cloneKeys(p.Keys)
+	c.StartUnknownAttrs = slices.Clone(p.StartUnknownAttrs)
+	c.UnknownTags = slices.Clone(p.UnknownTags)
+	c.TrailingUnknownTags = slices.Clone(p.TrailingUnknownTags)
+	c.Defines = slices.Clone(p.Defines)
+	c.DateRanges = cloneDateRanges(p.DateRanges)
+	c.TrailingDateRanges = cloneDateRanges(p.TrailingDateRanges)
+	c.Custom = maps.Clone(p.Custom)
+	c.customOrder = slices.Clone(p.customOrder)
+	if p.AllowCache != nil {
+		allowCache := *p.AllowCache
+		c.AllowCache = &allowCache
+	}
+	if p.ServerControl != nil {
+		sc := *p.ServerControl
+		sc.UnknownAttrs = slices.Clone(p.ServerControl.UnknownAttrs)
+		c.ServerControl = &sc
+	}
+	if p.keyRotator != nil {
+		rotator := *p.keyRotator
+		rotator.keys = cloneKeys(rotator.keys)
+		c.keyRotator = &rotator
+	}
+	if p.pdtClock != nil {
+		clock := *p.pdtClock
+		c.pdtClock = &clock
+	}
+	if p.PreloadHints != nil {
+		ph := *p.PreloadHints
+		ph.UnknownAttrs = slices.Clone(ph.UnknownAttrs)
+		c.PreloadHints = &ph
+	}
+	if p.PreloadMapHint != nil {
+		ph := *p.PreloadMapHint
+		ph.UnknownAttrs = slices.Clone(ph.UnknownAttrs)
+		c.PreloadMapHint = &ph
+	}
+	if p.PartialSegments != nil {
+		c.PartialSegments = make([]*PartialSegment, len(p.PartialSegments))
+		for i, ps := range p.PartialSegments {
+			n := *ps
+			n.UnknownAttrs = slices.Clone(ps.UnknownAttrs)
+			c.PartialSegments[i] = &n
+		}
+	}
+	// Copy slot by slot, so that head and tail of the ring buffer stay valid
+	c.Segments = getSegmentSlice(uint(len(p.Segments)))
+	for i, seg := range p.Segments {
+		if seg == nil {
+			c.Segments[i] = nil
+			continue
+		}
+		n := GetSegment()
+		*n = *seg
+		n.Keys = cloneKeys(seg.Keys)
+		n.UnknownTags = slices.Clone(seg.UnknownTags)
+		n.Map = cloneMap(seg.Map, mapCopies)
+		n.SCTE35DateRanges = cloneDateRanges(seg.SCTE35DateRanges)
+		n.Custom = maps.Clone(seg.Custom)
+		n.customOrder = slices.Clone(seg.customOrder)
+		if seg.SCTE != nil {
+			scte := *seg.SCTE
+			n.SCTE = &scte
+		}
+		c.Segments[i] = n
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the master playlist, which can be changed without
+// affecting p, e.g. to rewrite its URIs per request. The media playlists in
+// Variant.Chunklist, custom tags and custom decoders are shared.
+// The copy has its own cache.
+func (p *MasterPlaylist) Clone() *MasterPlaylist {
+	c := *p
+	c.buf = getBuffer()
+	c.Defines = slices.Clone(p.Defines)
+	c.Custom = maps.Clone(p.Custom)
+	c.customOrder = slices.Clone(p.customOrder)
+	c.StartUnknownAttrs = slices.Clone(p.StartUnknownAttrs)
+	c.UnknownTags = slices.Clone(p.UnknownTags)
+	c.TrailingUnknownTags = slices.Clone(p.TrailingUnknownTags)
+	if p.ContentSteering != nil {
+		cs := *p.ContentSteering
+		c.ContentSteering = &cs
+	}
+	if p.SessionDatas != nil {
+		c.SessionDatas = make([]*SessionData, len(p.SessionDatas))
+		for i, sd := range p.SessionDatas {
+			n := *sd
+			n.UnknownAttrs = slices.Clone(sd.UnknownAttrs)
+			c.SessionDatas[i] = &n
+		}
+	}
+	if p.SessionKeys != nil {
+		c.SessionKeys = make([]*Key, len(p.SessionKeys))
+		for i, key := range p.SessionKeys {
+			n := *key
+			n.UnknownAttrs = slices.Clone(key.UnknownAttrs)
+			c.SessionKeys[i] = &n
+		}
+	}
+	// Alternatives are shared between variants and groups, and should stay so in the copy
+	altCopies := make(map[*Alternative]*Alternative)
+	if p.Variants != nil {
+		c.Variants = make([]*Variant, len(p.Variants))
+		for i, v := range p.Variants {
+			n := *v
+			n.UnknownTags = slices.Clone(v.UnknownTags)
+			n.UnknownAttrs = slices.Clone(v.UnknownAttrs)
+			if v.ProgramId != nil {
+				programId := *v.ProgramId
+				n.ProgramId = &programId
+			}
+			if v.Alternatives != nil {
+				n.Alternatives = make([]*Alternative, len(v.Alternatives))
+				for j, alt := range v.Alternatives {
+					n.Alternatives[j] = cloneAlternative(alt, altCopies)
+				}
+			}
+			c.Variants[i] = &n
+		}
+	}
+	if p.RenditionGroups != nil {
+		c.RenditionGroups = make([]*RenditionGroup, len(p.RenditionGroups))
+		for i, g := range p.RenditionGroups {
+			n := *g
+			if g.Renditions != nil {
+				n.Renditions = make([]*Alternative, len(g.Renditions))
+				for j, alt := range g.Renditions {
+					n.Renditions[j] = cloneAlternative(alt, altCopies)
+				}
+			}
+			c.RenditionGroups[i] = &n
+		}
+	}
+	if p.renditionOrder != nil {
+		c.renditionOrder = make([]*Alternative, len(p.renditionOrder))
+		for i, alt := range p.renditionOrder {
+			c.renditionOrder[i] = cloneAlternative(alt, altCopies)
+		}
+	}
+	return &c
+}
+
+// cloneMap copies m, keeping maps that were shared in p shared in the copy.
+func cloneMap(m *Map, copies map[*Map]*Map) *Map {
+	if m == nil {
+		return nil
+	}
+	if n, ok := copies[m]; ok {
+		return n
+	}
+	n := *m
+	n.UnknownAttrs = slices.Clone(m.UnknownAttrs)
+	copies[m] = &n
+	return &n
+}
+
+func cloneAlternative(alt *Alternative, copies map[*Alternative]*Alternative) *Alternative {
+	if alt == nil {
+		return nil
+	}
+	if n, ok := copies[alt]; ok {
+		return n
+	}
+	n := *alt
+	n.UnknownAttrs = slices.Clone(alt.UnknownAttrs)
+	n.UnknownTags = slices.Clone(alt.UnknownTags)
+	if alt.Channels != nil {
+		ch := *alt.Channels
+		n.Channels = &ch
+	}
+	copies[alt] = &n
+	return &n
+}
+
+// cloneKeys copies keys including their unknown attributes.
+func cloneKeys(keys []Key) []Key {
+	out := slices.Clone(keys)
+	for i := range out {
+		out[i].UnknownAttrs = slices.Clone(out[i].UnknownAttrs)
+	}
+	return out
+}
+
+func cloneDateRanges(drs []*DateRange) []*DateRange {
+	if drs == nil {
+		return nil
+	}
+	out := make([]*DateRange, len(drs))
+	for i, dr := range drs {
+		n := *dr
+		n.XAttrs = slices.Clone(dr.XAttrs)
+		out[i] = &n
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package m3u8
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestMediaPlaylistClone(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-low-latency.m3u8")
+	is.NoErr(err) // must open file
+	p, _, err := DecodeFrom(bufio.NewReader(f), true)
+	is.NoErr(err) // must decode playlist
+	pl := p.(*MediaPlaylist)
+	want := pl.String()
+
+	c := pl.Clone()
+	is.Equal(c.String(), want) // clone must encode the same
+	c.RewriteURIs(func(_ URIKind, uri string) string { return "x/" + uri })
+	c.Segments[c.head].Discontinuity = true
+	c.PreloadHints.Type = "MAP"
+	is.True(c.String() != want)
+	pl.ResetCache()
+	is.Equal(pl.String(), want) // original must be unchanged
+	is.True(c.Segments[c.head] != pl.Segments[pl.head])
+
+	shared := &Map{URI: "init.mp4"}
+	pl.Map = shared
+	pl.Segments[pl.head].Map = shared
+	c = pl.Clone()
+	is.True(c.Map != shared)                 // map must be copied
+	is.True(c.Segments[c.head].Map == c.Map) // shared map stays shared
+}
+
+func TestMediaPlaylistCloneRingBuffer(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(3, 5)
+	is.NoErr(err) // must create playlist
+	for i := 0; i < 8; i++ {
+		p.Slide("seg.ts", 4, "")
+	}
+	c := p.Clone()
+	is.Equal(c.String(), p.String()) // wrapped ring buffer must encode the same
+	is.NoErr(c.Append("new.ts", 4, ""))
+	is.Equal(p.Count(), uint(3)) // original must keep its count
+}
+
+func TestMasterPlaylistClone(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-with-alternatives.m3u8")
+	is.NoErr(err) // must open file
+	p := NewMasterPlaylist()
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true)) // must decode playlist
+	want := p.String()
+
+	c := p.Clone()
+	is.Equal(c.String(), want) // clone must encode the same
+	c.RewriteURIs(func(_ URIKind, uri string) string { return "x/" + uri })
+	is.True(c.String() != want)
+	p.ResetCache()
+	is.Equal(p.String(), want) // original must be unchanged
+
+	alt := &Alternative{Type: "AUDIO", GroupId: "aac", Name: "English", URI: "audio.m3u8"}
+	p.Variants[0].Alternatives = append(p.Variants[0].Alternatives, alt)
+	p.Variants[1].Alternatives = append(p.Variants[1].Alternatives, alt)
+	c = p.Clone()
+	copied := c.Variants[0].Alternatives[len(c.Variants[0].Alternatives)-1]
+	is.True(copied != alt)                                                           // alternative must be copied
+	is.True(copied == c.Variants[1].Alternatives[len(c.Variants[1].Alternatives)-1]) // shared alternative stays shared
+}
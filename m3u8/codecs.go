@@ -0,0 +1,540 @@
+package m3u8
+
+/*
+ This file defines parsing and formatting of RFC 6381 codec strings used in the
+ CODECS and SUPPLEMENTAL-CODECS attributes.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCodec = errors.New("invalid codec string")
+
+// Sample entry types of codecs with typed parameters.
+const (
+	CodecAVC1         = "avc1"
+	CodecAVC3         = "avc3"
+	CodecHVC1         = "hvc1"
+	CodecHEV1         = "hev1"
+	CodecAV1          = "av01"
+	CodecVP9          = "vp09"
+	CodecDolbyVision  = "dvh1"
+	CodecDolbyVisionE = "dvhe"
+	CodecMP4A         = "mp4a"
+	CodecAC3          = "ac-3"
+	CodecEC3          = "ec-3"
+	CodecAC4          = "ac-4"
+	CodecOpus         = "Opus"
+	CodecFLAC         = "fLaC"
+	CodecSTPP         = "stpp"
+	CodecWVTT         = "wvtt"
+)
+
+// Transfer characteristics of AV1 and VP9 codec strings signaling HDR.
+const (
+	transferPQ  = 16 // SMPTE ST 2084
+	transferHLG = 18 // ARIB STD-B67
+)
+
+// CodecKind is the kind of media of a codec.
+type CodecKind uint
+
+const (
+	// use 0 for codecs of unknown kind
+	CodecKindVideo CodecKind = iota + 1
+	CodecKindAudio
+	CodecKindSubtitles
+)
+
+func (k CodecKind) String() string {
+	switch k {
+	case CodecKindVideo:
+		return "Video"
+	case CodecKindAudio:
+		return "Audio"
+	case CodecKindSubtitles:
+		return "Subtitles"
+	}
+	return "Unknown"
+}
+
+// AudioChannelClass is a coarse classification of the channel layout of audio.
+type AudioChannelClass uint
+
+const (
+	// use 0 when the channel layout is unknown
+	AudioMono AudioChannelClass = iota + 1
+	AudioStereo
+	AudioSurround  // more than two channels, such as 5.1 or 7.1
+	AudioImmersive // object-based or with height channels, such as Dolby Atmos
+)
+
+func (c AudioChannelClass) String() string {
+	switch c {
+	case AudioMono:
+		return "Mono"
+	case AudioStereo:
+		return "Stereo"
+	case AudioSurround:
+		return "Surround"
+	case AudioImmersive:
+		return "Immersive"
+	}
+	return "Unknown"
+}
+
+// Codec is a codec string of RFC 6381, such as avc1.64001f or mp4a.40.2.
+// Which fields are used depends on Type; the others are zero.
+// Codecs with other types are kept with their dot-separated parameters in Extra.
+type Codec struct {
+	Type                    string   // Sample entry type, e.g. avc1, hvc1, mp4a or ec-3
+	Profile                 int      // Profile, or MPEG-4 audio object type for mp4a.40, or AC-4 presentation version
+	ProfileSpace            int      // HEVC general_profile_space, 0-3
+	Compatibility           uint32   // AVC constraint set flags, HEVC profile compatibility flags
+	Level                   int      // Level as in the codec string, e.g. 31 for AVC 3.1 and 93 for HEVC 3.1
+	Tier                    string   // HEVC tier L or H, AV1 tier M or H
+	Constraints             []byte   // HEVC constraint indicator bytes
+	BitDepth                int      // Bit depth, from the profile for AVC, HEVC and Dolby Vision, if known
+	ColorInfo               bool     // AV1 and VP9 with the optional color parameters below
+	Monochrome              bool     // AV1 monochrome flag
+	ChromaSubsampling       string   // AV1 (3 digits) and VP9 (2 digits) chroma subsampling
+	ColorPrimaries          int      // AV1 and VP9 color primaries
+	TransferCharacteristics int      // AV1 and VP9 transfer characteristics
+	MatrixCoefficients      int      // AV1 and VP9 matrix coefficients
+	FullRange               bool     // AV1 and VP9 full range flag
+	ObjectType              int      // MPEG-4 object type indication of mp4a, 0x40 for MPEG-4 audio
+	Version                 int      // AC-4 bitstream version
+	MDCompat                int      // AC-4 mdcompat
+	Extra                   []string // Parameters of other codecs, e.g. ttml and im1t for stpp.ttml.im1t
+	Brands                  []string // Compatibility brands of SUPPLEMENTAL-CODECS, e.g. db1p in dvh1.08.07/db1p
+}
+
+// ParseCodecs parses a comma-separated list of codecs, such as the value of a CODECS
+// or SUPPLEMENTAL-CODECS attribute. An empty string gives no codecs.
+func ParseCodecs(s string) ([]Codec, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	codecs := make([]Codec, 0, len(parts))
+	for _, part := range parts {
+		c, err := ParseCodec(part)
+		if err != nil {
+			return nil, err
+		}
+		codecs = append(codecs, c)
+	}
+	return codecs, nil
+}
+
+// FormatCodecs formats codecs as a comma-separated list for a CODECS or
+// SUPPLEMENTAL-CODECS attribute.
+func FormatCodecs(codecs []Codec) string {
+	parts := make([]string, len(codecs))
+	for i, c := range codecs {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseCodec parses a single codec string. Compatibility brands after slashes are
+// stored in Brands. The sample entry type is matched case-insensitively.
+func ParseCodec(s string) (Codec, error) {
+	s = strings.TrimSpace(s)
+	brands := strings.Split(s, "/")
+	parts := strings.Split(brands[0], ".")
+	c := Codec{Type: parts[0]}
+	if len(brands) > 1 {
+		c.Brands = brands[1:]
+	}
+	if c.Type == "" {
+		return c, fmt.Errorf("%w: %q", ErrInvalidCodec, s)
+	}
+	for _, t := range []string{CodecAVC1, CodecAVC3, CodecHVC1, CodecHEV1, CodecAV1, CodecVP9, CodecDolbyVision,
+		CodecDolbyVisionE, CodecMP4A, CodecAC3, CodecEC3, CodecAC4, CodecOpus, CodecFLAC, CodecSTPP, CodecWVTT} {
+		if strings.EqualFold(c.Type, t) {
+			c.Type = t
+		}
+	}
+	params := parts[1:]
+	var err error
+	switch c.Type {
+	case CodecAVC1, CodecAVC3:
+		err = c.parseAVC(params)
+	case CodecHVC1, CodecHEV1:
+		err = c.parseHEVC(params)
+	case CodecAV1:
+		err = c.parseAV1(params)
+	case CodecVP9:
+		err = c.parseVP9(params)
+	case CodecDolbyVision, CodecDolbyVisionE:
+		err = c.parseDolbyVision(params)
+	case CodecMP4A:
+		err = c.parseMP4A(params)
+	case CodecAC4:
+		err = c.parseAC4(params)
+	case CodecAC3, CodecEC3, CodecOpus, CodecFLAC, CodecWVTT:
+		if len(params) > 0 {
+			err = errors.New("unexpected parameters")
+		}
+	default:
+		if len(params) > 0 {
+			c.Extra = params
+		}
+	}
+	if err != nil {
+		return c, fmt.Errorf("%w: %q: %w", ErrInvalidCodec, s, err)
+	}
+	return c, nil
+}
+
+// parseAVC parses avc1.PPCCLL with hexadecimal profile, constraint flags and level.
+func (c *Codec) parseAVC(params []string) error {
+	if len(params) != 1 || len(params[0]) != 6 {
+		return errors.New("want PPCCLL")
+	}
+	v, err := strconv.ParseUint(params[0], 16, 32)
+	if err != nil {
+		return err
+	}
+	c.Profile = int(v >> 16)
+	c.Compatibility = uint32(v>>8) & 0xff
+	c.Level = int(v & 0xff)
+	switch c.Profile {
+	case 110, 122:
+		c.BitDepth = 10
+	case 244:
+		// up to 14 bits
+	default:
+		c.BitDepth = 8
+	}
+	return nil
+}
+
+// parseHEVC parses hvc1.[A-C]P.CC.[LH]L[.XX...] of ISO/IEC 14496-15.
+func (c *Codec) parseHEVC(params []string) error {
+	if len(params) < 3 || len(params) > 9 {
+		return errors.New("want profile, compatibility, tier and level")
+	}
+	profile := params[0]
+	if profile != "" && profile[0] >= 'A' && profile[0] <= 'C' {
+		c.ProfileSpace = int(profile[0]-'A') + 1
+		profile = profile[1:]
+	}
+	var err error
+	if c.Profile, err = strconv.Atoi(profile); err != nil {
+		return err
+	}
+	compat, err := strconv.ParseUint(params[1], 16, 32)
+	if err != nil {
+		return err
+	}
+	c.Compatibility = uint32(compat)
+	if params[2] == "" || (params[2][0] != 'L' && params[2][0] != 'H') {
+		return errors.New("tier must be L or H")
+	}
+	c.Tier = params[2][:1]
+	if c.Level, err = strconv.Atoi(params[2][1:]); err != nil {
+		return err
+	}
+	for _, p := range params[3:] {
+		b, err := strconv.ParseUint(p, 16, 8)
+		if err != nil {
+			return err
+		}
+		c.Constraints = append(c.Constraints, byte(b))
+	}
+	switch c.Profile {
+	case 1:
+		c.BitDepth = 8
+	case 2:
+		c.BitDepth = 10
+	}
+	return nil
+}
+
+// parseAV1 parses av01.P.LLT.DD[.M.CCC.cp.tc.mc.F] of the AV1 ISOBMFF binding.
+func (c *Codec) parseAV1(params []string) error {
+	if len(params) != 3 && len(params) != 9 {
+		return errors.New("want 3 or 9 parameters")
+	}
+	var err error
+	if c.Profile, err = strconv.Atoi(params[0]); err != nil {
+		return err
+	}
+	lt := params[1]
+	if len(lt) != 3 || (lt[2] != 'M' && lt[2] != 'H') {
+		return errors.New("want level and tier M or H")
+	}
+	c.Tier = lt[2:]
+	if c.Level, err = strconv.Atoi(lt[:2]); err != nil {
+		return err
+	}
+	if c.BitDepth, err = strconv.Atoi(params[2]); err != nil {
+		return err
+	}
+	if len(params) == 3 {
+		return nil
+	}
+	c.ColorInfo = true
+	c.Monochrome = params[3] == "1"
+	c.ChromaSubsampling = params[4]
+	if err = c.parseColor(params[5:8]); err != nil {
+		return err
+	}
+	c.FullRange = params[8] == "1"
+	return nil
+}
+
+// parseVP9 parses vp09.PP.LL.DD[.CC[.cp[.tc[.mc[.FF]]]]] of the VP9 ISOBMFF binding.
+// Missing color parameters get their default values 01.01.01.01.00, so a codec with
+// some of them formats with all of them.
+func (c *Codec) parseVP9(params []string) error {
+	if len(params) < 3 || len(params) > 8 {
+		return errors.New("want 3 to 8 parameters")
+	}
+	var err error
+	if c.Profile, err = strconv.Atoi(params[0]); err != nil {
+		return err
+	}
+	if c.Level, err = strconv.Atoi(params[1]); err != nil {
+		return err
+	}
+	if c.BitDepth, err = strconv.Atoi(params[2]); err != nil {
+		return err
+	}
+	if len(params) == 3 {
+		return nil
+	}
+	color := append(params[3:len(params):len(params)], vp9ColorDefaults[len(params)-3:]...)
+	c.ColorInfo = true
+	c.ChromaSubsampling = color[0]
+	if err = c.parseColor(color[1:4]); err != nil {
+		return err
+	}
+	c.FullRange = strings.TrimLeft(color[4], "0") == "1"
+	return nil
+}
+
+// vp9ColorDefaults are the default values of the optional VP9 color parameters.
+var vp9ColorDefaults = []string{"01", "01", "01", "01", "00"}
+
+// parseColor parses color primaries, transfer characteristics and matrix coefficients.
+func (c *Codec) parseColor(params []string) error {
+	var err error
+	if c.ColorPrimaries, err = strconv.Atoi(params[0]); err != nil {
+		return err
+	}
+	if c.TransferCharacteristics, err = strconv.Atoi(params[1]); err != nil {
+		return err
+	}
+	c.MatrixCoefficients, err = strconv.Atoi(params[2])
+	return err
+}
+
+// parseDolbyVision parses dvh1.PP.LL with decimal profile and level.
+func (c *Codec) parseDolbyVision(params []string) error {
+	if len(params) != 2 {
+		return errors.New("want profile and level")
+	}
+	var err error
+	if c.Profile, err = strconv.Atoi(params[0]); err != nil {
+		return err
+	}
+	if c.Level, err = strconv.Atoi(params[1]); err != nil {
+		return err
+	}
+	c.BitDepth = 10
+	return nil
+}
+
+// parseMP4A parses mp4a.OO[.A] with hexadecimal object type indication and
+// decimal audio object type.
+func (c *Codec) parseMP4A(params []string) error {
+	if len(params) < 1 || len(params) > 2 {
+		return errors.New("want object type")
+	}
+	oti, err := strconv.ParseUint(params[0], 16, 8)
+	if err != nil {
+		return err
+	}
+	c.ObjectType = int(oti)
+	if len(params) == 2 {
+		if c.Profile, err = strconv.Atoi(params[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseAC4 parses ac-4.VV.PP.MM with bitstream version, presentation version and mdcompat.
+func (c *Codec) parseAC4(params []string) error {
+	if len(params) != 3 {
+		return errors.New("want bitstream version, presentation version and mdcompat")
+	}
+	var err error
+	if c.Version, err = strconv.Atoi(params[0]); err != nil {
+		return err
+	}
+	if c.Profile, err = strconv.Atoi(params[1]); err != nil {
+		return err
+	}
+	c.MDCompat, err = strconv.Atoi(params[2])
+	return err
+}
+
+// String formats the codec as an RFC 6381 codec string.
+func (c Codec) String() string {
+	var sb strings.Builder
+	sb.WriteString(c.Type)
+	switch c.Type {
+	case CodecAVC1, CodecAVC3:
+		fmt.Fprintf(&sb, ".%02x%02x%02x", c.Profile, c.Compatibility, c.Level)
+	case CodecHVC1, CodecHEV1:
+		sb.WriteByte('.')
+		if c.ProfileSpace > 0 {
+			sb.WriteByte(byte('A' + c.ProfileSpace - 1))
+		}
+		fmt.Fprintf(&sb, "%d.%X.%s%d", c.Profile, c.Compatibility, c.Tier, c.Level)
+		for _, b := range c.Constraints {
+			fmt.Fprintf(&sb, ".%02X", b)
+		}
+	case CodecAV1:
+		fmt.Fprintf(&sb, ".%d.%02d%s.%02d", c.Profile, c.Level, c.Tier, c.BitDepth)
+		if c.ColorInfo {
+			fmt.Fprintf(&sb, ".%d.%s.%02d.%02d.%02d.%d", boolToInt(c.Monochrome), c.ChromaSubsampling,
+				c.ColorPrimaries, c.TransferCharacteristics, c.MatrixCoefficients, boolToInt(c.FullRange))
+		}
+	case CodecVP9:
+		fmt.Fprintf(&sb, ".%02d.%02d.%02d", c.Profile, c.Level, c.BitDepth)
+		if c.ColorInfo {
+			fmt.Fprintf(&sb, ".%s.%02d.%02d.%02d.%02d", c.ChromaSubsampling, c.ColorPrimaries,
+				c.TransferCharacteristics, c.MatrixCoefficients, boolToInt(c.FullRange))
+		}
+	case CodecDolbyVision, CodecDolbyVisionE:
+		fmt.Fprintf(&sb, ".%02d.%02d", c.Profile, c.Level)
+	case CodecMP4A:
+		fmt.Fprintf(&sb, ".%02X", c.ObjectType)
+		if c.Profile > 0 {
+			fmt.Fprintf(&sb, ".%d", c.Profile)
+		}
+	case CodecAC4:
+		fmt.Fprintf(&sb, ".%02d.%02d.%02d", c.Version, c.Profile, c.MDCompat)
+	default:
+		for _, p := range c.Extra {
+			sb.WriteByte('.')
+			sb.WriteString(p)
+		}
+	}
+	for _, b := range c.Brands {
+		sb.WriteByte('/')
+		sb.WriteString(b)
+	}
+	return sb.String()
+}
+
+// Kind returns the kind of media of the codec.
+func (c Codec) Kind() CodecKind {
+	switch c.Type {
+	case CodecAVC1, CodecAVC3, CodecHVC1, CodecHEV1, CodecAV1, CodecVP9, CodecDolbyVision, CodecDolbyVisionE:
+		return CodecKindVideo
+	case CodecMP4A, CodecAC3, CodecEC3, CodecAC4, CodecOpus, CodecFLAC:
+		return CodecKindAudio
+	case CodecSTPP, CodecWVTT:
+		return CodecKindSubtitles
+	}
+	return 0
+}
+
+// IsAVC tells if the codec is H.264/AVC.
+func (c Codec) IsAVC() bool {
+	return c.Type == CodecAVC1 || c.Type == CodecAVC3
+}
+
+// IsHEVC tells if the codec is H.265/HEVC, including Dolby Vision based on HEVC.
+func (c Codec) IsHEVC() bool {
+	return c.Type == CodecHVC1 || c.Type == CodecHEV1 || c.IsDolbyVision()
+}
+
+// IsDolbyVision tells if the codec is Dolby Vision.
+func (c Codec) IsDolbyVision() bool {
+	return c.Type == CodecDolbyVision || c.Type == CodecDolbyVisionE
+}
+
+// IsHDR tells if the codec string signals HDR, i.e. Dolby Vision or AV1 and VP9 with
+// PQ or HLG transfer characteristics. HDR with HEVC is only signaled by VIDEO-RANGE,
+// see VariantParams.IsHDR.
+func (c Codec) IsHDR() bool {
+	if c.IsDolbyVision() {
+		return true
+	}
+	return c.ColorInfo && (c.TransferCharacteristics == transferPQ || c.TransferCharacteristics == transferHLG)
+}
+
+// AudioChannelClass returns the channel class implied by an audio codec, or 0 if it
+// does not imply one. Only HE-AAC v2 implies a class, since it is always stereo.
+// Use Channels.Class for the class of a rendition.
+func (c Codec) AudioChannelClass() AudioChannelClass {
+	if c.Type == CodecMP4A && c.ObjectType == 0x40 && c.Profile == 29 {
+		return AudioStereo
+	}
+	return 0
+}
+
+// Class returns the channel class of the CHANNELS attribute of an audio rendition.
+// Spatial audio identifiers, such as JOC for Dolby Atmos, make the audio immersive.
+func (ch *Channels) Class() AudioChannelClass {
+	switch {
+	case ch == nil || ch.Amount <= 0:
+		return 0
+	case ch.SpatialAudioIdentifiers != "":
+		return AudioImmersive
+	case ch.Amount == 1:
+		return AudioMono
+	case ch.Amount == 2:
+		return AudioStereo
+	}
+	return AudioSurround
+}
+
+// GetCodecs parses the CODECS attribute of the variant.
+func (vp *VariantParams) GetCodecs() ([]Codec, error) {
+	return ParseCodecs(vp.Codecs)
+}
+
+// GetSupplementalCodecs parses the SUPPLEMENTAL-CODECS attribute of the variant.
+func (vp *VariantParams) GetSupplementalCodecs() ([]Codec, error) {
+	return ParseCodecs(vp.SupplementalCodecs)
+}
+
+// SetCodecs sets the CODECS attribute of the variant.
+func (vp *VariantParams) SetCodecs(codecs []Codec) {
+	vp.Codecs = FormatCodecs(codecs)
+}
+
+// IsHDR tells if the variant is HDR, i.e. has VIDEO-RANGE PQ or HLG, or a codec in
+// CODECS or SUPPLEMENTAL-CODECS signaling HDR. Codecs that cannot be parsed are ignored.
+func (vp *VariantParams) IsHDR() bool {
+	if vp.VideoRange == "PQ" || vp.VideoRange == "HLG" {
+		return true
+	}
+	for _, s := range []string{vp.Codecs, vp.SupplementalCodecs} {
+		for _, part := range strings.Split(s, ",") {
+			if c, err := ParseCodec(part); err == nil && c.IsHDR() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+package m3u8
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseCodec(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Codec
+		kind CodecKind
+	}{
+		{"avc1.64001f", Codec{Type: CodecAVC1, Profile: 100, Level: 31, BitDepth: 8}, CodecKindVideo},
+		{"avc3.4d4028", Codec{Type: CodecAVC3, Profile: 77, Compatibility: 0x40, Level: 40, BitDepth: 8},
+			CodecKindVideo},
+		{"hvc1.2.4.L153.B0", Codec{Type: CodecHVC1, Profile: 2, Compatibility: 4, Tier: "L", Level: 153,
+			Constraints: []byte{0xb0}, BitDepth: 10}, CodecKindVideo},
+		{"hev1.A1.6.H120.90.00", Codec{Type: CodecHEV1, ProfileSpace: 1, Profile: 1, Compatibility: 6, Tier: "H",
+			Level: 120, Constraints: []byte{0x90, 0}, BitDepth: 8}, CodecKindVideo},
+		{"av01.0.04M.10", Codec{Type: CodecAV1, Level: 4, Tier: "M", BitDepth: 10}, CodecKindVideo},
+		{"av01.0.13M.10.0.110.09.16.09.0", Codec{Type: CodecAV1, Level: 13, Tier: "M", BitDepth: 10,
+			ColorInfo: true, ChromaSubsampling: "110", ColorPrimaries: 9, TransferCharacteristics: 16,
+			MatrixCoefficients: 9}, CodecKindVideo},
+		{"vp09.02.10.10.01.09.16.09.01", Codec{Type: CodecVP9, Profile: 2, Level: 10, BitDepth: 10,
+			ColorInfo: true, ChromaSubsampling: "01", ColorPrimaries: 9, TransferCharacteristics: 16,
+			MatrixCoefficients: 9, FullRange: true}, CodecKindVideo},
+		{"dvh1.08.07/db4h", Codec{Type: CodecDolbyVision, Profile: 8, Level: 7, BitDepth: 10,
+			Brands: []string{"db4h"}}, CodecKindVideo},
+		{"dvhe.05.06", Codec{Type: CodecDolbyVisionE, Profile: 5, Level: 6, BitDepth: 10}, CodecKindVideo},
+		{"mp4a.40.2", Codec{Type: CodecMP4A, ObjectType: 0x40, Profile: 2}, CodecKindAudio},
+		{"mp4a.6B", Codec{Type: CodecMP4A, ObjectType: 0x6b}, CodecKindAudio},
+		{"ac-3", Codec{Type: CodecAC3}, CodecKindAudio},
+		{"ec-3", Codec{Type: CodecEC3}, CodecKindAudio},
+		{"ac-4.02.01.03", Codec{Type: CodecAC4, Version: 2, Profile: 1, MDCompat: 3}, CodecKindAudio},
+		{"Opus", Codec{Type: CodecOpus}, CodecKindAudio},
+		{"fLaC", Codec{Type: CodecFLAC}, CodecKindAudio},
+		{"stpp.ttml.im1t", Codec{Type: CodecSTPP, Extra: []string{"ttml", "im1t"}}, CodecKindSubtitles},
+		{"wvtt", Codec{Type: CodecWVTT}, CodecKindSubtitles},
+		{"xyz1.2.3", Codec{Type: "xyz1", Extra: []string{"2", "3"}}, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			is := is.New(t)
+			got, err := ParseCodec(c.in)
+			is.NoErr(err)                // codec must parse
+			is.Equal(got, c.want)        // typed codec
+			is.Equal(got.Kind(), c.kind) // kind of codec
+			is.Equal(got.String(), c.in) // codec must format as parsed
+		})
+	}
+}
+
+func TestParseCodecVP9Defaults(t *testing.T) {
+	is := is.New(t)
+	c, err := ParseCodec("vp09.02.10.10.01")
+	is.NoErr(err) // codec with some color parameters must parse
+	is.Equal(c, Codec{Type: CodecVP9, Profile: 2, Level: 10, BitDepth: 10, ColorInfo: true,
+		ChromaSubsampling: "01", ColorPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1})
+	is.Equal(c.String(), "vp09.02.10.10.01.01.01.01.00") // missing parameters must get defaults
+	c, err = ParseCodec("vp09.02.10.10.01.09.16")
+	is.NoErr(err)
+	is.True(c.IsHDR()) // transfer characteristics must be kept
+	is.Equal(c.MatrixCoefficients, 1)
+}
+
+func TestParseCodecErrors(t *testing.T) {
+	for _, in := range []string{"", "avc1", "avc1.64001", "avc1.zz001f", "hvc1.2.4", "hvc1.2.4.X153",
+		"av01.0.04X.10", "av01.0.04M.10.0", "vp09.02.10", "vp09.02.10.10.01.01.01.01.00.0", "dvh1.08", "mp4a", "ac-3.1", "ac-4.02.01"} {
+		t.Run(in, func(t *testing.T) {
+			is := is.New(t)
+			_, err := ParseCodec(in)
+			is.True(errors.Is(err, ErrInvalidCodec)) // codec must be rejected
+		})
+	}
+}
+
+func TestParseCodecs(t *testing.T) {
+	is := is.New(t)
+	codecs, err := ParseCodecs("avc1.640028, mp4a.40.2")
+	is.NoErr(err)
+	is.Equal(len(codecs), 2)
+	is.Equal(FormatCodecs(codecs), "avc1.640028,mp4a.40.2")
+	codecs, err = ParseCodecs("")
+	is.NoErr(err)
+	is.Equal(len(codecs), 0)
+	_, err = ParseCodecs("avc1.640028,mp4a")
+	is.True(errors.Is(err, ErrInvalidCodec))
+
+	c, err := ParseCodec("AVC1.4D401F")
+	is.NoErr(err)
+	is.Equal(c.String(), "avc1.4d401f") // type and hex digits are normalized
+}
+
+func TestCodecHelpers(t *testing.T) {
+	is := is.New(t)
+	parse := func(s string) Codec {
+		c, err := ParseCodec(s)
+		is.NoErr(err)
+		return c
+	}
+	is.True(parse("hvc1.2.4.L153.B0").IsHEVC())
+	is.True(parse("dvh1.08.07").IsHEVC())
+	is.True(!parse("avc1.64001f").IsHEVC())
+	is.True(parse("avc3.64001f").IsAVC())
+	is.True(parse("dvh1.08.07").IsHDR())
+	is.True(!parse("hvc1.2.4.L153.B0").IsHDR()) // HEVC HDR is only signaled by VIDEO-RANGE
+	is.True(parse("av01.0.13M.10.0.110.09.16.09.0").IsHDR())
+	is.True(parse("vp09.02.10.10.01.09.18.09.00").IsHDR())
+	is.True(!parse("vp09.00.10.08.01.01.01.01.00").IsHDR())
+
+	is.Equal(parse("mp4a.40.29").AudioChannelClass(), AudioStereo)
+	is.Equal(parse("ec-3").AudioChannelClass(), AudioChannelClass(0)) // channels are not implied
+	is.Equal(parse("ac-4.02.01.03").AudioChannelClass(), AudioChannelClass(0))
+	is.Equal(parse("mp4a.40.2").AudioChannelClass(), AudioChannelClass(0))
+	is.Equal(AudioSurround.String(), "Surround")
+
+	is.Equal((&Channels{Amount: 1}).Class(), AudioMono)
+	is.Equal((&Channels{Amount: 2}).Class(), AudioStereo)
+	is.Equal((&Channels{Amount: 6}).Class(), AudioSurround)
+	is.Equal((&Channels{Amount: 16, SpatialAudioIdentifiers: "JOC"}).Class(), AudioImmersive)
+	is.Equal((*Channels)(nil).Class(), AudioChannelClass(0))
+}
+
+func TestVariantParamsCodecs(t *testing.T) {
+	is := is.New(t)
+	vp := VariantParams{Codecs: "hvc1.2.4.L153.B0,mp4a.40.2", SupplementalCodecs: "dvh1.08.07/db4h"}
+	codecs, err := vp.GetCodecs()
+	is.NoErr(err)
+	is.True(codecs[0].IsHEVC())
+	supplemental, err := vp.GetSupplementalCodecs()
+	is.NoErr(err)
+	is.Equal(supplemental[0].Brands, []string{"db4h"})
+	is.True(vp.IsHDR()) // Dolby Vision in SUPPLEMENTAL-CODECS
+
+	vp = VariantParams{Codecs: "hvc1.2.4.L153.B0", VideoRange: "PQ"}
+	is.True(vp.IsHDR())
+	vp.VideoRange = "SDR"
+	is.True(!vp.IsHDR())
+
+	vp.SetCodecs([]Codec{{Type: CodecAVC1, Profile: 100, Level: 40}, {Type: CodecMP4A, ObjectType: 0x40, Profile: 2}})
+	is.Equal(vp.Codecs, "avc1.640028,mp4a.40.2")
+}
@@ -0,0 +1,170 @@
+package m3u8
+
+/*
+ This file defines functions for concatenating VOD media playlists.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+var ErrConcatMismatch = errors.New("media playlists cannot be concatenated")
+
+// ConcatMediaPlaylists returns a new media playlist with the segments of all playlists
+// in order, e.g. to stitch pre-roll, main content and post-roll VOD playlists.
+// The playlists are not changed.
+//
+// An EXT-X-DISCONTINUITY is inserted at every boundary between playlists, and EXT-X-MAP
+// and EXT-X-KEY tags are emitted again where they change. A playlist without keys
+// following an encrypted one gets METHOD=NONE. Byte ranges keep their absolute offsets,
+// so the offset is written explicitly when a range does not continue the previous one.
+//
+// EXT-X-DATERANGE tags are merged by ID, and SCTE-35 date ranges after the last segment of
+// a playlist are moved in front of the first segment of the next one. Sequence numbers
+// start at EXT-X-MEDIA-SEQUENCE and EXT-X-DISCONTINUITY-SEQUENCE of the first playlist.
+// The target duration is recalculated, and the version is the highest of CalcMinVersion
+// and the versions of the playlists. The result is closed and of type VOD if all playlists are.
+//
+// ErrConcatMismatch is returned if the playlists mix I-frame and normal playlists, if a
+// playlist without EXT-X-MAP follows one with EXT-X-MAP, or if date ranges with the
+// same ID differ.
+func ConcatMediaPlaylists(playlists ...*MediaPlaylist) (*MediaPlaylist, error) {
+	if len(playlists) == 0 {
+		return nil, ErrPlaylistEmpty
+	}
+	var total uint
+	for _, pl := range playlists {
+		total += pl.Count()
+	}
+	if total == 0 {
+		return nil, ErrPlaylistEmpty
+	}
+	first := playlists[0]
+	p, err := NewMediaPlaylist(0, total)
+	if err != nil {
+		return nil, err
+	}
+	p.SeqNo = first.SeqNo
+	p.SegmentIndexing.NextMSNIndex = first.SeqNo
+	p.DiscontinuitySeq = first.DiscontinuitySeq
+	p.Iframe = first.Iframe
+	p.StartTime = first.StartTime
+	p.StartTimePrecise = first.StartTimePrecise
+	p.writePrecision = first.writePrecision
+	p.independentSegments = true
+	p.Closed = true
+	p.MediaType = VOD
+
+	var (
+		curMap           *Map  // map in effect at the end of the output so far
+		curKeys          []Key // keys in effect at the end of the output so far
+		pendingDateRange []*DateRange
+		mapCopies        = make(map[*Map]*Map)
+	)
+	dateRangeIDs := make(map[string]*DateRange)
+	for i, pl := range playlists {
+		if pl.Iframe != p.Iframe {
+			return nil, fmt.Errorf("playlist %d: I-frame and normal playlists mixed: %w", i, ErrConcatMismatch)
+		}
+		p.independentSegments = p.independentSegments && pl.IndependentSegments()
+		p.Closed = p.Closed && pl.Closed
+		if pl.MediaType != VOD {
+			p.MediaType = 0
+		}
+		updateVersion(&p.ver, pl.ver)
+		for _, d := range pl.Defines {
+			if !slices.Contains(p.Defines, d) {
+				p.Defines = append(p.Defines, d)
+			}
+		}
+		// tags repeated within a playlist are kept, tags of earlier playlists are not repeated
+		seen := len(p.customOrder)
+		for _, tag := range pl.CustomTags() {
+			if !slices.ContainsFunc(p.customOrder[:seen], func(c CustomTag) bool {
+				return c.TagName() == tag.TagName() && c.String() == tag.String()
+			}) {
+				p.AppendCustomTag(tag)
+			}
+		}
+		for _, dr := range pl.DateRanges {
+			if prev, ok := dateRangeIDs[dr.ID]; ok {
+				if !reflect.DeepEqual(prev, dr) {
+					return nil, fmt.Errorf("playlist %d: EXT-X-DATERANGE %q differs: %w", i, dr.ID, ErrConcatMismatch)
+				}
+				continue
+			}
+			dateRangeIDs[dr.ID] = dr
+			p.DateRanges = append(p.DateRanges, cloneDateRanges([]*DateRange{dr})[0])
+		}
+
+		plMap := pl.Map
+		plKeys := pl.Keys
+		for j, seg := range pl.GetAllSegments() {
+			if seg == nil {
+				continue
+			}
+			n := GetSegment()
+			*n = *seg
+			n.Keys = slices.Clone(seg.Keys)
+			n.Map = nil // set below where the map changes
+			n.SCTE35DateRanges = cloneDateRanges(seg.SCTE35DateRanges)
+			n.Custom = maps.Clone(seg.Custom)
+			n.customOrder = slices.Clone(seg.customOrder)
+			if seg.SCTE != nil {
+				scte := *seg.SCTE
+				n.SCTE = &scte
+			}
+			if seg.Map != nil {
+				plMap = seg.Map
+			}
+			if len(seg.Keys) > 0 {
+				plKeys = seg.Keys
+			}
+			if j == 0 {
+				if p.count > 0 {
+					n.Discontinuity = true
+				}
+				// Emit the state of this playlist again, since the previous one may have changed it
+				if plMap == nil && curMap != nil {
+					return nil, fmt.Errorf("playlist %d: no EXT-X-MAP after a playlist with EXT-X-MAP: %w",
+						i, ErrConcatMismatch)
+				}
+				if len(plKeys) == 0 && len(curKeys) > 0 {
+					plKeys = []Key{{Method: "NONE"}}
+				}
+				if len(plKeys) > 0 && !keysEqual(plKeys, curKeys) {
+					n.Keys = slices.Clone(plKeys)
+				}
+				n.SCTE35DateRanges = append(pendingDateRange, n.SCTE35DateRanges...)
+				pendingDateRange = nil
+			}
+			if plMap != nil && !plMap.Equal(curMap) {
+				n.Map = cloneMap(plMap, mapCopies)
+			}
+			curMap = plMap
+			if len(n.Keys) > 0 {
+				curKeys = n.Keys
+			}
+			if p.count == 0 {
+				p.Map = n.Map
+				p.Keys = slices.Clone(n.Keys)
+			}
+			if err := p.AppendSegment(n); err != nil {
+				return nil, err
+			}
+		}
+		pendingDateRange = append(pendingDateRange, cloneDateRanges(pl.TrailingDateRanges)...)
+	}
+	for _, dr := range pendingDateRange {
+		p.AppendTrailingDateRange(dr)
+	}
+
+	ver, _ := p.CalcMinVersion()
+	updateVersion(&p.ver, ver)
+	p.TargetDuration = p.CalculateTargetDuration(p.ver)
+	return p, nil
+}
@@ -0,0 +1,151 @@
+package m3u8
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func newConcatTestPlaylist(t *testing.T, prefix string, nrSegs int, dur float64) *MediaPlaylist {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, uint(nrSegs))
+	is.NoErr(err) // must create playlist
+	for i := 0; i < nrSegs; i++ {
+		is.NoErr(p.Append(prefix+string(rune('0'+i))+".ts", dur, ""))
+	}
+	p.MediaType = VOD
+	p.Close()
+	return p
+}
+
+func TestConcatMediaPlaylists(t *testing.T) {
+	is := is.New(t)
+	pre := newConcatTestPlaylist(t, "pre", 2, 4)
+	main := newConcatTestPlaylist(t, "main", 3, 6.2)
+	is.NoErr(main.SetDefaultKey("AES-128", "key1", "", "", ""))
+	main.SeqNo = 100
+	post := newConcatTestPlaylist(t, "post", 1, 4)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pre.DateRanges = []*DateRange{{ID: "a", StartDate: start}}
+	main.DateRanges = []*DateRange{{ID: "a", StartDate: start}, {ID: "b", StartDate: start}}
+	main.AppendTrailingDateRange(&DateRange{ID: "scte", StartDate: start, SCTE35Out: "0xFC30"})
+
+	p, err := ConcatMediaPlaylists(pre, main, post)
+	is.NoErr(err)
+	is.Equal(p.Count(), uint(6))
+	is.Equal(p.TargetDuration, uint(7))
+	is.True(p.Closed)
+	is.Equal(p.MediaType, VOD)
+	is.Equal(len(p.DateRanges), 2)                   // date ranges merged by ID
+	is.Equal(len(p.Segments[5].SCTE35DateRanges), 1) // trailing date range moved to next playlist
+	is.Equal(len(p.TrailingDateRanges), 0)           // no trailing date range left
+	is.Equal(p.Segments[5].SeqId, uint64(5))         // sequence numbers are consecutive
+	is.Equal(len(main.Segments[0].Keys), 0)          // input must not change
+	is.Equal(strings.Count(pre.String(), "DISCONTINUITY"), 0)
+
+	want := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:7
+#EXTINF:4.000,
+pre0.ts
+#EXTINF:4.000,
+pre1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=AES-128,URI="key1"
+#EXTINF:6.200,
+main0.ts
+#EXTINF:6.200,
+main1.ts
+#EXTINF:6.200,
+main2.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-DATERANGE:ID="scte",START-DATE="2025-01-01T00:00:00Z",SCTE35-OUT=0xFC30
+#EXT-X-KEY:METHOD=NONE
+#EXTINF:4.000,
+post0.ts
+#EXT-X-ENDLIST
+#EXT-X-DATERANGE:ID="a",START-DATE="2025-01-01T00:00:00Z"
+#EXT-X-DATERANGE:ID="b",START-DATE="2025-01-01T00:00:00Z"
+`
+	is.Equal(p.String(), want)
+}
+
+func TestConcatMediaPlaylistsMapsAndByteRanges(t *testing.T) {
+	is := is.New(t)
+	a := newConcatTestPlaylist(t, "a", 2, 4)
+	a.SetDefaultMap("initA.mp4", 0, 0)
+	b := newConcatTestPlaylist(t, "b", 1, 4)
+	b.SetDefaultMap("initB.mp4", 0, 0)
+	c := newConcatTestPlaylist(t, "c", 2, 4)
+	c.SetDefaultMap("initA.mp4", 0, 0)
+	for _, pl := range []*MediaPlaylist{a, c} {
+		// both playlists are byte ranges of the same file
+		for i, seg := range pl.GetAllSegments() {
+			seg.URI = "main.mp4"
+			seg.Limit = 100
+			seg.Offset = int64(i * 100)
+		}
+	}
+
+	p, err := ConcatMediaPlaylists(a, b, c)
+	is.NoErr(err)
+	out := p.String()
+	is.Equal(strings.Count(out, "#EXT-X-MAP:"), 3)
+	is.True(strings.Contains(out, "#EXT-X-MAP:URI=\"initA.mp4\"\n#EXTINF:4.000,\nmain.mp4\n") ||
+		strings.Contains(out, "#EXT-X-MAP:URI=\"initA.mp4\"\n#EXT-X-BYTERANGE:100@0\n"))
+	is.Equal(strings.Count(out, "#EXT-X-BYTERANGE:100@0\n"), 2) // restart of the range needs an offset
+	is.Equal(strings.Count(out, "#EXT-X-BYTERANGE:100\n"), 2)   // continuations omit the offset
+	is.Equal(p.Version(), uint8(6))                             // EXT-X-MAP and byte ranges
+
+	d := newConcatTestPlaylist(t, "d", 1, 4)
+	_, err = ConcatMediaPlaylists(a, d)
+	is.True(errors.Is(err, ErrConcatMismatch)) // no map after a map
+	d.SetIframeOnly()
+	d.SetDefaultMap("initD.mp4", 0, 0)
+	_, err = ConcatMediaPlaylists(a, d)
+	is.True(errors.Is(err, ErrConcatMismatch)) // I-frame and normal playlists mixed
+	_, err = ConcatMediaPlaylists()
+	is.True(errors.Is(err, ErrPlaylistEmpty))
+}
+
+func TestConcatMediaPlaylistsDateRangeConflict(t *testing.T) {
+	is := is.New(t)
+	a := newConcatTestPlaylist(t, "a", 1, 4)
+	b := newConcatTestPlaylist(t, "b", 1, 4)
+	a.DateRanges = []*DateRange{{ID: "x", StartDate: time.Unix(0, 0)}}
+	b.DateRanges = []*DateRange{{ID: "x", StartDate: time.Unix(1, 0)}}
+	_, err := ConcatMediaPlaylists(a, b)
+	is.True(errors.Is(err, ErrConcatMismatch)) // same ID with different attributes
+}
+
+func TestConcatMediaPlaylistsCustomTags(t *testing.T) {
+	is := is.New(t)
+	a := newConcatTestPlaylist(t, "a", 1, 4)
+	b := newConcatTestPlaylist(t, "b", 1, 4)
+	a.AppendCustomTag(&MockCustomTag{name: "#X-TAG", encodedString: "#X-TAG:1"})
+	a.AppendCustomTag(&MockCustomTag{name: "#X-TAG", encodedString: "#X-TAG:2"})
+	b.AppendCustomTag(&MockCustomTag{name: "#X-TAG", encodedString: "#X-TAG:2"})
+	b.AppendCustomTag(&MockCustomTag{name: "#X-TAG", encodedString: "#X-TAG:3"})
+	p, err := ConcatMediaPlaylists(a, b)
+	is.NoErr(err)
+	is.Equal(len(p.CustomTags().GetAll("#X-TAG")), 3) // repeated tags kept, same tag only once
+	is.True(strings.Contains(p.String(), "#X-TAG:1\n#X-TAG:2\n#X-TAG:3\n"))
+}
+
+func TestEncodeKeyReturnToDefault(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 3)
+	is.NoErr(err)
+	is.NoErr(p.SetDefaultKey("AES-128", "key1", "", "", ""))
+	is.NoErr(p.Append("a.ts", 4, ""))
+	is.NoErr(p.Append("b.ts", 4, ""))
+	is.NoErr(p.SetKey("AES-128", "key2", "", "", ""))
+	is.NoErr(p.Append("c.ts", 4, ""))
+	is.NoErr(p.SetKey("AES-128", "key1", "", "", ""))
+	is.Equal(strings.Count(p.String(), `URI="key1"`), 2) // default key must be written again after key2
+}
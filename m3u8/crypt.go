@@ -0,0 +1,227 @@
+package m3u8
+
+/*
+ This file defines AES-128 encryption and decryption of media segments.
+*/
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrUnsupportedKeyMethod = errors.New("unsupported EXT-X-KEY METHOD")
+var ErrInvalidIV = errors.New("invalid EXT-X-KEY IV")
+var ErrKeyNotFound = errors.New("key not found")
+var ErrInvalidCiphertext = errors.New("invalid AES-128 ciphertext")
+
+// KeyProvider provides the key bytes for the URI of an EXT-X-KEY, e.g. by fetching them
+// from a key server.
+type KeyProvider interface {
+	KeyBytes(key *Key) ([]byte, error)
+}
+
+// KeyProviderFunc is an adapter to use a function as a KeyProvider.
+type KeyProviderFunc func(key *Key) ([]byte, error)
+
+// KeyBytes calls f(key).
+func (f KeyProviderFunc) KeyBytes(key *Key) ([]byte, error) {
+	return f(key)
+}
+
+// StaticKeys is a KeyProvider with the key bytes for each key URI.
+type StaticKeys map[string][]byte
+
+// KeyBytes returns the key bytes for key.URI, or ErrKeyNotFound.
+func (s StaticKeys) KeyBytes(key *Key) ([]byte, error) {
+	b, ok := s[key.URI]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", key.URI, ErrKeyNotFound)
+	}
+	return b, nil
+}
+
+// EffectiveKeys returns the EXT-X-KEY tags in effect for seg, which are the keys of the
+// closest segment up to seg declaring keys, or the playlist Keys.
+func (p *MediaPlaylist) EffectiveKeys(seg *MediaSegment) []Key {
+	keys := p.Keys
+	for i := uint(0); i < p.count; i++ {
+		s := p.Segments[(p.head+i)%p.capacity]
+		if s == nil {
+			continue
+		}
+		if len(s.Keys) > 0 {
+			keys = s.Keys
+		}
+		if s == seg {
+			break
+		}
+	}
+	return keys
+}
+
+// SegmentIV returns the IV for decrypting seg with key: the IV attribute of the key if
+// present, and otherwise the media sequence number of the segment.
+func SegmentIV(seg *MediaSegment, key *Key) ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	if key.IV == "" {
+		binary.BigEndian.PutUint64(iv[8:], seg.SeqId)
+		return iv, nil
+	}
+	digits := key.IV
+	if !strings.HasPrefix(digits, "0x") && !strings.HasPrefix(digits, "0X") {
+		return nil, fmt.Errorf("%q has no 0x prefix: %w", key.IV, ErrInvalidIV)
+	}
+	digits = digits[2:]
+	if len(digits) == 0 || len(digits) > 2*aes.BlockSize {
+		return nil, fmt.Errorf("%q is not 128 bits: %w", key.IV, ErrInvalidIV)
+	}
+	digits = strings.Repeat("0", 2*aes.BlockSize-len(digits)) + digits
+	if _, err := hex.Decode(iv, []byte(digits)); err != nil {
+		return nil, fmt.Errorf("%q: %w", key.IV, ErrInvalidIV)
+	}
+	return iv, nil
+}
+
+// EncryptSegment returns a reader with the AES-128 encryption of the media segment seg
+// read from r, as described by key, which must have METHOD AES-128 or NONE. r reads the
+// resource under seg.URI, so only the byte range of seg is encrypted if it has one.
+// The key bytes are taken from keys.
+func EncryptSegment(r io.Reader, seg *MediaSegment, key *Key, keys KeyProvider) (io.Reader, error) {
+	return cryptSegment(r, seg, key, keys, true)
+}
+
+// DecryptSegment returns a reader with the media segment seg decrypted from r, as
+// described by key, which must have METHOD AES-128 or NONE. r reads the resource under
+// seg.URI, so only the byte range of seg is decrypted if it has one. The key bytes are
+// taken from keys. A read returns ErrInvalidCiphertext if the padding is wrong.
+func DecryptSegment(r io.Reader, seg *MediaSegment, key *Key, keys KeyProvider) (io.Reader, error) {
+	return cryptSegment(r, seg, key, keys, false)
+}
+
+func cryptSegment(r io.Reader, seg *MediaSegment, key *Key, keys KeyProvider, encrypt bool) (io.Reader, error) {
+	r, err := segmentRange(r, seg)
+	if err != nil {
+		return nil, err
+	}
+	switch key.Method {
+	case "NONE":
+		return r, nil
+	case "AES-128":
+	default:
+		return nil, fmt.Errorf("%q: %w", key.Method, ErrUnsupportedKeyMethod)
+	}
+	iv, err := SegmentIV(seg, key)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes, err := keys.KeyBytes(key)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	c := &cbcReader{src: r, encrypt: encrypt, readBuf: make([]byte, 32*1024)}
+	if encrypt {
+		c.mode = cipher.NewCBCEncrypter(block, iv)
+	} else {
+		c.mode = cipher.NewCBCDecrypter(block, iv)
+	}
+	return c, nil
+}
+
+// segmentRange limits r to the byte range of seg, if it has one.
+func segmentRange(r io.Reader, seg *MediaSegment) (io.Reader, error) {
+	if seg.Limit <= 0 {
+		return r, nil
+	}
+	if s, ok := r.(io.Seeker); ok {
+		if _, err := s.Seek(seg.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else if _, err := io.CopyN(io.Discard, r, seg.Offset); err != nil {
+		return nil, err
+	}
+	return io.LimitReader(r, seg.Limit), nil
+}
+
+// cbcReader encrypts or decrypts a stream with AES-CBC and PKCS7 padding.
+type cbcReader struct {
+	src     io.Reader
+	mode    cipher.BlockMode
+	encrypt bool
+	readBuf []byte
+	in      []byte // input not yet processed
+	out     []byte // output not yet read
+	err     error  // error to return once out is read
+}
+
+func (c *cbcReader) Read(b []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.fill()
+	}
+	n := copy(b, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// fill reads from src and processes all complete blocks, except the last one when
+// decrypting, since it holds the padding.
+func (c *cbcReader) fill() {
+	n, err := c.src.Read(c.readBuf)
+	c.in = append(c.in, c.readBuf[:n]...)
+	switch {
+	case err == io.EOF:
+		c.finish()
+		return
+	case err != nil:
+		c.err = err
+		return
+	}
+	bs := c.mode.BlockSize()
+	keep := len(c.in) % bs
+	if !c.encrypt && keep == 0 && len(c.in) > 0 {
+		keep = bs
+	}
+	if process := len(c.in) - keep; process > 0 {
+		c.out = make([]byte, process)
+		c.mode.CryptBlocks(c.out, c.in[:process])
+		c.in = append(c.in[:0], c.in[process:]...)
+	}
+}
+
+// finish processes the rest of the input, adding or removing the padding.
+func (c *cbcReader) finish() {
+	c.err = io.EOF
+	bs := c.mode.BlockSize()
+	if c.encrypt {
+		padding := bs - len(c.in)%bs
+		c.in = append(c.in, bytes.Repeat([]byte{byte(padding)}, padding)...)
+		c.mode.CryptBlocks(c.in, c.in)
+		c.out, c.in = c.in, nil
+		return
+	}
+	if len(c.in) == 0 || len(c.in)%bs != 0 {
+		c.err = fmt.Errorf("length is not a multiple of the block size: %w", ErrInvalidCiphertext)
+		return
+	}
+	c.mode.CryptBlocks(c.in, c.in)
+	padding := int(c.in[len(c.in)-1])
+	if padding == 0 || padding > bs ||
+		!bytes.Equal(c.in[len(c.in)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		c.err = fmt.Errorf("bad padding: %w", ErrInvalidCiphertext)
+		return
+	}
+	c.out, c.in = c.in[:len(c.in)-padding], nil
+}
@@ -0,0 +1,121 @@
+package m3u8
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/matryer/is"
+)
+
+var testKeyBytes = []byte("0123456789abcdef")
+
+// referenceEncrypt encrypts data with AES-128-CBC and PKCS7 padding in one go.
+func referenceEncrypt(data, iv []byte) []byte {
+	block, _ := aes.NewCipher(testKeyBytes)
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	out := append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out
+}
+
+func TestEncryptDecryptSegment(t *testing.T) {
+	keys := StaticKeys{"key1": testKeyBytes}
+	for _, size := range []int{0, 1, 15, 16, 17, 100_000} {
+		for _, iv := range []string{"", "0x0102030405060708090A0B0C0D0E0F10"} {
+			is := is.New(t)
+			data := bytes.Repeat([]byte{'x', 'y', 'z'}, size)[:size]
+			seg := &MediaSegment{SeqId: 42, URI: "seg42.ts"}
+			key := &Key{Method: "AES-128", URI: "key1", IV: iv}
+			wantIV, err := SegmentIV(seg, key)
+			is.NoErr(err)
+
+			r, err := EncryptSegment(iotest.HalfReader(bytes.NewReader(data)), seg, key, keys)
+			is.NoErr(err)
+			encrypted, err := io.ReadAll(r)
+			is.NoErr(err)
+			is.Equal(encrypted, referenceEncrypt(data, wantIV)) // must match AES-128-CBC with PKCS7
+
+			r, err = DecryptSegment(iotest.OneByteReader(bytes.NewReader(encrypted)), seg, key, keys)
+			is.NoErr(err)
+			decrypted, err := io.ReadAll(r)
+			is.NoErr(err)
+			is.Equal(len(decrypted), size)
+			is.True(bytes.Equal(decrypted, data)) // round trip
+		}
+	}
+}
+
+func TestDecryptSegmentByteRange(t *testing.T) {
+	is := is.New(t)
+	keys := KeyProviderFunc(func(key *Key) ([]byte, error) { return testKeyBytes, nil })
+	key := &Key{Method: "AES-128", URI: "key1"}
+	seg1 := &MediaSegment{SeqId: 1, URI: "all.ts"}
+	part0 := referenceEncrypt([]byte("first segment"), make([]byte, 16)) // IV of sequence number 0
+	iv1, _ := SegmentIV(seg1, key)
+	part1 := referenceEncrypt([]byte("second segment"), iv1)
+	resource := append(bytes.Clone(part0), part1...)
+	seg1.Offset = int64(len(part0))
+	seg1.Limit = int64(len(part1))
+
+	for _, src := range []io.Reader{bytes.NewReader(resource), iotest.HalfReader(bytes.NewReader(resource))} {
+		r, err := DecryptSegment(src, seg1, key, keys) // with and without io.Seeker
+		is.NoErr(err)
+		decrypted, err := io.ReadAll(r)
+		is.NoErr(err)
+		is.Equal(string(decrypted), "second segment")
+	}
+}
+
+func TestDecryptSegmentErrors(t *testing.T) {
+	is := is.New(t)
+	keys := StaticKeys{"key1": testKeyBytes}
+	seg := &MediaSegment{SeqId: 1}
+
+	_, err := DecryptSegment(bytes.NewReader(nil), seg, &Key{Method: "SAMPLE-AES", URI: "key1"}, keys)
+	is.True(errors.Is(err, ErrUnsupportedKeyMethod))
+	_, err = DecryptSegment(bytes.NewReader(nil), seg, &Key{Method: "AES-128", URI: "key2"}, keys)
+	is.True(errors.Is(err, ErrKeyNotFound))
+	_, err = DecryptSegment(bytes.NewReader(nil), seg, &Key{Method: "AES-128", URI: "key1", IV: "0xZZ"}, keys)
+	is.True(errors.Is(err, ErrInvalidIV))
+
+	r, err := DecryptSegment(bytes.NewReader(make([]byte, 20)), seg, &Key{Method: "AES-128", URI: "key1"}, keys)
+	is.NoErr(err)
+	_, err = io.ReadAll(r)
+	is.True(errors.Is(err, ErrInvalidCiphertext)) // not a multiple of the block size
+
+	r, err = DecryptSegment(bytes.NewReader([]byte("plain")), seg, &Key{Method: "NONE"}, keys)
+	is.NoErr(err)
+	plain, err := io.ReadAll(r)
+	is.NoErr(err)
+	is.Equal(string(plain), "plain") // METHOD=NONE is not encrypted
+}
+
+func TestSegmentIV(t *testing.T) {
+	is := is.New(t)
+	iv, err := SegmentIV(&MediaSegment{SeqId: 258}, &Key{Method: "AES-128"})
+	is.NoErr(err)
+	is.Equal(iv, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}) // from media sequence number
+	iv, err = SegmentIV(&MediaSegment{}, &Key{Method: "AES-128", IV: SequenceIV(258)})
+	is.NoErr(err)
+	is.Equal(iv[14:], []byte{1, 2}) // same as SequenceIV
+}
+
+func TestEffectiveKeys(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 4)
+	is.NoErr(err)
+	is.NoErr(p.SetDefaultKey("AES-128", "key0", "", "", ""))
+	for i := 0; i < 4; i++ {
+		is.NoErr(p.Append("seg.ts", 4, ""))
+		if i == 2 {
+			is.NoErr(p.SetKey("AES-128", "key2", "", "", ""))
+		}
+	}
+	is.Equal(p.EffectiveKeys(p.Segments[1])[0].URI, "key0")
+	is.Equal(p.EffectiveKeys(p.Segments[3])[0].URI, "key2")
+}
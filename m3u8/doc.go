@@ -25,6 +25,9 @@ These are represented as two different structs, but they have a common interface
 
 There is a function Decode, that decodes and autodetects the type of playlist by decoding
 both in parallel, and stopping one, once the type is known.
+Tags and attributes that are not known are dropped, unless decoding in preserve mode
+with DecodePreserveUnknown or SetPreserveUnknown, which keeps them so that Encode writes
+them again.
 
 For generating playlists, one starts by calling either NewMasterPlaylist or NewMediaPlaylist.
 One can then Set or Append extra data such as Variants or Segments.
@@ -32,7 +35,12 @@ One can then Set or Append extra data such as Variants or Segments.
 For live media playlists with a fixed sliding window, one can set a window size (winsize) that will
 be used to Encode a maximum number of latest segments.
 
-For VOD or EVENT media playlists, the winsize should be 0.
+For live media playlists with a window of a fixed duration, e.g. a DVR window of two hours with
+varying segment durations, one can instead call SetWindowDuration. Segments leaving the window are
+then removed automatically when new segments are appended.
+
+For VOD or EVENT media playlists, the winsize should be 0. If the number of segments is not known
+in advance, SetGrowable lets the capacity grow as segments are appended.
 
 For writing, there are Encode methods that return a [*bytes.Buffer]. This buffer serves as a cache.
 
@@ -0,0 +1,240 @@
+package m3u8
+
+/*
+ This file defines helpers for DRM systems used with EXT-X-KEY and EXT-X-SESSION-KEY.
+*/
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Well-known KEYFORMAT values.
+const (
+	KeyformatIdentity      = "identity"
+	KeyformatFairPlay      = "com.apple.streamingkeydelivery"
+	KeyformatWidevine      = "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+	KeyformatPlayReady     = "com.microsoft.playready"
+	KeyformatPlayReadyUUID = "urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95"
+)
+
+// psshDataURIPrefix is the prefix of a base64 data URI with a PSSH box.
+const psshDataURIPrefix = "data:text/plain;base64,"
+
+var ErrInvalidPSSH = errors.New("invalid PSSH box")
+
+// DRM system IDs used in PSSH boxes.
+var (
+	WidevineSystemID = [16]byte{0xed, 0xef, 0x8b, 0xa9, 0x79, 0xd6, 0x4a, 0xce,
+		0xa3, 0xc8, 0x27, 0xdc, 0xd5, 0x1d, 0x21, 0xed}
+	PlayReadySystemID = [16]byte{0x9a, 0x04, 0xf0, 0x79, 0x98, 0x40, 0x42, 0x86,
+		0xab, 0x92, 0xe6, 0x5b, 0xe0, 0x88, 0x5f, 0x95}
+)
+
+// DRMSystem is a DRM system identified by the KEYFORMAT of a key.
+type DRMSystem uint
+
+const (
+	// use 0 for keys without a known DRM system
+	DRMFairPlay DRMSystem = iota + 1
+	DRMWidevine
+	DRMPlayReady
+)
+
+func (s DRMSystem) String() string {
+	switch s {
+	case DRMFairPlay:
+		return "FairPlay"
+	case DRMWidevine:
+		return "Widevine"
+	case DRMPlayReady:
+		return "PlayReady"
+	}
+	return "Unknown"
+}
+
+// Keyformat returns the KEYFORMAT value of the DRM system.
+func (s DRMSystem) Keyformat() string {
+	switch s {
+	case DRMFairPlay:
+		return KeyformatFairPlay
+	case DRMWidevine:
+		return KeyformatWidevine
+	case DRMPlayReady:
+		return KeyformatPlayReady
+	}
+	return ""
+}
+
+// DRMSystem returns the DRM system of the key from its KEYFORMAT, or 0 if it is not a
+// known DRM system, e.g. for AES-128 keys with the identity key format.
+func (k *Key) DRMSystem() DRMSystem {
+	switch strings.ToLower(k.Keyformat) {
+	case KeyformatFairPlay:
+		return DRMFairPlay
+	case KeyformatWidevine:
+		return DRMWidevine
+	case KeyformatPlayReady, KeyformatPlayReadyUUID:
+		return DRMPlayReady
+	}
+	return 0
+}
+
+// PSSH is a Protection System Specific Header box as defined in ISO/IEC 23001-7,
+// carried as a data URI in the EXT-X-KEY URI for Widevine.
+type PSSH struct {
+	Version  uint8
+	SystemID [16]byte
+	KeyIDs   [][16]byte // only for version 1
+	Data     []byte
+}
+
+// Bytes returns the PSSH box.
+func (p *PSSH) Bytes() []byte {
+	size := 32 + len(p.Data) // box and full box headers, system ID and data size
+	if p.Version > 0 {
+		size += 4 + 16*len(p.KeyIDs)
+	}
+	b := make([]byte, 0, size)
+	b = binary.BigEndian.AppendUint32(b, uint32(size))
+	b = append(b, "pssh"...)
+	b = binary.BigEndian.AppendUint32(b, uint32(p.Version)<<24) // version and flags
+	b = append(b, p.SystemID[:]...)
+	if p.Version > 0 {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(p.KeyIDs)))
+		for _, kid := range p.KeyIDs {
+			b = append(b, kid[:]...)
+		}
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.Data)))
+	return append(b, p.Data...)
+}
+
+// DataURI returns the PSSH box as a base64 data URI for an EXT-X-KEY URI.
+func (p *PSSH) DataURI() string {
+	return psshDataURIPrefix + base64.StdEncoding.EncodeToString(p.Bytes())
+}
+
+// DRMSystem returns the DRM system of the PSSH box from its system ID, or 0 if unknown.
+func (p *PSSH) DRMSystem() DRMSystem {
+	switch p.SystemID {
+	case WidevineSystemID:
+		return DRMWidevine
+	case PlayReadySystemID:
+		return DRMPlayReady
+	}
+	return 0
+}
+
+// ParsePSSH parses a PSSH box of version 0 or 1.
+func ParsePSSH(b []byte) (*PSSH, error) {
+	if len(b) < 32 || string(b[4:8]) != "pssh" {
+		return nil, fmt.Errorf("no pssh box: %w", ErrInvalidPSSH)
+	}
+	if size := binary.BigEndian.Uint32(b); int(size) != len(b) {
+		return nil, fmt.Errorf("box size %d for %d bytes: %w", size, len(b), ErrInvalidPSSH)
+	}
+	p := &PSSH{Version: b[8]}
+	if p.Version > 1 {
+		return nil, fmt.Errorf("version %d: %w", p.Version, ErrInvalidPSSH)
+	}
+	copy(p.SystemID[:], b[12:28])
+	b = b[28:]
+	if p.Version > 0 {
+		n := int(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		if len(b) < 16*n+4 {
+			return nil, fmt.Errorf("%d key IDs: %w", n, ErrInvalidPSSH)
+		}
+		p.KeyIDs = make([][16]byte, n)
+		for i := range p.KeyIDs {
+			copy(p.KeyIDs[i][:], b[16*i:])
+		}
+		b = b[16*n:]
+	}
+	if n := int(binary.BigEndian.Uint32(b)); n != len(b)-4 {
+		return nil, fmt.Errorf("data size %d for %d bytes: %w", n, len(b)-4, ErrInvalidPSSH)
+	}
+	p.Data = bytes.Clone(b[4:])
+	return p, nil
+}
+
+// ParsePSSHDataURI parses a PSSH box from a base64 data URI, as used in EXT-X-KEY URIs.
+func ParsePSSHDataURI(uri string) (*PSSH, error) {
+	if !strings.HasPrefix(uri, "data:") {
+		return nil, fmt.Errorf("not a data URI: %w", ErrInvalidPSSH)
+	}
+	_, data, ok := strings.Cut(uri, ";base64,")
+	if !ok {
+		return nil, fmt.Errorf("data URI is not base64: %w", ErrInvalidPSSH)
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPSSH, err)
+	}
+	return ParsePSSH(b)
+}
+
+// KeyIDFromHex parses a key ID written as 32 hex digits, optionally with dashes as in a UUID.
+func KeyIDFromHex(s string) ([16]byte, error) {
+	var kid [16]byte
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != len(kid) {
+		return kid, fmt.Errorf("key ID %q is not 16 bytes in hex", s)
+	}
+	copy(kid[:], b)
+	return kid, nil
+}
+
+// SessionKeys returns the keys of the media playlists, suitable for EXT-X-SESSION-KEY
+// tags: keys with METHOD=NONE are left out, IVs are removed, since they apply to
+// segments, and duplicates are removed. The keys are in order of first use.
+func SessionKeys(playlists ...*MediaPlaylist) []*Key {
+	var keys []*Key
+	add := func(key Key) {
+		if key.Method == "" || key.Method == "NONE" {
+			return
+		}
+		key.IV = ""
+		if !slices.ContainsFunc(keys, func(k *Key) bool { return k.Equal(&key) }) {
+			keys = append(keys, &key)
+		}
+	}
+	for _, pl := range playlists {
+		if pl == nil {
+			continue
+		}
+		for _, key := range pl.Keys {
+			add(key)
+		}
+		for _, seg := range pl.GetAllSegments() {
+			if seg == nil {
+				continue
+			}
+			for _, key := range seg.Keys {
+				add(key)
+			}
+		}
+	}
+	return keys
+}
+
+// GenerateSessionKeys adds the keys of the media playlists of the variants, see
+// SessionKeys, to SessionKeys, so that clients can preload them. Session keys already
+// set are kept, and derived keys equal to one of them are not added again. Variants
+// without a Chunklist are ignored.
+func (p *MasterPlaylist) GenerateSessionKeys() {
+	playlists := make([]*MediaPlaylist, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		playlists = append(playlists, v.Chunklist)
+	}
+	for _, key := range SessionKeys(playlists...) {
+		p.AddSessionKey(*key)
+	}
+}
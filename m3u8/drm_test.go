@@ -0,0 +1,93 @@
+package m3u8
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestKeyDRMSystem(t *testing.T) {
+	is := is.New(t)
+	is.Equal((&Key{Keyformat: KeyformatFairPlay}).DRMSystem(), DRMFairPlay)
+	is.Equal((&Key{Keyformat: strings.ToUpper(KeyformatWidevine)}).DRMSystem(), DRMWidevine) // case-insensitive UUID
+	is.Equal((&Key{Keyformat: KeyformatPlayReadyUUID}).DRMSystem(), DRMPlayReady)
+	is.Equal((&Key{Method: "AES-128"}).DRMSystem(), DRMSystem(0))
+	is.Equal(DRMWidevine.Keyformat(), KeyformatWidevine)
+	is.Equal(DRMPlayReady.String(), "PlayReady")
+}
+
+func TestPSSHRoundTrip(t *testing.T) {
+	is := is.New(t)
+	kid, err := KeyIDFromHex("01234567-89ab-cdef-0123-456789abcdef")
+	is.NoErr(err)
+	is.Equal(kid[15], byte(0xef))
+	for _, pssh := range []*PSSH{
+		{SystemID: WidevineSystemID, Data: []byte("widevine data")},
+		{Version: 1, SystemID: PlayReadySystemID, KeyIDs: [][16]byte{kid, kid}, Data: []byte{}},
+	} {
+		b := pssh.Bytes()
+		is.Equal(len(b), int(b[3]))
+		parsed, err := ParsePSSH(b)
+		is.NoErr(err)
+		is.Equal(parsed, pssh)
+
+		uri := pssh.DataURI()
+		is.True(strings.HasPrefix(uri, "data:text/plain;base64,"))
+		parsed, err = ParsePSSHDataURI(uri)
+		is.NoErr(err)
+		is.Equal(parsed, pssh)
+	}
+	p, err := ParsePSSHDataURI((&PSSH{SystemID: WidevineSystemID}).DataURI())
+	is.NoErr(err)
+	is.Equal(p.DRMSystem(), DRMWidevine)
+}
+
+func TestParsePSSHErrors(t *testing.T) {
+	is := is.New(t)
+	b := (&PSSH{Version: 1, SystemID: WidevineSystemID, KeyIDs: make([][16]byte, 1)}).Bytes()
+	_, err := ParsePSSH(b[:len(b)-1])
+	is.True(errors.Is(err, ErrInvalidPSSH)) // truncated
+	b[8] = 2
+	_, err = ParsePSSH(b)
+	is.True(errors.Is(err, ErrInvalidPSSH)) // unknown version
+	_, err = ParsePSSHDataURI("skd://key1")
+	is.True(errors.Is(err, ErrInvalidPSSH))
+	_, err = ParsePSSHDataURI("data:text/plain;base64,!!")
+	is.True(errors.Is(err, ErrInvalidPSSH))
+	_, err = KeyIDFromHex("0123")
+	is.True(err != nil)
+}
+
+func TestSessionKeys(t *testing.T) {
+	is := is.New(t)
+	p1, err := NewMediaPlaylist(0, 4)
+	is.NoErr(err)
+	p2, err := NewMediaPlaylist(0, 4)
+	is.NoErr(err)
+	p1.SetKeyRotationPolicy(KeyRotationPolicy{Keys: rotationKeys, Segments: 2, IVFromSequence: true})
+	p2.SetKeyRotationPolicy(KeyRotationPolicy{Keys: rotationKeys, Segments: 2})
+	for _, p := range []*MediaPlaylist{p1, p2} {
+		for i := 0; i < 4; i++ {
+			is.NoErr(p.Append("seg.ts", 4, ""))
+		}
+	}
+	is.NoErr(p2.SetKey("NONE", "", "", "", ""))
+
+	keys := SessionKeys(p1, p2, nil)
+	is.Equal(len(keys), 4) // two periods of two keys, without duplicates and NONE
+	is.Equal(keys[0].URI, "skd://key0")
+	is.Equal(keys[3].URI, "data:text/plain;base64,key1")
+	is.Equal(keys[0].IV, "") // IVs removed
+
+	m := NewMasterPlaylist()
+	m.Append("p1.m3u8", p1, VariantParams{Bandwidth: 1000000})
+	m.Append("p2.m3u8", p2, VariantParams{Bandwidth: 2000000})
+	m.GenerateSessionKeys()
+	is.Equal(m.SessionKeys, keys)
+	is.Equal(m.Version(), uint8(5)) // KEYFORMAT
+	out := m.String()
+	is.Equal(strings.Count(out, "#EXT-X-SESSION-KEY:"), 4)
+	is.True(strings.Contains(out, `#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://key0",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"`))
+}
@@ -0,0 +1,116 @@
+package m3u8
+
+/*
+ This file defines filtering of the variants of a master playlist by the
+ capabilities of a device.
+*/
+
+import (
+	"slices"
+	"strings"
+)
+
+// DeviceProfile describes the capabilities of a class of devices, such as the
+// highest resolution or the codecs it can play. Zero values mean no limit.
+type DeviceProfile struct {
+	MaxWidth        int                // Maximum width of RESOLUTION
+	MaxHeight       int                // Maximum height of RESOLUTION
+	MaxBandwidth    uint32             // Maximum BANDWIDTH
+	MaxFrameRate    float64            // Maximum FRAME-RATE
+	Codecs          []string           // Supported codec sample entry types, e.g. avc1, hvc1 and mp4a
+	CodecSupported  func(c Codec) bool // Tells if a codec is supported, for checks of profile or level
+	VideoRanges     []VideoRange       // Supported VIDEO-RANGE values. Variants without one are SDR
+	MaxHDCPLevel    HDCPLevel          // Highest supported HDCP-LEVEL
+	ReqVideoLayouts []string           // Supported REQ-VIDEO-LAYOUT parameters, e.g. CH-STEREO
+}
+
+// Supports tells if a device with the profile can play the variant. All codecs in
+// CODECS must be supported, and codecs that cannot be parsed are not supported when
+// Codecs or CodecSupported is set. A variant without RESOLUTION, FRAME-RATE or
+// HDCP-LEVEL is not limited by the corresponding maximum, while a variant with an
+// unknown HDCP-LEVEL is not supported when MaxHDCPLevel is set.
+func (d *DeviceProfile) Supports(v *Variant) bool {
+	if d.MaxBandwidth > 0 && v.Bandwidth > d.MaxBandwidth {
+		return false
+	}
+	if d.MaxFrameRate > 0 && v.FrameRate > d.MaxFrameRate {
+		return false
+	}
+	if d.MaxWidth > 0 || d.MaxHeight > 0 {
+		w, h, ok := parseResolution(v.Resolution)
+		if ok && ((d.MaxWidth > 0 && w > d.MaxWidth) || (d.MaxHeight > 0 && h > d.MaxHeight)) {
+			return false
+		}
+	}
+	if len(d.VideoRanges) > 0 {
+		videoRange := v.GetVideoRange()
+		if videoRange == "" {
+			videoRange = VideoRangeSDR
+		}
+		if !slices.Contains(d.VideoRanges, videoRange) {
+			return false
+		}
+	}
+	if d.MaxHDCPLevel != "" && v.HDCPLevel != "" {
+		level := slices.Index(hdcpLevels, v.GetHDCPLevel())
+		if level < 0 || level > slices.Index(hdcpLevels, d.MaxHDCPLevel) {
+			return false
+		}
+	}
+	if len(d.ReqVideoLayouts) > 0 && v.ReqVideoLayout != "" {
+		for _, param := range strings.FieldsFunc(v.ReqVideoLayout, func(r rune) bool { return r == ',' || r == '/' }) {
+			if !slices.Contains(d.ReqVideoLayouts, param) {
+				return false
+			}
+		}
+	}
+	return d.supportsCodecs(v.Codecs)
+}
+
+// supportsCodecs tells if all codecs of a CODECS attribute are supported.
+func (d *DeviceProfile) supportsCodecs(s string) bool {
+	if len(d.Codecs) == 0 && d.CodecSupported == nil {
+		return true
+	}
+	codecs, err := ParseCodecs(s)
+	if err != nil {
+		return false
+	}
+	for _, c := range codecs {
+		if len(d.Codecs) > 0 && !slices.Contains(d.Codecs, c.Type) {
+			return false
+		}
+		if d.CodecSupported != nil && !d.CodecSupported(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns a copy of the playlist with only the variants and I-frame variants
+// the device profile supports. Rendition groups that no remaining variant refers to
+// are removed. The original playlist is not changed.
+func (p *MasterPlaylist) Filter(profile DeviceProfile) *MasterPlaylist {
+	c := p.Clone()
+	c.Variants = slices.DeleteFunc(c.Variants, func(v *Variant) bool { return !profile.Supports(v) })
+	c.pruneRenditionGroups()
+	return c
+}
+
+// pruneRenditionGroups removes the rendition groups no variant refers to.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) pruneRenditionGroups() {
+	if len(p.RenditionGroups) > 0 {
+		p.foldAlternatives()
+	}
+	p.RenditionGroups = slices.DeleteFunc(p.RenditionGroups, func(g *RenditionGroup) bool {
+		for _, v := range p.Variants {
+			if v.groupIds()[g.Type] == g.GroupId {
+				return false
+			}
+		}
+		return true
+	})
+	p.UpdateAlternatives()
+	p.buf.Reset()
+}
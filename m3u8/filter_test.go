@@ -0,0 +1,91 @@
+package m3u8
+
+import (
+	"bufio"
+	"os"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func readDeviceMaster(t *testing.T) *MasterPlaylist {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-for-devices.m3u8")
+	is.NoErr(err) // must open file
+	defer f.Close()
+	p := NewMasterPlaylist()
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true)) // must decode playlist
+	return p
+}
+
+func variantURIs(p *MasterPlaylist) []string {
+	uris := make([]string, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		uris = append(uris, v.URI)
+	}
+	return uris
+}
+
+func TestFilter(t *testing.T) {
+	cases := []struct {
+		desc    string
+		profile DeviceProfile
+		want    []string
+		groups  int
+	}{
+		{"no limits", DeviceProfile{},
+			[]string{"avc/720p.m3u8", "avc/1080p.m3u8", "hevc/2160p.m3u8", "mvhevc/1080p.m3u8",
+				"avc/720p_iframe.m3u8", "hevc/2160p_iframe.m3u8"}, 3},
+		{"avc only", DeviceProfile{Codecs: []string{CodecAVC1, CodecMP4A}},
+			[]string{"avc/720p.m3u8", "avc/1080p.m3u8", "avc/720p_iframe.m3u8"}, 2},
+		{"hd", DeviceProfile{MaxWidth: 1920, MaxHeight: 1080, MaxFrameRate: 30},
+			[]string{"avc/720p.m3u8", "mvhevc/1080p.m3u8", "avc/720p_iframe.m3u8"}, 2},
+		{"bandwidth", DeviceProfile{MaxBandwidth: 5000000},
+			[]string{"avc/720p.m3u8", "avc/1080p.m3u8", "avc/720p_iframe.m3u8", "hevc/2160p_iframe.m3u8"}, 2},
+		{"sdr", DeviceProfile{VideoRanges: []VideoRange{VideoRangeSDR}},
+			[]string{"avc/720p.m3u8", "avc/1080p.m3u8", "mvhevc/1080p.m3u8", "avc/720p_iframe.m3u8"}, 2},
+		{"no hdcp", DeviceProfile{MaxHDCPLevel: HDCPLevelNone},
+			[]string{"avc/720p.m3u8", "mvhevc/1080p.m3u8", "avc/720p_iframe.m3u8"}, 2},
+		{"hdcp type 0", DeviceProfile{MaxHDCPLevel: HDCPLevelType0},
+			[]string{"avc/720p.m3u8", "avc/1080p.m3u8", "mvhevc/1080p.m3u8", "avc/720p_iframe.m3u8"}, 2},
+		{"mono layout", DeviceProfile{ReqVideoLayouts: []string{"CH-MONO"}},
+			[]string{"avc/720p.m3u8", "avc/1080p.m3u8", "hevc/2160p.m3u8",
+				"avc/720p_iframe.m3u8", "hevc/2160p_iframe.m3u8"}, 3},
+		{"codec level", DeviceProfile{CodecSupported: func(c Codec) bool { return !c.IsAVC() || c.Level <= 31 }},
+			[]string{"avc/720p.m3u8", "hevc/2160p.m3u8", "mvhevc/1080p.m3u8",
+				"avc/720p_iframe.m3u8", "hevc/2160p_iframe.m3u8"}, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			is := is.New(t)
+			p := readDeviceMaster(t)
+			want := p.String()
+			f := p.Filter(c.profile)
+			is.Equal(variantURIs(f), c.want)           // remaining variants
+			is.Equal(len(f.RenditionGroups), c.groups) // remaining rendition groups
+			is.Equal(p.String(), want)                 // original must be unchanged
+			is.Equal(len(p.Variants), 6)
+		})
+	}
+}
+
+func TestSupportsUnknownHDCPLevel(t *testing.T) {
+	is := is.New(t)
+	v := &Variant{VariantParams: VariantParams{Bandwidth: 1000000, HDCPLevel: "TYPE-2"}}
+	is.True(!(&DeviceProfile{MaxHDCPLevel: HDCPLevelNone}).Supports(v))  // unknown level must not be supported
+	is.True(!(&DeviceProfile{MaxHDCPLevel: HDCPLevelType1}).Supports(v)) // not even by the highest level
+	is.True((&DeviceProfile{}).Supports(v))                              // without limit
+}
+
+func TestFilterPrunesRenditions(t *testing.T) {
+	is := is.New(t)
+	p := readDeviceMaster(t)
+	f := p.Filter(DeviceProfile{Codecs: []string{CodecAVC1, CodecMP4A}})
+	is.Equal(f.RenditionGroup(RenditionAudio, "ec3"), nil) // unused group must be removed
+	for _, alt := range f.GetAllAlternatives() {
+		is.True(alt.GroupId != "ec3") // rendition of the removed group must not be written
+	}
+	is.Equal(len(f.Variants[0].Alternatives), 2)
+	is.Equal(f.Variants[0].Alternatives[0], f.RenditionGroups[0].Renditions[0])
+	is.True(f.Variants[0].Alternatives[0] != p.Variants[0].Alternatives[0]) // renditions must be copies
+}
@@ -0,0 +1,112 @@
+package m3u8
+
+/*
+ This file defines automatic key rotation for encrypted media playlists.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrKeyRotationConflict = errors.New("segment keys differ from the key rotation policy")
+
+// KeyRotationFunc returns the EXT-X-KEY tags for a key period, typically one key per
+// KEYFORMAT, e.g. for FairPlay, Widevine and PlayReady. period counts the periods from 0,
+// and seqId is the media sequence number of the first segment of the period.
+type KeyRotationFunc func(period, seqId uint64) ([]Key, error)
+
+// KeyRotationPolicy makes a media playlist set the keys of appended segments, and start
+// a new key period with new keys from Keys after a number of segments or a duration.
+// The first segment of every period gets the keys, so that they are written at the
+// rotation boundaries, and Remove carries them forward when the window slides.
+// Segments appended with keys of their own must have the keys the policy gives them.
+type KeyRotationPolicy struct {
+	Keys KeyRotationFunc
+	// Segments is the number of segments in a key period, 0 for no limit.
+	Segments uint
+	// Interval is the duration of a key period in seconds, 0 for no limit. A new period
+	// starts with the first segment appended after the interval.
+	Interval float64
+	// IVFromSequence sets the IV of the keys of every segment to its media sequence number,
+	// as done implicitly by clients for keys without IV. The keys are then written for every
+	// segment.
+	IVFromSequence bool
+}
+
+// keyRotator keeps track of key periods while appending segments.
+type keyRotator struct {
+	policy   KeyRotationPolicy
+	keys     []Key   // keys of the current period
+	period   uint64  // number of the next period
+	segments uint    // number of segments in the current period
+	duration float64 // duration of the current period
+}
+
+// SetKeyRotationPolicy sets the policy for the keys of segments appended from now on.
+// The first segment appended starts a new key period. A policy without Keys turns key
+// rotation off.
+func (p *MediaPlaylist) SetKeyRotationPolicy(policy KeyRotationPolicy) {
+	if policy.Keys == nil {
+		p.keyRotator = nil
+		return
+	}
+	p.keyRotator = &keyRotator{policy: policy}
+}
+
+// SequenceIV returns the IV attribute value for a media sequence number, which is the
+// IV a client uses for a key without IV attribute.
+func SequenceIV(seqId uint64) string {
+	return fmt.Sprintf("0x%032X", seqId)
+}
+
+// nextKeys returns the keys of a segment with media sequence number seqId being appended,
+// starting a new key period if due. Keys already set on the segment must be those the
+// policy gives it, or those still in effect, else ErrKeyRotationConflict is returned.
+// The state of the rotator is only changed on success.
+func (r *keyRotator) nextKeys(seg *MediaSegment, seqId uint64) ([]Key, error) {
+	var keys []Key // nil if the keys of the period are still in effect
+	periodKeys := r.keys
+	newPeriod := r.period == 0 || r.due()
+	if newPeriod {
+		var err error
+		periodKeys, err = r.policy.Keys(r.period, seqId)
+		if err != nil {
+			return nil, fmt.Errorf("key period %d: %w", r.period, err)
+		}
+		keys = slices.Clone(periodKeys)
+	}
+	if r.policy.IVFromSequence {
+		keys = slices.Clone(periodKeys)
+		for i := range keys {
+			if keys[i].Method != "NONE" {
+				keys[i].IV = SequenceIV(seqId)
+			}
+		}
+	}
+	if len(seg.Keys) > 0 {
+		want := keys
+		if want == nil {
+			want = periodKeys
+		}
+		if !keysEqual(seg.Keys, want) {
+			return nil, fmt.Errorf("%w: segment %s", ErrKeyRotationConflict, seg.URI)
+		}
+	}
+	if newPeriod {
+		r.keys = periodKeys
+		r.period++
+		r.segments = 0
+		r.duration = 0
+	}
+	r.segments++
+	r.duration += seg.Duration
+	return keys, nil
+}
+
+// due tells whether the current key period is over.
+func (r *keyRotator) due() bool {
+	return (r.policy.Segments > 0 && r.segments >= r.policy.Segments) ||
+		(r.policy.Interval > 0 && r.duration >= r.policy.Interval)
+}
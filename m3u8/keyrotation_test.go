@@ -0,0 +1,137 @@
+package m3u8
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// rotationKeys returns a FairPlay and a Widevine key per period.
+func rotationKeys(period, seqId uint64) ([]Key, error) {
+	return []Key{
+		{Method: "SAMPLE-AES", URI: fmt.Sprintf("skd://key%d", period), Keyformat: "com.apple.streamingkeydelivery",
+			Keyformatversions: "1"},
+		{Method: "SAMPLE-AES", URI: fmt.Sprintf("data:text/plain;base64,key%d", period),
+			Keyformat: "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed", Keyformatversions: "1"},
+	}, nil
+}
+
+func TestKeyRotationSegments(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	p.SetKeyRotationPolicy(KeyRotationPolicy{Keys: rotationKeys, Segments: 3})
+	for i := 0; i < 7; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("seg%d.ts", i), 4, ""))
+	}
+	for i, seg := range p.GetAllSegments() {
+		if i%3 == 0 {
+			is.Equal(len(seg.Keys), 2) // keys at rotation boundary
+			is.Equal(seg.Keys[0].URI, fmt.Sprintf("skd://key%d", i/3))
+		} else {
+			is.Equal(len(seg.Keys), 0) // keys still in effect
+		}
+	}
+	is.Equal(p.Version(), uint8(5)) // KEYFORMAT
+	out := p.String()
+	is.Equal(strings.Count(out, "#EXT-X-KEY:"), 6)
+	is.True(strings.Index(out, `URI="skd://key1"`) < strings.Index(out, "seg3.ts"))
+	is.True(strings.Index(out, `URI="skd://key1"`) > strings.Index(out, "seg2.ts"))
+}
+
+func TestKeyRotationInterval(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	var seqIds []uint64
+	p.SetKeyRotationPolicy(KeyRotationPolicy{
+		Keys: func(period, seqId uint64) ([]Key, error) {
+			seqIds = append(seqIds, seqId)
+			return []Key{{Method: "AES-128", URI: fmt.Sprintf("key%d", period)}}, nil
+		},
+		Interval: 10,
+	})
+	p.SeqNo = 100
+	for i := 0; i < 7; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("seg%d.ts", i), 4, ""))
+	}
+	is.Equal(seqIds, []uint64{100, 103, 106}) // new period after 12s
+}
+
+func TestKeyRotationIVFromSequence(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	p.SetKeyRotationPolicy(KeyRotationPolicy{
+		Keys: func(period, seqId uint64) ([]Key, error) {
+			return []Key{{Method: "AES-128", URI: fmt.Sprintf("key%d", period)}}, nil
+		},
+		Segments:       2,
+		IVFromSequence: true,
+	})
+	for i := 0; i < 3; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("seg%d.ts", i), 4, ""))
+	}
+	is.Equal(p.Segments[1].Keys, []Key{{Method: "AES-128", URI: "key0", IV: "0x00000000000000000000000000000001"}})
+	is.Equal(p.Segments[2].Keys[0].URI, "key1")
+	is.Equal(strings.Count(p.String(), "#EXT-X-KEY:"), 3) // keys written for every segment
+}
+
+func TestKeyRotationSlidingWindow(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(3, 3)
+	is.NoErr(err)
+	p.SetKeyRotationPolicy(KeyRotationPolicy{Keys: rotationKeys, Segments: 4})
+	for i := 0; i < 10; i++ {
+		p.Slide(fmt.Sprintf("seg%d.ts", i), 4, "")
+		out := p.String()
+		period := (i - 2) / 4 // period of the first segment in the window
+		if i < 2 {
+			period = 0
+		}
+		firstKey := strings.Index(out, "#EXT-X-KEY:")
+		is.True(firstKey >= 0 && firstKey < strings.Index(out, "#EXTINF:")) // keys valid for the window
+		is.True(strings.Contains(out[firstKey:], fmt.Sprintf(`URI="skd://key%d"`, period)))
+	}
+}
+
+func TestKeyRotationError(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	errNoKey := errors.New("no key")
+	p.SetKeyRotationPolicy(KeyRotationPolicy{Keys: func(_, _ uint64) ([]Key, error) { return nil, errNoKey }})
+	err = p.Append("seg0.ts", 4, "")
+	is.True(errors.Is(err, errNoKey))
+	is.Equal(p.Count(), uint(0)) // segment not appended
+
+	p.SetKeyRotationPolicy(KeyRotationPolicy{})
+	is.NoErr(p.Append("seg0.ts", 4, "")) // rotation turned off
+	is.Equal(len(p.Segments[0].Keys), 0)
+}
+
+func TestKeyRotationSegmentKeys(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	p.SetKeyRotationPolicy(KeyRotationPolicy{Keys: rotationKeys, Segments: 2})
+	keys, _ := rotationKeys(0, 0)
+
+	seg := GetSegment()
+	seg.URI, seg.Duration, seg.Keys = "seg0.ts", 4, []Key{{Method: "AES-128", URI: "other"}}
+	err = p.AppendSegment(seg)
+	is.True(errors.Is(err, ErrKeyRotationConflict)) // keys set by the caller are not replaced
+	is.Equal(p.Count(), uint(0))
+	is.Equal(seg.Keys[0].URI, "other")
+
+	seg.Keys = keys
+	is.NoErr(p.AppendSegment(seg)) // the keys of the policy are accepted
+	seg = GetSegment()
+	seg.URI, seg.Duration, seg.Keys = "seg1.ts", 4, keys
+	is.NoErr(p.AppendSegment(seg)) // as are the keys still in effect
+	is.Equal(len(seg.Keys), 0)     // which need not be repeated
+	is.Equal(strings.Count(p.String(), "#EXT-X-KEY:"), 2)
+}
@@ -0,0 +1,311 @@
+package m3u8
+
+/*
+ This file defines sorting of variants and analysis of the ABR ladder of a master playlist.
+*/
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrLadderIssue = errors.New("ABR ladder issue")
+
+// DefaultMaxStepRatio is the largest bandwidth ratio between adjacent rungs of a ladder
+// that is not reported as a gap.
+const DefaultMaxStepRatio = 2.0
+
+// CompareBandwidth compares variants by BANDWIDTH, for use with SortVariants.
+func CompareBandwidth(a, b *Variant) int {
+	return cmp.Compare(a.Bandwidth, b.Bandwidth)
+}
+
+// CompareResolution compares variants by the number of pixels of RESOLUTION, and then
+// by BANDWIDTH. Variants without RESOLUTION come first.
+func CompareResolution(a, b *Variant) int {
+	wa, ha, _ := parseResolution(a.Resolution)
+	wb, hb, _ := parseResolution(b.Resolution)
+	if c := cmp.Compare(wa*ha, wb*hb); c != 0 {
+		return c
+	}
+	return CompareBandwidth(a, b)
+}
+
+// CompareScore compares variants by SCORE, and then by BANDWIDTH.
+func CompareScore(a, b *Variant) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return CompareBandwidth(a, b)
+}
+
+// SortVariants sorts the variants with compare, e.g. CompareBandwidth, keeping the
+// order of equal variants. Swap the arguments of compare for a descending order.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) SortVariants(compare func(a, b *Variant) int) {
+	slices.SortStableFunc(p.Variants, compare)
+	p.buf.Reset()
+}
+
+// FirstVariantPolicy chooses the variant to list first among the non-I-frame variants,
+// since players start playback with it. It returns nil to keep the order.
+type FirstVariantPolicy func(variants []*Variant) *Variant
+
+// LowestBandwidth chooses the variant with the lowest BANDWIDTH.
+func LowestBandwidth(variants []*Variant) *Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	return slices.MinFunc(variants, CompareBandwidth)
+}
+
+// HighestBandwidth chooses the variant with the highest BANDWIDTH.
+func HighestBandwidth(variants []*Variant) *Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	return slices.MaxFunc(variants, CompareBandwidth)
+}
+
+// BandwidthAtMost returns a policy choosing the variant with the highest BANDWIDTH not
+// above limit, or the variant with the lowest BANDWIDTH if all are above it.
+func BandwidthAtMost(limit uint32) FirstVariantPolicy {
+	return func(variants []*Variant) *Variant {
+		var best *Variant
+		for _, v := range variants {
+			if v.Bandwidth <= limit && (best == nil || v.Bandwidth > best.Bandwidth) {
+				best = v
+			}
+		}
+		if best == nil {
+			return LowestBandwidth(variants)
+		}
+		return best
+	}
+}
+
+// SetFirstVariant moves the variant chosen by policy to the front of the variants,
+// keeping the order of the others, and returns it.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) SetFirstVariant(policy FirstVariantPolicy) *Variant {
+	var candidates []*Variant
+	for _, v := range p.Variants {
+		if !v.Iframe {
+			candidates = append(candidates, v)
+		}
+	}
+	first := policy(candidates)
+	if first == nil {
+		return nil
+	}
+	i := slices.Index(p.Variants, first)
+	if i < 0 {
+		return nil
+	}
+	p.Variants = slices.Insert(slices.Delete(p.Variants, i, i+1), 0, first)
+	p.buf.Reset()
+	return first
+}
+
+// LadderIssueKind is the kind of an issue found by AnalyzeLadder.
+type LadderIssueKind uint
+
+const (
+	// LadderGap is a bandwidth step between adjacent rungs above the maximum ratio
+	LadderGap LadderIssueKind = iota + 1
+	// LadderDuplicateResolution is a rung with the same resolution, frame rate, VIDEO-RANGE,
+	// codecs and audio group as another rung
+	LadderDuplicateResolution
+	// LadderAverageAboveBandwidth is a variant with AVERAGE-BANDWIDTH greater than BANDWIDTH
+	LadderAverageAboveBandwidth
+	// LadderMissingIFrame is a variant without an I-frame variant of the same resolution,
+	// VIDEO-RANGE and video codec
+	LadderMissingIFrame
+)
+
+func (k LadderIssueKind) String() string {
+	switch k {
+	case LadderGap:
+		return "Gap"
+	case LadderDuplicateResolution:
+		return "DuplicateResolution"
+	case LadderAverageAboveBandwidth:
+		return "AverageAboveBandwidth"
+	case LadderMissingIFrame:
+		return "MissingIFrame"
+	}
+	return "Unknown"
+}
+
+// LadderIssue is an issue of the ABR ladder. It is an error wrapping ErrLadderIssue.
+type LadderIssue struct {
+	Kind    LadderIssueKind // Kind of issue
+	Variant *Variant        // Variant with the issue
+	Other   *Variant        // Previous rung for a gap, first rung for a duplicate, else nil
+	Message string          // Human-readable description
+}
+
+func (i LadderIssue) Error() string {
+	return fmt.Sprintf("%s: %s", i.Kind, i.Message)
+}
+
+func (i LadderIssue) Unwrap() error {
+	return ErrLadderIssue
+}
+
+// Ladder is one ladder of variants with the same video codec and VIDEO-RANGE,
+// or of the audio-only variants, with the rungs sorted by BANDWIDTH.
+type Ladder struct {
+	VideoCodec string     // Sample entry type of the video codec, empty if unknown
+	VideoRange string     // VIDEO-RANGE, empty if not set
+	AudioOnly  bool       // Variants without video, which have no I-frame variants
+	Rungs      []*Variant // Variants by increasing BANDWIDTH
+}
+
+// LadderReport is the result of AnalyzeLadder.
+type LadderReport struct {
+	Ladders []Ladder      // Ladders in the order of their first variant
+	Issues  []LadderIssue // Issues in the order they were found
+}
+
+// IssuesOf returns the issues of the given kind.
+func (r *LadderReport) IssuesOf(kind LadderIssueKind) []LadderIssue {
+	var issues []LadderIssue
+	for _, i := range r.Issues {
+		if i.Kind == kind {
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+// Err returns the issues joined as one error, or nil if there are none.
+func (r *LadderReport) Err() error {
+	errs := make([]error, len(r.Issues))
+	for i, issue := range r.Issues {
+		errs[i] = issue
+	}
+	return errors.Join(errs...)
+}
+
+// LadderOptions configures AnalyzeLadder.
+type LadderOptions struct {
+	MaxStepRatio float64 // Largest BANDWIDTH ratio between adjacent rungs, DefaultMaxStepRatio if 0
+}
+
+// AnalyzeLadder groups the non-I-frame variants into ladders by video codec and
+// VIDEO-RANGE, and reports gaps between adjacent rungs, duplicate resolutions,
+// AVERAGE-BANDWIDTH greater than BANDWIDTH, and variants without I-frame counterpart.
+// Audio-only variants, with only audio codecs in CODECS and without RESOLUTION, form
+// a ladder of their own and need no I-frame counterpart.
+// The playlist is not changed.
+func (p *MasterPlaylist) AnalyzeLadder(opts LadderOptions) LadderReport {
+	maxRatio := opts.MaxStepRatio
+	if maxRatio == 0 {
+		maxRatio = DefaultMaxStepRatio
+	}
+	var r LadderReport
+	for _, v := range p.Variants {
+		if v.AverageBandwidth > v.Bandwidth {
+			r.Issues = append(r.Issues, LadderIssue{Kind: LadderAverageAboveBandwidth, Variant: v,
+				Message: fmt.Sprintf("%s has AVERAGE-BANDWIDTH %d above BANDWIDTH %d", v.URI,
+					v.AverageBandwidth, v.Bandwidth)})
+		}
+		if v.Iframe {
+			continue
+		}
+		codec := videoCodecType(v)
+		audioOnly := isAudioOnly(v)
+		i := slices.IndexFunc(r.Ladders, func(l Ladder) bool {
+			return l.VideoCodec == codec && l.VideoRange == v.VideoRange && l.AudioOnly == audioOnly
+		})
+		if i < 0 {
+			r.Ladders = append(r.Ladders, Ladder{VideoCodec: codec, VideoRange: v.VideoRange, AudioOnly: audioOnly})
+			i = len(r.Ladders) - 1
+		}
+		r.Ladders[i].Rungs = append(r.Ladders[i].Rungs, v)
+	}
+
+	for _, l := range r.Ladders {
+		slices.SortStableFunc(l.Rungs, CompareBandwidth)
+		for i, v := range l.Rungs {
+			if i > 0 {
+				prev := l.Rungs[i-1]
+				if prev.Bandwidth > 0 && float64(v.Bandwidth)/float64(prev.Bandwidth) > maxRatio {
+					r.Issues = append(r.Issues, LadderIssue{Kind: LadderGap, Variant: v, Other: prev,
+						Message: fmt.Sprintf("%s has %.2f times the BANDWIDTH of %s", v.URI,
+							float64(v.Bandwidth)/float64(prev.Bandwidth), prev.URI)})
+				}
+			}
+			if v.Resolution == "" {
+				continue
+			}
+			for _, o := range l.Rungs[:i] {
+				if o.Resolution == v.Resolution && o.FrameRate == v.FrameRate && o.Codecs == v.Codecs &&
+					o.Audio == v.Audio {
+					r.Issues = append(r.Issues, LadderIssue{Kind: LadderDuplicateResolution, Variant: v, Other: o,
+						Message: fmt.Sprintf("%s has the same resolution %s as %s", v.URI, v.Resolution, o.URI)})
+					break
+				}
+			}
+		}
+	}
+
+	for _, l := range r.Ladders {
+		if l.AudioOnly {
+			continue
+		}
+		for _, v := range l.Rungs {
+			if !p.hasIFrameCounterpart(v, l.VideoCodec) {
+				r.Issues = append(r.Issues, LadderIssue{Kind: LadderMissingIFrame, Variant: v,
+					Message: fmt.Sprintf("%s has no I-frame variant", v.URI)})
+			}
+		}
+	}
+	return r
+}
+
+// hasIFrameCounterpart tells if there is an I-frame variant with the resolution,
+// VIDEO-RANGE and video codec of v.
+func (p *MasterPlaylist) hasIFrameCounterpart(v *Variant, codec string) bool {
+	for _, o := range p.Variants {
+		if o.Iframe && o.Resolution == v.Resolution && o.VideoRange == v.VideoRange && videoCodecType(o) == codec {
+			return true
+		}
+	}
+	return false
+}
+
+// isAudioOnly tells if v has no RESOLUTION and only audio codecs in CODECS.
+func isAudioOnly(v *Variant) bool {
+	if v.Resolution != "" {
+		return false
+	}
+	codecs, err := v.GetCodecs()
+	if err != nil || len(codecs) == 0 {
+		return false
+	}
+	for _, c := range codecs {
+		if c.Kind() != CodecKindAudio {
+			return false
+		}
+	}
+	return true
+}
+
+// videoCodecType returns the sample entry type of the first video codec in CODECS,
+// or an empty string if there is none or CODECS cannot be parsed.
+func videoCodecType(v *Variant) string {
+	codecs, err := v.GetCodecs()
+	if err != nil {
+		return ""
+	}
+	for _, c := range codecs {
+		if c.Kind() == CodecKindVideo {
+			return c.Type
+		}
+	}
+	return ""
+}
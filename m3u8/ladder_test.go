@@ -0,0 +1,106 @@
+package m3u8
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestSortVariants(t *testing.T) {
+	is := is.New(t)
+	p := readDeviceMaster(t)
+	p.SortVariants(CompareBandwidth)
+	is.Equal(variantURIs(p), []string{"avc/720p_iframe.m3u8", "hevc/2160p_iframe.m3u8", "avc/720p.m3u8",
+		"avc/1080p.m3u8", "mvhevc/1080p.m3u8", "hevc/2160p.m3u8"})
+
+	p.SortVariants(func(a, b *Variant) int { return CompareResolution(b, a) })
+	is.Equal(variantURIs(p), []string{"hevc/2160p.m3u8", "hevc/2160p_iframe.m3u8", "mvhevc/1080p.m3u8",
+		"avc/1080p.m3u8", "avc/720p.m3u8", "avc/720p_iframe.m3u8"})
+
+	p.Variants[3].Score = 2
+	p.SortVariants(CompareScore)
+	is.Equal(p.Variants[5].URI, "avc/1080p.m3u8") // highest score last
+}
+
+func TestSetFirstVariant(t *testing.T) {
+	is := is.New(t)
+	p := readDeviceMaster(t)
+	v := p.SetFirstVariant(BandwidthAtMost(5000000))
+	is.Equal(v.URI, "avc/1080p.m3u8")
+	is.Equal(variantURIs(p), []string{"avc/1080p.m3u8", "avc/720p.m3u8", "hevc/2160p.m3u8",
+		"mvhevc/1080p.m3u8", "avc/720p_iframe.m3u8", "hevc/2160p_iframe.m3u8"})
+
+	is.Equal(p.SetFirstVariant(HighestBandwidth).URI, "hevc/2160p.m3u8") // I-frame variants are not chosen
+	is.Equal(p.SetFirstVariant(LowestBandwidth).URI, "avc/720p.m3u8")
+	is.Equal(p.SetFirstVariant(BandwidthAtMost(1000)).URI, "avc/720p.m3u8") // lowest if all are above
+	is.Equal(p.Variants[0].URI, "avc/720p.m3u8")
+	is.Equal(NewMasterPlaylist().SetFirstVariant(LowestBandwidth), nil)
+}
+
+func TestAnalyzeLadder(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	p.Append("360p.m3u8", nil, VariantParams{Bandwidth: 500000, Codecs: "avc1.64001e,mp4a.40.2",
+		Resolution: "640x360"})
+	p.Append("720p.m3u8", nil, VariantParams{Bandwidth: 1500000, AverageBandwidth: 1600000,
+		Codecs: "avc1.64001f,mp4a.40.2", Resolution: "1280x720"})
+	p.Append("720p_high.m3u8", nil, VariantParams{Bandwidth: 2000000, Codecs: "avc1.64001f,mp4a.40.2",
+		Resolution: "1280x720"})
+	p.Append("1080p.m3u8", nil, VariantParams{Bandwidth: 3500000, Codecs: "avc1.640028,mp4a.40.2",
+		Resolution: "1920x1080"})
+	p.Append("hevc_2160p.m3u8", nil, VariantParams{Bandwidth: 12000000, Codecs: "hvc1.2.4.L150.B0,mp4a.40.2",
+		Resolution: "3840x2160", VideoRange: "PQ"})
+	for _, res := range []string{"640x360", "1280x720", "1920x1080"} {
+		p.Append(res+"_iframe.m3u8", nil, VariantParams{Iframe: true, Bandwidth: 100000, Codecs: "avc1.64001f",
+			Resolution: res})
+	}
+
+	r := p.AnalyzeLadder(LadderOptions{})
+	is.Equal(len(r.Ladders), 2) // AVC SDR and HEVC PQ ladders
+	is.Equal(r.Ladders[0].VideoCodec, CodecAVC1)
+	is.Equal(len(r.Ladders[0].Rungs), 4)
+	is.Equal(r.Ladders[1].VideoRange, "PQ")
+
+	gaps := r.IssuesOf(LadderGap)
+	is.Equal(len(gaps), 1) // 360p to 720p is a step of 3
+	is.Equal(gaps[0].Variant.URI, "720p.m3u8")
+	is.Equal(gaps[0].Other.URI, "360p.m3u8")
+	dups := r.IssuesOf(LadderDuplicateResolution)
+	is.Equal(len(dups), 1)
+	is.Equal(dups[0].Variant.URI, "720p_high.m3u8")
+	avg := r.IssuesOf(LadderAverageAboveBandwidth)
+	is.Equal(len(avg), 1)
+	is.Equal(avg[0].Variant.URI, "720p.m3u8")
+	missing := r.IssuesOf(LadderMissingIFrame)
+	is.Equal(len(missing), 1)
+	is.Equal(missing[0].Variant.URI, "hevc_2160p.m3u8")
+
+	err := r.Err()
+	is.True(errors.Is(err, ErrLadderIssue))
+	var issue LadderIssue
+	is.True(errors.As(err, &issue))
+	is.Equal(issue.Error(), "AverageAboveBandwidth: 720p.m3u8 has AVERAGE-BANDWIDTH 1600000 above BANDWIDTH 1500000")
+
+	r = p.AnalyzeLadder(LadderOptions{MaxStepRatio: 4})
+	is.Equal(len(r.IssuesOf(LadderGap)), 0)  // no gap with a higher ratio
+	is.Equal(p.Variants[0].URI, "360p.m3u8") // playlist must not be changed
+}
+
+func TestAnalyzeLadderAudioOnly(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	p.Append("720p.m3u8", nil, VariantParams{Bandwidth: 1500000, Codecs: "avc1.64001f,mp4a.40.2",
+		Resolution: "1280x720"})
+	p.Append("720p_iframe.m3u8", nil, VariantParams{Iframe: true, Bandwidth: 100000, Codecs: "avc1.64001f",
+		Resolution: "1280x720"})
+	p.Append("audio_64k.m3u8", nil, VariantParams{Bandwidth: 64000, Codecs: "mp4a.40.2"})
+	p.Append("audio_128k.m3u8", nil, VariantParams{Bandwidth: 128000, Codecs: "mp4a.40.2"})
+
+	r := p.AnalyzeLadder(LadderOptions{})
+	is.Equal(len(r.Ladders), 2) // audio-only variants must have their own ladder
+	is.True(r.Ladders[1].AudioOnly)
+	is.Equal(len(r.Ladders[1].Rungs), 2)
+	is.Equal(r.Ladders[1].Rungs[0].URI, "audio_64k.m3u8")
+	is.NoErr(r.Err()) // no gap to the video ladder and no missing I-frame variants
+}
@@ -0,0 +1,146 @@
+package m3u8
+
+/*
+ This file defines automatic generation of EXT-X-PROGRAM-DATE-TIME when appending segments.
+*/
+
+import (
+	"time"
+)
+
+// ProgramDateTimeMode selects the segments that get an EXT-X-PROGRAM-DATE-TIME
+// from a ProgramDateTimePolicy.
+type ProgramDateTimeMode uint
+
+const (
+	// ProgramDateTimeOff turns automatic program date times off
+	ProgramDateTimeOff ProgramDateTimeMode = iota
+	// ProgramDateTimeEverySegment stamps every segment
+	ProgramDateTimeEverySegment
+	// ProgramDateTimeAfterDiscontinuity stamps the first segment and every segment with an EXT-X-DISCONTINUITY
+	ProgramDateTimeAfterDiscontinuity
+	// ProgramDateTimeInterval stamps the first segment and then the first segment starting
+	// at least Interval seconds after the previous stamp
+	ProgramDateTimeInterval
+)
+
+// ProgramDateTimePolicy makes a media playlist set ProgramDateTime automatically on
+// appended segments. The program date time of a segment is the Anchor, or the last explicit
+// program date time, plus the durations of the segments appended before it since then.
+// A program date time set explicitly, either on a segment before it is appended or with
+// SetProgramDateTime, is kept and the following segments continue from it, so that
+// encoders can resynchronize the clock.
+type ProgramDateTimePolicy struct {
+	Mode ProgramDateTimeMode
+	// Anchor is the program date time of the next segment appended. If zero, it continues
+	// from the end of the last segment of the playlist with a program date time.
+	Anchor time.Time
+	// Interval is the minimum time in seconds between stamped segments for ProgramDateTimeInterval.
+	Interval float64
+	// PartialSegments also stamps every partial segment with the program date time of its start.
+	PartialSegments bool
+}
+
+// dateTimeClock keeps track of program date times while appending segments.
+type dateTimeClock struct {
+	policy    ProgramDateTimePolicy
+	last      time.Time // start of the last appended segment
+	next      time.Time // start of the next segment
+	lastStamp time.Time // start of the last segment with a program date time
+}
+
+// SetProgramDateTimePolicy sets the policy for automatic EXT-X-PROGRAM-DATE-TIME on
+// segments appended from now on. ErrNoProgramDateTime is returned if the policy has no
+// Anchor and no segment of the playlist has a program date time to continue from.
+func (p *MediaPlaylist) SetProgramDateTimePolicy(policy ProgramDateTimePolicy) error {
+	if policy.Mode == ProgramDateTimeOff {
+		p.pdtClock = nil
+		return nil
+	}
+	next := policy.Anchor
+	if next.IsZero() {
+		segs := p.GetAllSegments()
+		pdts := programDateTimes(segs)
+		if pdts == nil {
+			return ErrNoProgramDateTime
+		}
+		last := len(segs) - 1
+		next = pdts[last].Add(durationOf(segs[last].Duration))
+	}
+	p.pdtClock = &dateTimeClock{policy: policy, next: next}
+	return nil
+}
+
+// ProgramDateTimePolicy returns the policy for automatic EXT-X-PROGRAM-DATE-TIME.
+func (p *MediaPlaylist) ProgramDateTimePolicy() ProgramDateTimePolicy {
+	if p.pdtClock == nil {
+		return ProgramDateTimePolicy{}
+	}
+	return p.pdtClock.policy
+}
+
+// stampSegment advances the clock over a segment being appended, and sets its program
+// date time if the policy asks for it.
+func (c *dateTimeClock) stampSegment(seg *MediaSegment) {
+	start := c.next
+	switch {
+	case !seg.ProgramDateTime.IsZero():
+		start = seg.ProgramDateTime
+		c.lastStamp = start
+	case c.due(start, seg.Discontinuity):
+		seg.ProgramDateTime = start
+		c.lastStamp = start
+	}
+	c.last = start
+	c.next = start.Add(durationOf(seg.Duration))
+}
+
+// due tells whether a segment starting at start gets a program date time.
+func (c *dateTimeClock) due(start time.Time, discontinuity bool) bool {
+	if c.lastStamp.IsZero() {
+		return true
+	}
+	switch c.policy.Mode {
+	case ProgramDateTimeEverySegment:
+		return true
+	case ProgramDateTimeAfterDiscontinuity:
+		return discontinuity
+	case ProgramDateTimeInterval:
+		return start.Sub(c.lastStamp) >= durationOf(c.policy.Interval)
+	}
+	return false
+}
+
+// stampDiscontinuity sets the program date time of the last segment, which has just
+// got an EXT-X-DISCONTINUITY.
+func (c *dateTimeClock) stampDiscontinuity(seg *MediaSegment) {
+	if c.policy.Mode == ProgramDateTimeAfterDiscontinuity && seg.ProgramDateTime.IsZero() {
+		seg.ProgramDateTime = c.last
+		c.lastStamp = c.last
+	}
+}
+
+// resync continues the clock from a program date time set on the last segment.
+func (c *dateTimeClock) resync(seg *MediaSegment) {
+	c.last = seg.ProgramDateTime
+	c.next = seg.ProgramDateTime.Add(durationOf(seg.Duration))
+	c.lastStamp = seg.ProgramDateTime
+}
+
+// stampPartialSegment sets the program date time of a partial segment being appended,
+// which starts after the earlier partial segments of the same full segment.
+func (c *dateTimeClock) stampPartialSegment(p *MediaPlaylist, ps *PartialSegment) {
+	if !c.policy.PartialSegments || !ps.ProgramDateTime.IsZero() {
+		return
+	}
+	start := c.next
+	if p.count > 0 && ps.SeqID == p.Segments[p.last()].SeqId {
+		start = c.last
+	}
+	for _, prev := range p.PartialSegments {
+		if prev.SeqID == ps.SeqID {
+			start = start.Add(durationOf(prev.Duration))
+		}
+	}
+	ps.ProgramDateTime = start
+}
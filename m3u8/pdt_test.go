@@ -0,0 +1,124 @@
+package m3u8
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+var pdtAnchor = time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// appendPDTSegments appends n segments of 4s and returns their program date times.
+func appendPDTSegments(t *testing.T, p *MediaPlaylist, n int, discontinuityAt int) []time.Time {
+	is := is.New(t)
+	var pdts []time.Time
+	for i := 0; i < n; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("seg%d.ts", i), 4, ""))
+		if i == discontinuityAt {
+			is.NoErr(p.SetDiscontinuity())
+		}
+	}
+	for _, seg := range p.GetAllSegments() {
+		pdts = append(pdts, seg.ProgramDateTime)
+	}
+	return pdts
+}
+
+func TestProgramDateTimePolicy(t *testing.T) {
+	at := func(seconds int) time.Time { return pdtAnchor.Add(time.Duration(seconds) * time.Second) }
+	cases := []struct {
+		desc   string
+		policy ProgramDateTimePolicy
+		want   []time.Time
+	}{
+		{
+			desc:   "every segment",
+			policy: ProgramDateTimePolicy{Mode: ProgramDateTimeEverySegment, Anchor: pdtAnchor},
+			want:   []time.Time{at(0), at(4), at(8), at(12), at(16), at(20), at(24)},
+		},
+		{
+			desc:   "after discontinuity",
+			policy: ProgramDateTimePolicy{Mode: ProgramDateTimeAfterDiscontinuity, Anchor: pdtAnchor},
+			want:   []time.Time{at(0), {}, {}, at(12), {}, {}, {}},
+		},
+		{
+			desc:   "interval",
+			policy: ProgramDateTimePolicy{Mode: ProgramDateTimeInterval, Anchor: pdtAnchor, Interval: 10},
+			want:   []time.Time{at(0), {}, {}, at(12), {}, {}, at(24)},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			is := is.New(t)
+			p, err := NewMediaPlaylist(0, 10)
+			is.NoErr(err)
+			is.NoErr(p.SetProgramDateTimePolicy(c.policy))
+			is.Equal(p.ProgramDateTimePolicy(), c.policy)
+			is.Equal(appendPDTSegments(t, p, 7, 3), c.want)
+		})
+	}
+}
+
+func TestProgramDateTimePolicyResync(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	is.NoErr(p.SetProgramDateTimePolicy(ProgramDateTimePolicy{Mode: ProgramDateTimeEverySegment, Anchor: pdtAnchor}))
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+	is.NoErr(p.SetProgramDateTime(pdtAnchor.Add(time.Minute))) // encoder clock jumps
+	is.NoErr(p.Append("seg1.ts", 4, ""))
+	is.Equal(p.Segments[1].ProgramDateTime, pdtAnchor.Add(time.Minute+4*time.Second)) // continues from explicit value
+
+	seg := GetSegment()
+	seg.URI = "seg2.ts"
+	seg.Duration = 4
+	seg.ProgramDateTime = pdtAnchor.Add(time.Hour)
+	is.NoErr(p.AppendSegment(seg))
+	is.NoErr(p.Append("seg3.ts", 4, ""))
+	is.Equal(p.Segments[3].ProgramDateTime, pdtAnchor.Add(time.Hour+4*time.Second)) // continues from segment value
+
+	is.NoErr(p.SetProgramDateTimePolicy(ProgramDateTimePolicy{}))
+	is.NoErr(p.Append("seg4.ts", 4, ""))
+	is.True(p.Segments[4].ProgramDateTime.IsZero()) // policy turned off
+}
+
+func TestProgramDateTimePolicyContinue(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	policy := ProgramDateTimePolicy{Mode: ProgramDateTimeEverySegment}
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+	is.True(errors.Is(p.SetProgramDateTimePolicy(policy), ErrNoProgramDateTime)) // nothing to continue from
+
+	is.NoErr(p.SetProgramDateTime(pdtAnchor))
+	is.NoErr(p.Append("seg1.ts", 6, ""))
+	is.NoErr(p.SetProgramDateTimePolicy(policy))
+	is.NoErr(p.Append("seg2.ts", 4, ""))
+	is.Equal(p.Segments[2].ProgramDateTime, pdtAnchor.Add(10*time.Second)) // continues after the last segment
+}
+
+func TestProgramDateTimePolicyPartialSegments(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 10)
+	is.NoErr(err)
+	is.NoErr(p.SetProgramDateTimePolicy(ProgramDateTimePolicy{
+		Mode:            ProgramDateTimeAfterDiscontinuity,
+		Anchor:          pdtAnchor,
+		PartialSegments: true,
+	}))
+	is.NoErr(p.Append("fileSequence0.m4s", 4, ""))
+	is.NoErr(p.AppendPartial("filePart1.1.m4s", 1, true))
+	is.NoErr(p.AppendPartial("filePart1.2.m4s", 1.5, false))
+	is.NoErr(p.AppendPartial("filePart1.3.m4s", 1.5, false))
+	is.NoErr(p.Append("fileSequence1.m4s", 4, ""))
+	is.NoErr(p.AppendPartial("filePart2.1.m4s", 1, true))
+
+	is.Equal(p.PartialSegments[0].ProgramDateTime, pdtAnchor.Add(4*time.Second))
+	is.Equal(p.PartialSegments[1].ProgramDateTime, pdtAnchor.Add(5*time.Second))
+	is.Equal(p.PartialSegments[2].ProgramDateTime, pdtAnchor.Add(6500*time.Millisecond))
+	is.Equal(p.PartialSegments[3].ProgramDateTime, pdtAnchor.Add(8*time.Second))
+	is.True(p.Segments[1].ProgramDateTime.IsZero()) // full segment follows the mode
+}
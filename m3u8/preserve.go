@@ -0,0 +1,105 @@
+package m3u8
+
+/*
+ This file defines the preserve mode, which keeps unknown tags and attributes when
+ decoding, so that they are written again by Encode.
+*/
+
+import "strings"
+
+// knownTags are the tags decoded by this package. Other tags are unknown, and are
+// kept in preserve mode unless a CustomDecoder handles them.
+var knownTags = map[string]bool{
+	"#EXTM3U":                       true,
+	"#EXTINF":                       true,
+	"#EXT-X-VERSION":                true,
+	"#EXT-X-INDEPENDENT-SEGMENTS":   true,
+	"#EXT-X-START":                  true,
+	"#EXT-X-DEFINE":                 true,
+	"#EXT-X-TARGETDURATION":         true,
+	"#EXT-X-MEDIA-SEQUENCE":         true,
+	"#EXT-X-DISCONTINUITY-SEQUENCE": true,
+	"#EXT-X-PLAYLIST-TYPE":          true,
+	"#EXT-X-ENDLIST":                true,
+	"#EXT-X-I-FRAMES-ONLY":          true,
+	"#EXT-X-ALLOW-CACHE":            true,
+	"#EXT-X-PART-INF":               true,
+	"#EXT-X-SERVER-CONTROL":         true,
+	"#EXT-X-SKIP":                   true,
+	"#EXT-X-PART":                   true,
+	"#EXT-X-PRELOAD-HINT":           true,
+	"#EXT-X-KEY":                    true,
+	"#EXT-X-MAP":                    true,
+	"#EXT-X-PROGRAM-DATE-TIME":      true,
+	"#EXT-X-BYTERANGE":              true,
+	"#EXT-X-DISCONTINUITY":          true,
+	"#EXT-X-GAP":                    true,
+	"#EXT-X-DATERANGE":              true,
+	"#EXT-SCTE35":                   true,
+	"#EXT-OATCLS-SCTE35":            true,
+	"#EXT-X-CUE-OUT":                true,
+	"#EXT-X-CUE-OUT-CONT":           true,
+	"#EXT-X-CUE-IN":                 true,
+	"#EXT-X-MEDIA":                  true,
+	"#EXT-X-STREAM-INF":             true,
+	"#EXT-X-I-FRAME-STREAM-INF":     true,
+	"#EXT-X-SESSION-DATA":           true,
+	"#EXT-X-SESSION-KEY":            true,
+	"#EXT-X-CONTENT-STEERING":       true,
+}
+
+// SetPreserveUnknown sets the preserve mode for decoding. In preserve mode, tags that
+// are not known to this package, such as EXT-X-RENDITION-REPORT, are kept in UnknownTags
+// of the playlist header and of segments, and in TrailingUnknownTags after the last
+// segment. Unknown attributes of EXT-X-KEY, EXT-X-MAP, EXT-X-PART, EXT-X-PRELOAD-HINT and
+// EXT-X-SERVER-CONTROL are kept in their UnknownAttrs, and those of EXT-X-START in
+// StartUnknownAttrs. Encode writes them again, so that vendor extensions survive a decode
+// and encode round trip. Tags handled by a CustomDecoder are not unknown.
+//
+// The round trip has limits: unknown tags among the header tags are written after the
+// known ones, unknown attributes are written after the known ones, and EXT-X-SKIP is
+// written from the number of skipped segments, without other attributes.
+func (p *MediaPlaylist) SetPreserveUnknown(preserve bool) {
+	p.preserveUnknown = preserve
+}
+
+// PreserveUnknown tells whether unknown tags and attributes are kept when decoding.
+func (p *MediaPlaylist) PreserveUnknown() bool {
+	return p.preserveUnknown
+}
+
+// SetPreserveUnknown sets the preserve mode for decoding. In preserve mode, tags that
+// are not known to this package are kept in UnknownTags of the playlist header, of the
+// EXT-X-MEDIA rendition or variant they precede, and in TrailingUnknownTags after the
+// last variant. Unknown attributes of EXT-X-STREAM-INF, EXT-X-I-FRAME-STREAM-INF,
+// EXT-X-MEDIA, EXT-X-SESSION-KEY and EXT-X-SESSION-DATA are kept in their UnknownAttrs,
+// and those of EXT-X-START in StartUnknownAttrs. Encode writes them again. Tags handled
+// by a CustomDecoder are not unknown.
+//
+// The round trip has limits: unknown tags among the header tags are written after the
+// known ones, unknown attributes are written after the known ones, and the attributes
+// of EXT-X-CONTENT-STEERING are not kept.
+func (p *MasterPlaylist) SetPreserveUnknown(preserve bool) {
+	p.preserveUnknown = preserve
+}
+
+// PreserveUnknown tells whether unknown tags and attributes are kept when decoding.
+func (p *MasterPlaylist) PreserveUnknown() bool {
+	return p.preserveUnknown
+}
+
+// isUnknownTag tells whether line is a tag not known to this package. Comments are not tags.
+func isUnknownTag(line string) bool {
+	if !strings.HasPrefix(line, "#EXT") {
+		return false
+	}
+	name, _, _ := strings.Cut(line, ":")
+	return !knownTags[name]
+}
+
+// segmentTagsPending tells whether tags for the next media segment have been decoded.
+// EXT-X-KEY is not counted, since keys before the first segment are playlist keys.
+func (s *decodingState) segmentTagsPending() bool {
+	return s.tagInf || s.tagRange || s.tagDiscontinuity || s.tagProgramDateTime || s.tagGap ||
+		s.tagSCTE35 || s.tagCustom || len(s.scte35DateRanges) > 0
+}
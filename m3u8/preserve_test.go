@@ -0,0 +1,166 @@
+package m3u8
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestDecodePreserveUnknownRoundTrip(t *testing.T) {
+	for _, fileName := range []string{
+		"media-playlist-with-unknown-tags.m3u8",
+		"master-with-unknown-tags.m3u8",
+		"media-playlist-ll-with-unknown-attrs.m3u8",
+		"master-with-unknown-positions.m3u8",
+	} {
+		t.Run(fileName, func(t *testing.T) {
+			is := is.New(t)
+			inData, err := os.ReadFile("sample-playlists/" + fileName)
+			is.NoErr(err)
+			p, _, err := DecodePreserveUnknown(bytes.NewBuffer(inData), true, nil)
+			is.NoErr(err)
+			got := trimLineEnd(p.String())
+			want := trimLineEnd(string(inData))
+			if got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+
+			p, _, err = DecodeFrom(bytes.NewBuffer(inData), true)
+			is.NoErr(err)
+			is.True(!strings.Contains(p.String(), "VENDOR")) // dropped without preserve mode
+		})
+	}
+}
+
+func TestMediaPlaylistPreserveUnknown(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-with-unknown-tags.m3u8")
+	is.NoErr(err)
+	defer f.Close()
+	p, err := NewMediaPlaylist(0, 5)
+	is.NoErr(err)
+	p.SetPreserveUnknown(true)
+	is.True(p.PreserveUnknown())
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true))
+
+	is.Equal(p.UnknownTags, []string{`#EXT-X-VENDOR-HEADER:ID="channel-1"`, "#EXT-X-VENDOR-FLAG"})
+	is.Equal(p.Segments[0].UnknownTags, []string{"#EXT-X-VENDOR-AD:TYPE=MID,ID=1"})
+	is.Equal(len(p.Segments[1].UnknownTags), 2)
+	is.Equal(p.TrailingUnknownTags, []string{`#EXT-X-RENDITION-REPORT:URI="../low/playlist.m3u8",LAST-MSN=11`})
+	is.Equal(p.Keys[0].UnknownAttrs, []Attribute{{Key: "VENDOR-KEY-ID", Val: `"abc"`}})
+
+	c := p.Clone()
+	c.Segments[0].UnknownTags[0] = "#EXT-X-CHANGED"
+	is.Equal(p.Segments[0].UnknownTags[0], "#EXT-X-VENDOR-AD:TYPE=MID,ID=1") // deep copy
+}
+
+func TestMasterPlaylistPreserveUnknown(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-with-unknown-tags.m3u8")
+	is.NoErr(err)
+	defer f.Close()
+	p := NewMasterPlaylist()
+	p.SetPreserveUnknown(true)
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true))
+
+	is.Equal(p.UnknownTags, []string{`#EXT-X-VENDOR-HEADER:ID="master-1"`})
+	is.Equal(p.Variants[0].UnknownTags, []string{"#EXT-X-VENDOR-VARIANT:TIER=1"})
+	is.Equal(p.Variants[0].UnknownAttrs, []Attribute{{Key: "VENDOR-TIER", Val: "1"}, {Key: "X-LABEL", Val: `"low"`}})
+	is.Equal(p.Variants[0].Alternatives[0].UnknownAttrs, []Attribute{{Key: "VENDOR-MIX", Val: `"stereo"`}})
+	is.Equal(p.SessionKeys[0].UnknownAttrs, []Attribute{{Key: "X-VENDOR", Val: `"1"`}})
+	is.Equal(p.TrailingUnknownTags, []string{"#EXT-X-VENDOR-END"})
+}
+
+func TestPreserveUnknownWithCustomDecoder(t *testing.T) {
+	is := is.New(t)
+	const playlist = `#EXTM3U
+#EXT-X-TARGETDURATION:4
+# a comment
+#EXTINF:4.000,
+seg0.ts
+#EXT-X-VENDOR-AD:ID=1
+#EXT-X-VENDOR-OTHER:ID=2
+#EXTINF:4.000,
+seg1.ts
+`
+	decoder := &MockCustomTag{name: "#EXT-X-VENDOR-AD:", segment: true, encodedString: "#EXT-X-VENDOR-AD:ID=1"}
+	pl, listType, err := DecodePreserveUnknown(bytes.NewBufferString(playlist), false, []CustomDecoder{decoder})
+	is.NoErr(err)
+	is.Equal(listType, MEDIA)
+	p := pl.(*MediaPlaylist)
+	is.Equal(len(p.UnknownTags), 0)                                           // comments are not tags
+	is.Equal(p.Segments[1].UnknownTags, []string{"#EXT-X-VENDOR-OTHER:ID=2"}) // custom tag is not unknown
+	is.Equal(strings.Count(p.String(), "#EXT-X-VENDOR-AD:"), 1)
+}
+
+func TestMasterPlaylistPreserveUnknownPositions(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-with-unknown-positions.m3u8")
+	is.NoErr(err)
+	defer f.Close()
+	p := NewMasterPlaylist()
+	p.SetPreserveUnknown(true)
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true))
+
+	alts := p.Variants[0].Alternatives
+	is.Equal(len(alts[0].UnknownTags), 0)
+	is.Equal(alts[1].UnknownTags, []string{"#EXT-X-VENDOR-TRACK:ID=2"}) // kept before its rendition
+	is.Equal(p.SessionDatas[0].UnknownAttrs, []Attribute{{Key: "X-VENDOR-SOURCE", Val: `"cms"`}})
+	is.Equal(p.StartUnknownAttrs, []Attribute{{Key: "X-VENDOR-START", Val: `"s"`}})
+
+	c := p.Clone()
+	c.Variants[0].Alternatives[1].UnknownTags[0] = "#EXT-X-CHANGED"
+	c.SessionDatas[0].UnknownAttrs[0].Val = "changed"
+	is.Equal(alts[1].UnknownTags[0], "#EXT-X-VENDOR-TRACK:ID=2") // deep copy
+	is.Equal(p.SessionDatas[0].UnknownAttrs[0].Val, `"cms"`)
+}
+
+func TestMediaPlaylistPreserveUnknownAttrs(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-ll-with-unknown-attrs.m3u8")
+	is.NoErr(err)
+	defer f.Close()
+	p, err := NewMediaPlaylist(0, 5)
+	is.NoErr(err)
+	p.SetPreserveUnknown(true)
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true))
+
+	is.Equal(p.ServerControl.UnknownAttrs, []Attribute{{Key: "X-VENDOR-HINT", Val: `"a"`}})
+	is.Equal(p.StartUnknownAttrs, []Attribute{{Key: "X-VENDOR-START", Val: "1"}})
+	is.Equal(p.Map.UnknownAttrs, []Attribute{{Key: "X-VENDOR-INIT", Val: `"v1"`}})
+	is.Equal(p.PartialSegments[0].UnknownAttrs, []Attribute{{Key: "X-VENDOR-PART", Val: "1"}})
+	is.True(p.PartialSegments[1].Gap)
+	is.Equal(p.PreloadHints.UnknownAttrs, []Attribute{{Key: "X-VENDOR-PRELOAD", Val: `"p"`}})
+	// EXT-X-RENDITION-REPORT is not decoded, so it is kept as an unknown tag
+	is.Equal(p.TrailingUnknownTags, []string{`#EXT-X-RENDITION-REPORT:URI="../low/playlist.m3u8",LAST-MSN=20,LAST-PART=1`})
+
+	c := p.Clone()
+	c.Map.UnknownAttrs[0].Val = "changed"
+	c.PartialSegments[0].UnknownAttrs[0].Val = "changed"
+	is.Equal(p.Map.UnknownAttrs[0].Val, `"v1"`) // deep copy
+	is.Equal(p.PartialSegments[0].UnknownAttrs[0].Val, "1")
+}
+
+// EXT-X-SKIP is written from the number of skipped segments, so unknown attributes
+// of it are not kept
+func TestPreserveUnknownSkipLimit(t *testing.T) {
+	is := is.New(t)
+	const playlist = `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-SERVER-CONTROL:CAN-SKIP-UNTIL=12.000
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:4
+#EXT-X-SKIP:SKIPPED-SEGMENTS=3,X-VENDOR-SKIP=1
+#EXTINF:4.000,
+seg13.ts
+`
+	p, _, err := DecodePreserveUnknown(bytes.NewBufferString(playlist), true, nil)
+	is.NoErr(err)
+	out := p.String()
+	is.True(strings.Contains(out, "#EXT-X-SKIP:SKIPPED-SEGMENTS=3\n"))
+	is.True(!strings.Contains(out, "X-VENDOR-SKIP"))
+}
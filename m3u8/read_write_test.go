@@ -382,7 +382,7 @@ func TestReadWriteExtXStart(t *testing.T) {
 	}
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
-			start, precise, err := parseExtXStartParams(c.line[len("#EXT-X-START:"):])
+			start, precise, _, err := parseExtXStartParams(c.line[len("#EXT-X-START:"):])
 			if c.error {
 				is.Equal(err != nil, true) // must return an error
 				return
@@ -391,7 +391,7 @@ func TestReadWriteExtXStart(t *testing.T) {
 			out := bytes.Buffer{}
 			is.Equal(c.start, start)     // start time must match
 			is.Equal(c.precise, precise) // precise must match
-			writeExtXStart(&out, start, precise, DefaultFloatPrecision)
+			writeExtXStart(&out, start, precise, nil, DefaultFloatPrecision)
 			outStr := trimLineEnd(out.String())
 			is.Equal(c.line, outStr) // EXT-X-START line must match
 		})
@@ -415,6 +415,7 @@ func TestReadWritePlaylists(t *testing.T) {
 		"media-playlist-low-latency.m3u8",
 		"media-playlist-with-skip.m3u8",
 		"media-playlist-trailing-scte35-daterange.m3u8",
+		"master-with-rendition-groups.m3u8",
 	}
 
 	for _, fileName := range files {
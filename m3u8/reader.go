@@ -82,6 +82,7 @@ func (p *MasterPlaylist) decode(buf *bytes.Buffer, strict bool) error {
 	}
 
 	p.attachRenditionsToVariants(state.alternatives)
+	p.storeTrailingUnknownTags(state)
 
 	if strict && !state.m3u {
 		return ErrExtM3UAbsent
@@ -89,29 +90,32 @@ func (p *MasterPlaylist) decode(buf *bytes.Buffer, strict bool) error {
 	return nil
 }
 
+// takeUnknown moves the unknown tags before a variant from the decoding state to the
+// variant, and drops its unknown attributes unless in preserve mode.
+func (p *MasterPlaylist) takeUnknown(state *decodingState, variant *Variant) {
+	variant.UnknownTags = state.unknownTags
+	state.unknownTags = nil
+	if !p.preserveUnknown {
+		variant.UnknownAttrs = nil
+	}
+}
+
+// storeTrailingUnknownTags moves unknown tags left in the decoding state after the
+// last variant to the playlist's TrailingUnknownTags.
+func (p *MasterPlaylist) storeTrailingUnknownTags(state *decodingState) {
+	p.TrailingUnknownTags = append(p.TrailingUnknownTags, state.unknownTags...)
+	state.unknownTags = nil
+}
+
+// attachRenditionsToVariants adds the decoded renditions to their groups, including
+// renditions no variant refers to, and derives the alternatives of the variants.
 func (p *MasterPlaylist) attachRenditionsToVariants(alternatives []*Alternative) {
-	for _, variant := range p.Variants {
-		if variant.Iframe {
-			continue
-		}
-		for _, alt := range alternatives {
-			if alt == nil {
-				continue
-			}
-			if variant.Video != "" && alt.Type == "VIDEO" && variant.Video == alt.GroupId {
-				variant.Alternatives = append(variant.Alternatives, alt)
-			}
-			if variant.Audio != "" && alt.Type == "AUDIO" && variant.Audio == alt.GroupId {
-				variant.Alternatives = append(variant.Alternatives, alt)
-			}
-			if variant.Captions != "" && alt.Type == "CLOSED-CAPTIONS" && variant.Captions == alt.GroupId {
-				variant.Alternatives = append(variant.Alternatives, alt)
-			}
-			if variant.Subtitles != "" && alt.Type == "SUBTITLES" && variant.Subtitles == alt.GroupId {
-				variant.Alternatives = append(variant.Alternatives, alt)
-			}
+	for _, alt := range alternatives {
+		if alt != nil {
+			p.addRendition(alt)
 		}
 	}
+	p.UpdateAlternatives()
 }
 
 // Version returns the HLS protocol version as signaled by EXT-X-VERSION
@@ -251,6 +255,7 @@ func (p *MediaPlaylist) decode(buf *bytes.Buffer, strict bool) error {
 		return ErrExtM3UAbsent
 	}
 	p.storeTrailingDateRanges(state)
+	p.storeTrailingUnknownTags(state)
 	return nil
 }
 
@@ -265,9 +270,16 @@ func (p *MediaPlaylist) storeTrailingDateRanges(state *decodingState) {
 	p.scte35Syntax = SCTE35_DATERANGE
 }
 
+// storeTrailingUnknownTags moves unknown tags left in the decoding state after the
+// last segment to the playlist's TrailingUnknownTags.
+func (p *MediaPlaylist) storeTrailingUnknownTags(state *decodingState) {
+	p.TrailingUnknownTags = append(p.TrailingUnknownTags, state.unknownTags...)
+	state.unknownTags = nil
+}
+
 // Decode detects type of playlist and decodes it.
 func Decode(data bytes.Buffer, strict bool) (Playlist, ListType, error) {
-	return decode(&data, strict, nil)
+	return decode(&data, strict, nil, false)
 }
 
 // DecodeFrom detects type of playlist and decodes it.
@@ -277,22 +289,34 @@ func DecodeFrom(reader io.Reader, strict bool) (Playlist, ListType, error) {
 	if err != nil {
 		return nil, 0, err
 	}
-	return decode(buf, strict, nil)
+	return decode(buf, strict, nil, false)
 }
 
 // DecodeWith detects the type of playlist and decodes it. It accepts either bytes.Buffer
 // or io.Reader as input. Any custom decoders provided will be used during decoding.
 func DecodeWith(input interface{}, strict bool, customDecoders []CustomDecoder) (Playlist, ListType, error) {
+	return decodeInput(input, strict, customDecoders, false)
+}
+
+// DecodePreserveUnknown detects the type of playlist and decodes it like DecodeWith, in
+// preserve mode, keeping unknown tags and attributes, see MediaPlaylist.SetPreserveUnknown
+// and MasterPlaylist.SetPreserveUnknown for what is kept and the limits of the round trip.
+func DecodePreserveUnknown(input interface{}, strict bool, customDecoders []CustomDecoder) (Playlist, ListType, error) {
+	return decodeInput(input, strict, customDecoders, true)
+}
+
+func decodeInput(input interface{}, strict bool, customDecoders []CustomDecoder, preserveUnknown bool) (Playlist,
+	ListType, error) {
 	switch v := input.(type) {
 	case bytes.Buffer:
-		return decode(&v, strict, customDecoders)
+		return decode(&v, strict, customDecoders, preserveUnknown)
 	case io.Reader:
 		buf := new(bytes.Buffer)
 		_, err := buf.ReadFrom(v)
 		if err != nil {
 			return nil, 0, err
 		}
-		return decode(buf, strict, customDecoders)
+		return decode(buf, strict, customDecoders, preserveUnknown)
 	default:
 		return nil, 0, fmt.Errorf("input must be bytes.Buffer or io.Reader type, got %T", input)
 	}
@@ -300,7 +324,8 @@ func DecodeWith(input interface{}, strict bool, customDecoders []CustomDecoder)
 
 // Detect playlist type and decode it. May be used as decoder for both
 // master and media playlists.
-func decode(buf *bytes.Buffer, strict bool, customDecoders []CustomDecoder) (Playlist, ListType, error) {
+func decode(buf *bytes.Buffer, strict bool, customDecoders []CustomDecoder, preserveUnknown bool) (Playlist,
+	ListType, error) {
 	var eof bool
 	var line string
 	var master *MasterPlaylist
@@ -315,12 +340,13 @@ func decode(buf *bytes.Buffer, strict bool, customDecoders []CustomDecoder) (Pla
 	if err != nil {
 		return nil, 0, fmt.Errorf("create media playlist failed: %w", err)
 	}
+	master.preserveUnknown = preserveUnknown
+	media.preserveUnknown = preserveUnknown
 
 	// If we have custom tags to parse
 	if customDecoders != nil {
 		media = media.WithCustomDecoders(customDecoders).(*MediaPlaylist)
 		master = master.WithCustomDecoders(customDecoders).(*MasterPlaylist)
-		state.custom = make(CustomMap)
 	}
 
 	for !eof {
@@ -357,6 +383,7 @@ func decode(buf *bytes.Buffer, strict bool, customDecoders []CustomDecoder) (Pla
 	switch state.listType {
 	case MASTER:
 		master.attachRenditionsToVariants(state.alternatives)
+		master.storeTrailingUnknownTags(state)
 		return master, MASTER, nil
 	case MEDIA:
 		if media.Closed || media.MediaType == EVENT {
@@ -364,6 +391,7 @@ func decode(buf *bytes.Buffer, strict bool, customDecoders []CustomDecoder) (Pla
 			_ = media.SetWinSize(0)
 		}
 		media.storeTrailingDateRanges(state)
+		media.storeTrailingUnknownTags(state)
 		return media, MEDIA, nil
 	}
 	return nil, state.listType, ErrCannotDetectPlaylistType
@@ -382,17 +410,20 @@ func decodeAndTrimAttributes(line string) map[string]string {
 // Parse one line of master playlist.
 func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line string, strict bool) error {
 	var err error
+	var decodedCustom bool
 
 	// check for custom tags first to allow custom parsing of existing tags
 	if p.Custom != nil {
 		for _, v := range p.customDecoders {
 			if strings.HasPrefix(line, v.TagName()) {
+				decodedCustom = true
 				t, err := v.Decode(line)
 
 				if strict && err != nil {
 					return err
 				}
 				p.Custom[t.TagName()] = t
+				p.customOrder = append(p.customOrder, t)
 			}
 		}
 	}
@@ -406,10 +437,14 @@ func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line st
 			return err
 		}
 	case strings.HasPrefix(line, "#EXT-X-START:"):
-		p.StartTime, p.StartTimePrecise, err = parseExtXStartParams(line[len("#EXT-X-START:"):])
+		p.StartTime, p.StartTimePrecise, p.StartUnknownAttrs, err =
+			parseExtXStartParams(line[len("#EXT-X-START:"):])
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-START: %w", err)
 		}
+		if !p.preserveUnknown {
+			p.StartUnknownAttrs = nil
+		}
 	case line == "#EXT-X-INDEPENDENT-SEGMENTS":
 		p.SetIndependentSegments(true)
 	case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
@@ -418,6 +453,11 @@ func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line st
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-MEDIA: %w", err)
 		}
+		if !p.preserveUnknown {
+			alt.UnknownAttrs = nil
+		}
+		alt.UnknownTags = state.unknownTags
+		state.unknownTags = nil
 		state.alternatives = append(state.alternatives, &alt)
 	case !state.tagStreamInf && strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
 		state.tagStreamInf = true
@@ -426,6 +466,7 @@ func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line st
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-STREAM-INF: %w", err)
 		}
+		p.takeUnknown(state, variant)
 		state.variant = variant
 		p.Variants = append(p.Variants, variant)
 	case state.tagStreamInf && !strings.HasPrefix(line, "#"):
@@ -437,6 +478,7 @@ func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line st
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-I-FRAME-STREAM-INF: %w", err)
 		}
+		p.takeUnknown(state, variant)
 		state.variant = variant
 		state.variant.Iframe = true
 		p.Variants = append(p.Variants, state.variant)
@@ -454,11 +496,27 @@ func decodeLineOfMasterPlaylist(p *MasterPlaylist, state *decodingState, line st
 		if err != nil {
 			return err
 		}
+		if !p.preserveUnknown {
+			sd.UnknownAttrs = nil
+		}
 		p.SessionDatas = append(p.SessionDatas, sd)
 	case strings.HasPrefix(line, "#EXT-X-SESSION-KEY:"):
-		p.SessionKeys = append(p.SessionKeys, parseKeyParams(line[19:]))
+		key := parseKeyParams(line[19:])
+		if !p.preserveUnknown {
+			key.UnknownAttrs = nil
+		}
+		p.SessionKeys = append(p.SessionKeys, key)
 	case strings.HasPrefix(line, "#EXT-X-CONTENT-STEERING:"):
 		p.ContentSteering = parseContentSteering(line[len("#EXT-X-CONTENT-STEERING:"):])
+	case p.preserveUnknown && !decodedCustom && isUnknownTag(line):
+		switch {
+		case state.tagStreamInf: // between EXT-X-STREAM-INF and its URI
+			state.variant.UnknownTags = append(state.variant.UnknownTags, line)
+		case len(p.Variants) == 0 && len(state.alternatives) == 0:
+			p.UnknownTags = append(p.UnknownTags, line)
+		default:
+			state.unknownTags = append(state.unknownTags, line)
+		}
 	}
 
 	return err
@@ -470,7 +528,8 @@ func parseExtXMedia(line string, strict bool) (Alternative, error) {
 		return alt, fmt.Errorf("invalid line: %q", line)
 	}
 	var err error
-	for k, v := range decodeAndTrimAttributes(line[len("#EXT-X-MEDIA:"):]) {
+	for _, a := range decodeAttributes(line[len("#EXT-X-MEDIA:"):]) {
+		k, v := a.Key, strings.Trim(a.Val, ` "`)
 		switch k {
 		case "TYPE":
 			alt.Type = v
@@ -517,11 +576,16 @@ func parseExtXMedia(line string, strict bool) (Alternative, error) {
 			alt.SampleRate = uint32(sampleRate)
 		case "CHARACTERISTICS":
 			alt.Characteristics = v
+			if err := checkCharacteristics(v); strict && err != nil {
+				return alt, err
+			}
 		case "CHANNELS":
 			alt.Channels, err = parseChannels(v)
 			if err != nil {
 				return alt, fmt.Errorf("invalid CHANNELS: %w", err)
 			}
+		default:
+			alt.UnknownAttrs = append(alt.UnknownAttrs, a)
 		}
 	}
 	return alt, nil
@@ -565,6 +629,9 @@ func parseExtXStreamInf(line string, strict bool) (*Variant, error) {
 			variant.SupplementalCodecs = deQuote(a.Val)
 		case "RESOLUTION": // decimal-resolution WxH
 			variant.Resolution = a.Val
+			if _, _, ok := parseResolution(a.Val); strict && !ok {
+				return nil, fmt.Errorf("%w: RESOLUTION=%s", ErrInvalidAttribute, a.Val)
+			}
 		case "FRAME-RATE":
 			val, err := strconv.ParseFloat(a.Val, 64)
 			if strict && err != nil {
@@ -573,12 +640,24 @@ func parseExtXStreamInf(line string, strict bool) (*Variant, error) {
 			variant.FrameRate = val
 		case "HDCP-LEVEL": // NONE, TYPE-0, TYPE-1
 			variant.HDCPLevel = a.Val
+			if strict && !HDCPLevel(a.Val).Valid() {
+				return nil, fmt.Errorf("%w: HDCP-LEVEL=%s", ErrInvalidAttribute, a.Val)
+			}
 		case "ALLOWED-CPC":
 			variant.AllowedCPC = deQuote(a.Val)
+			if _, err := parseAllowedCPC(variant.AllowedCPC); strict && err != nil {
+				return nil, err
+			}
 		case "VIDEO-RANGE": // SDR, HLG, PQ
 			variant.VideoRange = a.Val
+			if strict && !VideoRange(a.Val).Valid() {
+				return nil, fmt.Errorf("%w: VIDEO-RANGE=%s", ErrInvalidAttribute, a.Val)
+			}
 		case "REQ-VIDEO-LAYOUT":
 			variant.ReqVideoLayout = deQuote(a.Val)
+			if err := checkReqVideoLayout(variant.ReqVideoLayout); strict && err != nil {
+				return nil, err
+			}
 		case "STABLE-VARIANT-ID":
 			variant.StableVariantId = deQuote(a.Val)
 		case "AUDIO": // Alternative renditions group ID
@@ -605,6 +684,8 @@ func parseExtXStreamInf(line string, strict bool) (*Variant, error) {
 			variant.ProgramId = &val
 		case "NAME":
 			variant.Name = deQuote(a.Val)
+		default:
+			variant.UnknownAttrs = append(variant.UnknownAttrs, a)
 		}
 	}
 	return &variant, nil
@@ -673,9 +754,10 @@ func parseDateRange(line string) (*DateRange, error) {
 	return &dr, nil
 }
 
-func parseExtXStartParams(parameters string) (float64, bool, error) {
+func parseExtXStartParams(parameters string) (float64, bool, []Attribute, error) {
 	var startTime float64
 	var startTimePrecise bool
+	var unknownAttrs []Attribute
 	var err error
 
 	for _, attr := range decodeAttributes(parameters) {
@@ -683,14 +765,16 @@ func parseExtXStartParams(parameters string) (float64, bool, error) {
 		case "TIME-OFFSET":
 			startTime, err = strconv.ParseFloat(attr.Val, 64)
 			if err != nil {
-				return startTime, startTimePrecise,
+				return startTime, startTimePrecise, nil,
 					fmt.Errorf("invalid TIME-OFFSET: %s: %w", attr.Val, err)
 			}
 		case "PRECISE":
 			startTimePrecise = attr.Val == "YES"
+		default:
+			unknownAttrs = append(unknownAttrs, attr)
 		}
 	}
-	return startTime, startTimePrecise, nil
+	return startTime, startTimePrecise, unknownAttrs, nil
 }
 
 func parseDefine(line string) (Define, error) {
@@ -742,6 +826,10 @@ func parsePartialSegment(parameters string) (ps *PartialSegment, hasOffset bool,
 			if part.Limit, part.Offset, hasOffset, err = parseByteRange(deQuote(attr.Val)); err != nil {
 				return nil, false, err
 			}
+		case "GAP":
+			part.Gap = attr.Val == "YES"
+		default:
+			part.UnknownAttrs = append(part.UnknownAttrs, attr)
 		}
 	}
 	return &part, hasOffset, nil
@@ -767,6 +855,8 @@ func parsePreloadHint(parameters string) (*PreloadHint, error) {
 				return nil, fmt.Errorf("length parsing error: %w", err)
 			}
 			ph.Limit = length
+		default:
+			ph.UnknownAttrs = append(ph.UnknownAttrs, attr)
 		}
 	}
 	return &ph, nil
@@ -807,15 +897,15 @@ func parseServerControl(parameters string) (*ServerControl, error) {
 			}
 		case "CAN-BLOCK-RELOAD":
 			sc.CanBlockReload = attr.Val == "YES"
+		default:
+			sc.UnknownAttrs = append(sc.UnknownAttrs, attr)
 		}
 	}
 	return &sc, nil
 }
 
 func parseSessionData(line string) (*SessionData, error) {
-	sd := SessionData{
-		Format: "JSON",
-	}
+	var sd SessionData
 	if !strings.HasPrefix(line, "#EXT-X-SESSION-DATA:") {
 		return nil, fmt.Errorf("invalid EXT-X-SESSION-DATA line: %q", line)
 	}
@@ -836,8 +926,13 @@ func parseSessionData(line string) (*SessionData, error) {
 			}
 		case "LANGUAGE":
 			sd.Language = deQuote(attr.Val)
+		default:
+			sd.UnknownAttrs = append(sd.UnknownAttrs, attr)
 		}
 	}
+	if sd.URI != "" && sd.Format == "" {
+		sd.Format = "JSON" // FORMAT only applies to URI
+	}
 	return &sd, nil
 }
 
@@ -876,6 +971,8 @@ func parseExtXMapParameters(parameters string) (*Map, error) {
 				return nil, fmt.Errorf("EXT-X-MAP BYTERANGE %q is missing the required offset", attr.Val)
 			}
 			m.Limit, m.Offset = limit, offset
+		default:
+			m.UnknownAttrs = append(m.UnknownAttrs, attr)
 		}
 	}
 	return &m, nil
@@ -895,6 +992,8 @@ func parseKeyParams(parameters string) *Key {
 			key.Keyformat = deQuote(attr.Val)
 		case "KEYFORMATVERSIONS":
 			key.Keyformatversions = deQuote(attr.Val)
+		default:
+			key.UnknownAttrs = append(key.UnknownAttrs, attr)
 		}
 	}
 	return &key
@@ -956,11 +1055,13 @@ func deQuote(s string) string {
 // Parse one line of a media playlist.
 func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line string, strict bool) error {
 	var err error
+	var decodedCustom bool
 
 	// check for custom tags first to allow custom parsing of existing tags
 	if p.Custom != nil {
 		for _, v := range p.customDecoders {
 			if strings.HasPrefix(line, v.TagName()) {
+				decodedCustom = true
 				t, err := v.Decode(line)
 
 				if strict && err != nil {
@@ -969,9 +1070,14 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 
 				if v.SegmentTag() {
 					state.tagCustom = true
+					if state.custom == nil {
+						state.custom = make(CustomMap)
+					}
 					state.custom[v.TagName()] = t
+					state.customOrder = append(state.customOrder, t)
 				} else {
 					p.Custom[v.TagName()] = t
+					p.customOrder = append(p.customOrder, t)
 				}
 			}
 		}
@@ -1005,19 +1111,19 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 			seg.URI = line
 			seg.Duration = state.duration
 			seg.Title = state.title
+			seg.UnknownTags = state.unknownTags
+			state.unknownTags = nil
 			if state.lastReadMap != nil && !state.lastReadMap.Equal(state.lastStoredMap) {
 				seg.Map = state.lastReadMap
 				state.lastStoredMap = state.lastReadMap
 			}
 			err := p.AppendSegment(seg)
 			if err == ErrPlaylistFull {
-				// Extend playlist by doubling size, reset internal state, try again.
+				// Extend playlist by doubling size, try again.
 				// If the second Append fails, the if err block will handle it.
 				// Retrying instead of being recursive was chosen as the state may be
 				// modified non-idempotently.
-				p.Segments = append(p.Segments, make([]*MediaSegment, p.Count())...)
-				p.capacity = uint(len(p.Segments))
-				p.tail = p.count
+				p.grow()
 				err = p.AppendSegment(seg)
 			}
 
@@ -1101,8 +1207,11 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 		}
 		// if segment custom tag appeared before EXTINF then it links to this segment
 		if state.tagCustom {
-			p.Segments[p.last()].Custom = state.custom
-			state.custom = make(CustomMap)
+			seg := p.Segments[p.last()]
+			seg.Custom = state.custom
+			seg.customOrder = state.customOrder
+			state.custom = nil
+			state.customOrder = nil
 			state.tagCustom = false
 		}
 		// all partial segment which appeared before the segment should be marked as completed
@@ -1138,6 +1247,9 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 		if p.ServerControl, err = parseServerControl(line[22:]); err != nil {
 			return err
 		}
+		if !p.preserveUnknown {
+			p.ServerControl.UnknownAttrs = nil
+		}
 	case strings.HasPrefix(line, "#EXT-X-SKIP:"):
 		state.listType = MEDIA
 		skipped, err := parseSkipTag(line[12:])
@@ -1152,6 +1264,9 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 		if err != nil {
 			return err
 		}
+		if !p.preserveUnknown {
+			partialSegment.UnknownAttrs = nil
+		}
 		if partialSegment.Limit > 0 {
 			// As for EXT-X-BYTERANGE, an absent offset continues from the previous partial
 			// segment, here scoped to the same parent segment (rfc8216bis Section 4.4.4.9).
@@ -1183,7 +1298,14 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-PRELOAD-HINT: %w", err)
 		}
-		p.PreloadHints = preloadHint
+		if !p.preserveUnknown {
+			preloadHint.UnknownAttrs = nil
+		}
+		if p.GetPreloadHint(preloadHint.Type) != nil && strict {
+			return fmt.Errorf("EXT-X-PRELOAD-HINT: more than one of type %s: %w",
+				preloadHint.Type, ErrInvalidPreloadHint)
+		}
+		p.setPreloadHint(preloadHint)
 	case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
 		state.listType = MEDIA
 		if _, err = fmt.Sscanf(line, "#EXT-X-MEDIA-SEQUENCE:%d", &p.SeqNo); strict && err != nil {
@@ -1218,13 +1340,20 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 			return err
 		}
 	case strings.HasPrefix(line, "#EXT-X-START:"):
-		p.StartTime, p.StartTimePrecise, err = parseExtXStartParams(line[len("#EXT-X-START:"):])
+		p.StartTime, p.StartTimePrecise, p.StartUnknownAttrs, err =
+			parseExtXStartParams(line[len("#EXT-X-START:"):])
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-START: %w", err)
 		}
+		if !p.preserveUnknown {
+			p.StartUnknownAttrs = nil
+		}
 	case strings.HasPrefix(line, "#EXT-X-KEY:"):
 		state.listType = MEDIA
 		xkey := parseKeyParams(line[11:])
+		if !p.preserveUnknown {
+			xkey.UnknownAttrs = nil
+		}
 		state.xkeys = append(state.xkeys, *xkey)
 		state.tagKey = true
 	case strings.HasPrefix(line, "#EXT-X-MAP:"):
@@ -1233,6 +1362,9 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 		if err != nil {
 			return fmt.Errorf("error parsing EXT-X-MAP: %w", err)
 		}
+		if !p.preserveUnknown {
+			xMap.UnknownAttrs = nil
+		}
 		if state.lastReadMap == nil && p.Count() == 0 {
 			p.Map = xMap
 			state.lastStoredMap = xMap
@@ -1343,6 +1475,12 @@ func decodeLineOfMediaPlaylist(p *MediaPlaylist, state *decodingState, line stri
 	case strings.HasPrefix(line, "#EXT-X-ALLOW-CACHE:"):
 		val := strings.TrimPrefix(line, "#EXT-X-ALLOW-CACHE:") == "YES"
 		p.AllowCache = &val
+	case p.preserveUnknown && !decodedCustom && isUnknownTag(line):
+		if p.Count() == 0 && !p.HasPartialSegments() && !state.segmentTagsPending() {
+			p.UnknownTags = append(p.UnknownTags, line)
+		} else {
+			state.unknownTags = append(state.unknownTags, line)
+		}
 	}
 	return err
 }
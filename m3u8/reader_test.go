@@ -1644,6 +1644,31 @@ func TestParsePreloadHint(t *testing.T) {
 	}
 }
 
+func TestDecodeMediaPlaylistPreloadHints(t *testing.T) {
+	is := is.New(t)
+	playlist := `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-PART-INF:PART-TARGET=1.000
+#EXT-X-TARGETDURATION:4
+#EXTINF:4.000,
+seg0.m4s
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="seg1.m4s",BYTERANGE-START=1000
+#EXT-X-PRELOAD-HINT:TYPE=MAP,URI="init1.mp4"
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="seg1.part.m4s"
+`
+	p, err := NewMediaPlaylist(0, 1)
+	is.NoErr(err)
+	err = p.DecodeFrom(bytes.NewBufferString(playlist), true)
+	is.True(errors.Is(err, ErrInvalidPreloadHint)) // two PART hints in strict mode
+
+	p, err = NewMediaPlaylist(0, 1)
+	is.NoErr(err)
+	is.NoErr(p.DecodeFrom(bytes.NewBufferString(playlist), false))
+	is.Equal(p.PreloadHints.URI, "seg1.part.m4s") // last PART hint wins
+	is.Equal(p.PreloadMapHint.URI, "init1.mp4")   // MAP hint kept
+	is.Equal(p.GetPreloadHint(PreloadHintMap).URI, "init1.mp4")
+}
+
 func TestParseServerControl(t *testing.T) {
 	tests := []struct {
 		name       string
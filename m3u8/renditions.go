@@ -0,0 +1,289 @@
+package m3u8
+
+/*
+ This file defines the rendition groups of a master playlist, i.e. the
+ EXT-X-MEDIA renditions grouped by TYPE and GROUP-ID.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidRendition = errors.New("invalid EXT-X-MEDIA rendition")
+var ErrRenditionGroupNotFound = errors.New("rendition group not found")
+
+// renditionTypes lists the TYPE values of EXT-X-MEDIA renditions.
+var renditionTypes = []string{RenditionVideo, RenditionAudio, RenditionSubtitles, RenditionClosedCaptions}
+
+// RenditionGroup returns the group of renditions with the given TYPE and GROUP-ID,
+// or nil if there is no such group.
+func (p *MasterPlaylist) RenditionGroup(renditionType, groupId string) *RenditionGroup {
+	for _, g := range p.RenditionGroups {
+		if g.Type == renditionType && g.GroupId == groupId {
+			return g
+		}
+	}
+	return nil
+}
+
+// addRendition appends alt to its rendition group, creating the group if it is
+// the first rendition of it.
+func (p *MasterPlaylist) addRendition(alt *Alternative) *RenditionGroup {
+	g := p.RenditionGroup(alt.Type, alt.GroupId)
+	if g == nil {
+		g = &RenditionGroup{Type: alt.Type, GroupId: alt.GroupId}
+		p.RenditionGroups = append(p.RenditionGroups, g)
+	}
+	g.Renditions = append(g.Renditions, alt)
+	p.renditionOrder = append(p.renditionOrder, alt)
+	return g
+}
+
+// AddRenditionGroup adds an empty group of renditions with the given TYPE and GROUP-ID,
+// or returns the existing group. TYPE must be one of the Rendition type constants.
+func (p *MasterPlaylist) AddRenditionGroup(renditionType, groupId string) (*RenditionGroup, error) {
+	if err := checkRenditionGroupId(renditionType, groupId); err != nil {
+		return nil, err
+	}
+	if g := p.RenditionGroup(renditionType, groupId); g != nil {
+		return g, nil
+	}
+	g := &RenditionGroup{Type: renditionType, GroupId: groupId}
+	p.RenditionGroups = append(p.RenditionGroups, g)
+	return g, nil
+}
+
+// AddRendition adds alt to the group given by its TYPE and GROUP-ID, and creates the
+// group if needed. The rendition is checked against the group, see ValidateRenditionGroups.
+// Alternatives of variants that are in no group are added to their groups first, and the
+// Alternatives of the variants referring to the group are updated.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) AddRendition(alt *Alternative) error {
+	if err := checkRenditionGroupId(alt.Type, alt.GroupId); err != nil {
+		return err
+	}
+	p.foldAlternatives()
+	g := p.RenditionGroup(alt.Type, alt.GroupId)
+	if g == nil {
+		g = &RenditionGroup{Type: alt.Type, GroupId: alt.GroupId}
+	}
+	if err := g.checkRendition(g.Renditions, alt); err != nil {
+		return err
+	}
+	p.addRendition(alt)
+	p.UpdateAlternatives()
+	return nil
+}
+
+// LinkRenditionGroup makes the variant refer to an existing rendition group by setting
+// its VIDEO, AUDIO, SUBTITLES or CLOSED-CAPTIONS attribute, and updates its Alternatives.
+// I-frame variants can only refer to VIDEO groups.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) LinkRenditionGroup(v *Variant, renditionType, groupId string) error {
+	if p.RenditionGroup(renditionType, groupId) == nil {
+		return fmt.Errorf("%w: %s %q", ErrRenditionGroupNotFound, renditionType, groupId)
+	}
+	if v.Iframe && renditionType != RenditionVideo {
+		return fmt.Errorf("%w: I-frame variant %q cannot refer to %s group %q", ErrInvalidRendition,
+			v.URI, renditionType, groupId)
+	}
+	switch renditionType {
+	case RenditionVideo:
+		v.Video = groupId
+	case RenditionAudio:
+		v.Audio = groupId
+	case RenditionSubtitles:
+		v.Subtitles = groupId
+	case RenditionClosedCaptions:
+		v.Captions = groupId
+	}
+	p.UpdateAlternatives()
+	return nil
+}
+
+// ValidateRenditionGroups checks the rendition groups and the references of the variants
+// to them. Every rendition of a group has the TYPE and GROUP-ID of the group and a NAME
+// that is unique within the group, at most one rendition of a group is DEFAULT, and
+// CHANNELS is only used for AUDIO, where either all or none of the renditions of a group
+// have it, with the same number of channels. Every group a variant refers to must exist.
+func (p *MasterPlaylist) ValidateRenditionGroups() error {
+	var errs []error
+	for _, g := range p.RenditionGroups {
+		for i, alt := range g.Renditions {
+			if err := g.checkRendition(g.Renditions[:i], alt); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, v := range p.Variants {
+		ids := v.groupIds()
+		for _, renditionType := range renditionTypes {
+			id := ids[renditionType]
+			if id == "" || (renditionType == RenditionClosedCaptions && id == "NONE") {
+				continue
+			}
+			if p.RenditionGroup(renditionType, id) == nil {
+				errs = append(errs, fmt.Errorf("%w: %s %q of variant %q", ErrRenditionGroupNotFound,
+					renditionType, id, v.URI))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkRenditionGroupId checks the TYPE and GROUP-ID of a rendition group.
+func checkRenditionGroupId(renditionType, groupId string) error {
+	if !slices.Contains(renditionTypes, renditionType) {
+		return fmt.Errorf("%w: TYPE=%s", ErrInvalidRendition, renditionType)
+	}
+	if groupId == "" {
+		return fmt.Errorf("%w: no GROUP-ID", ErrInvalidRendition)
+	}
+	return nil
+}
+
+// checkRendition checks alt against the group and the preceding renditions of it.
+func (g *RenditionGroup) checkRendition(preceding []*Alternative, alt *Alternative) error {
+	switch {
+	case alt == nil:
+		return fmt.Errorf("%w: nil rendition in group %q", ErrInvalidRendition, g.GroupId)
+	case alt.Type != g.Type || alt.GroupId != g.GroupId:
+		return fmt.Errorf("%w: %s %q in %s group %q", ErrInvalidRendition, alt.Type, alt.GroupId,
+			g.Type, g.GroupId)
+	case alt.Name == "":
+		return fmt.Errorf("%w: no NAME in group %q", ErrInvalidRendition, g.GroupId)
+	case alt.Channels != nil && g.Type != RenditionAudio:
+		return fmt.Errorf("%w: CHANNELS for %s %q", ErrInvalidRendition, g.Type, alt.Name)
+	}
+	for _, o := range preceding {
+		if o.Name == alt.Name {
+			return fmt.Errorf("%w: duplicate NAME %q in group %q", ErrInvalidRendition, alt.Name, g.GroupId)
+		}
+		if o.Default && alt.Default {
+			return fmt.Errorf("%w: %q and %q are both DEFAULT in group %q", ErrInvalidRendition,
+				o.Name, alt.Name, g.GroupId)
+		}
+	}
+	if len(preceding) > 0 && g.Type == RenditionAudio {
+		first := preceding[0]
+		if (first.Channels == nil) != (alt.Channels == nil) ||
+			(alt.Channels != nil && first.Channels.Amount != alt.Channels.Amount) {
+			return fmt.Errorf("%w: CHANNELS of %q differ from %q in group %q", ErrInvalidRendition,
+				alt.Name, first.Name, g.GroupId)
+		}
+	}
+	return nil
+}
+
+// groupIds returns the group IDs the variant refers to, per rendition type.
+func (v *Variant) groupIds() map[string]string {
+	return map[string]string{
+		RenditionVideo:          v.Video,
+		RenditionAudio:          v.Audio,
+		RenditionClosedCaptions: v.Captions,
+		RenditionSubtitles:      v.Subtitles,
+	}
+}
+
+// UpdateAlternatives sets the Alternatives of every non-I-frame variant to the
+// renditions of the groups it refers to with its VIDEO, AUDIO, SUBTITLES and
+// CLOSED-CAPTIONS attributes, in declaration order.
+// Variant.Alternatives is a view derived from RenditionGroups, and should be
+// updated after changing the groups or the group attributes of variants.
+// Alternatives of variants that are in no group, e.g. from Append, are added to
+// their groups first, so that they are kept.
+// Nothing is done if the playlist has no rendition groups.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) UpdateAlternatives() {
+	if len(p.RenditionGroups) == 0 {
+		return
+	}
+	p.foldAlternatives()
+	for _, v := range p.Variants {
+		if v.Iframe {
+			continue
+		}
+		v.Alternatives = p.variantRenditions(v)
+	}
+	p.buf.Reset()
+}
+
+// foldAlternatives adds the alternatives of variants that are in no rendition group to
+// their groups, in the order GetAllAlternatives lists them. Alternatives listed once by
+// GetAllAlternatives, since they equal one already listed, are not added.
+func (p *MasterPlaylist) foldAlternatives() {
+	alts := p.GetAllAlternatives()
+	for _, alt := range alts[len(p.groupRenditions()):] {
+		p.addRendition(alt)
+	}
+}
+
+// variantRenditions returns the renditions of the groups the variant refers to, or
+// its Alternatives if the playlist has no rendition groups.
+func (p *MasterPlaylist) variantRenditions(v *Variant) []*Alternative {
+	if len(p.RenditionGroups) == 0 {
+		return v.Alternatives
+	}
+	var alts []*Alternative
+	ids := v.groupIds()
+	for _, g := range p.RenditionGroups {
+		if id := ids[g.Type]; id != "" && id == g.GroupId {
+			alts = append(alts, g.Renditions...)
+		}
+	}
+	return alts
+}
+
+// groupRenditions returns the renditions of all groups in declaration order.
+// Renditions put directly into RenditionGroups come last, in group order.
+func (p *MasterPlaylist) groupRenditions() []*Alternative {
+	inGroup := make(map[*Alternative]bool)
+	for _, g := range p.RenditionGroups {
+		for _, alt := range g.Renditions {
+			if alt != nil {
+				inGroup[alt] = true
+			}
+		}
+	}
+	alts := make([]*Alternative, 0, len(inGroup))
+	for _, alt := range p.renditionOrder {
+		if inGroup[alt] {
+			alts = append(alts, alt)
+			delete(inGroup, alt)
+		}
+	}
+	for _, g := range p.RenditionGroups {
+		for _, alt := range g.Renditions {
+			if inGroup[alt] {
+				alts = append(alts, alt)
+				delete(inGroup, alt)
+			}
+		}
+	}
+	return alts
+}
+
+// allRenditions returns the renditions of all groups followed by the variant
+// alternatives not in any group, each listed once.
+func (p *MasterPlaylist) allRenditions() []*Alternative {
+	var alts []*Alternative
+	seen := make(map[*Alternative]bool)
+	add := func(alt *Alternative) {
+		if alt == nil || seen[alt] {
+			return
+		}
+		seen[alt] = true
+		alts = append(alts, alt)
+	}
+	for _, alt := range p.groupRenditions() {
+		add(alt)
+	}
+	for _, v := range p.Variants {
+		for _, alt := range v.Alternatives {
+			add(alt)
+		}
+	}
+	return alts
+}
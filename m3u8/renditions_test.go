@@ -0,0 +1,182 @@
+package m3u8
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRenditionGroups(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-with-rendition-groups.m3u8")
+	is.NoErr(err) // must open file
+	p := NewMasterPlaylist()
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true)) // must decode playlist
+	f.Close()
+
+	is.Equal(len(p.RenditionGroups), 3) // subs, aac and orphan ec3
+	is.Equal(p.RenditionGroups[0].Type, "SUBTITLES")
+	is.Equal(p.RenditionGroups[0].GroupId, "subs")
+	is.Equal(p.RenditionGroups[1].GroupId, "aac")
+	is.Equal(p.RenditionGroups[2].GroupId, "ec3")
+	is.Equal(len(p.RenditionGroups[2].Renditions), 1) // orphan group must be kept
+	is.Equal(p.RenditionGroups[1].Renditions[0].Name, "Swedish")
+	is.Equal(p.RenditionGroup("AUDIO", "aac"), p.RenditionGroups[1])
+	is.Equal(p.RenditionGroup("VIDEO", "aac"), nil) // type must match
+
+	// Variant alternatives are derived from the groups and share their renditions
+	v := p.Variants[0]
+	is.Equal(len(v.Alternatives), 4)
+	is.Equal(v.Alternatives[0], p.RenditionGroups[0].Renditions[0])
+	is.Equal(v.Alternatives[2], p.RenditionGroups[1].Renditions[0])
+
+	alts := p.GetAllAlternatives()
+	is.Equal(len(alts), 5)
+	is.Equal(alts[1].GroupId, "aac") // declaration order must be kept
+	is.Equal(alts[4].GroupId, "ec3")
+
+	v.Audio = "ec3"
+	p.UpdateAlternatives()
+	is.Equal(len(v.Alternatives), 3)
+	is.Equal(v.Alternatives[2], p.RenditionGroups[2].Renditions[0])
+}
+
+func TestRenditionGroupsClone(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	err := p.Decode(*bytes.NewBufferString("#EXTM3U\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",URI="en.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="ec3",NAME="English",URI="ec3.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000,AUDIO="aac"` + "\n" +
+		"video.m3u8\n"), true)
+	is.NoErr(err) // must decode playlist
+	want := p.String()
+
+	c := p.Clone()
+	is.Equal(c.String(), want)                                                  // clone must encode the same
+	is.Equal(c.Variants[0].Alternatives[0], c.RenditionGroups[0].Renditions[0]) // copies must be shared
+	is.True(c.RenditionGroups[0].Renditions[0] != p.RenditionGroups[0].Renditions[0])
+
+	c.RewriteURIs(func(_ URIKind, uri string) string { return "x/" + uri })
+	is.Equal(c.RenditionGroups[1].Renditions[0].URI, "x/ec3.m3u8") // orphan rendition must be rewritten
+	is.Equal(c.Variants[0].Alternatives[0].URI, "x/en.m3u8")
+	p.ResetCache()
+	is.Equal(p.String(), want) // original must be unchanged
+}
+
+func TestAddRendition(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	p.Append("720p.m3u8", nil, VariantParams{Bandwidth: 1280000})
+	p.Append("1080p.m3u8", nil, VariantParams{Bandwidth: 2560000})
+
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionAudio, GroupId: "aac", Name: "English", Language: "en",
+		Default: true, Autoselect: true, Channels: &Channels{Amount: 2}, URI: "aac/en.m3u8"}))
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionAudio, GroupId: "aac", Name: "Swedish", Language: "sv",
+		Autoselect: true, Channels: &Channels{Amount: 2}, URI: "aac/sv.m3u8"}))
+	g, err := p.AddRenditionGroup(RenditionSubtitles, "subs")
+	is.NoErr(err)
+	is.Equal(len(g.Renditions), 0)
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionSubtitles, GroupId: "subs", Name: "English",
+		Language: "en", URI: "subs/en.m3u8"}))
+	is.Equal(len(g.Renditions), 1) // rendition must be added to the existing group
+
+	for _, v := range p.Variants {
+		is.NoErr(p.LinkRenditionGroup(v, RenditionAudio, "aac"))
+		is.NoErr(p.LinkRenditionGroup(v, RenditionSubtitles, "subs"))
+	}
+	is.Equal(p.Variants[1].Audio, "aac")
+	is.Equal(len(p.Variants[1].Alternatives), 3)
+	is.Equal(p.Variants[0].Alternatives[0], p.Variants[1].Alternatives[0]) // renditions must be shared
+	is.NoErr(p.ValidateRenditionGroups())
+
+	want := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="aac/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="Swedish",LANGUAGE="sv",DEFAULT=NO,AUTOSELECT=YES,CHANNELS="2",URI="aac/sv.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=NO,URI="subs/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,AUDIO="aac",SUBTITLES="subs"
+720p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2560000,AUDIO="aac",SUBTITLES="subs"
+1080p.m3u8
+`
+	is.Equal(p.String(), want)
+
+	err = p.LinkRenditionGroup(p.Variants[0], RenditionVideo, "aac")
+	is.True(errors.Is(err, ErrRenditionGroupNotFound)) // group must exist
+}
+
+func TestAddRenditionKeepsAppendAlternatives(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	// Each variant has its own copy of the alternative, as is common without groups
+	for _, uri := range []string{"720p.m3u8", "1080p.m3u8"} {
+		p.Append(uri, nil, VariantParams{Bandwidth: 1280000, Audio: "aac", Alternatives: []*Alternative{
+			{Type: RenditionAudio, GroupId: "aac", Name: "English", Language: "en", URI: "aac/en.m3u8"}}})
+	}
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionSubtitles, GroupId: "subs", Name: "English",
+		Language: "en", URI: "subs/en.m3u8"}))
+	is.Equal(len(p.RenditionGroups), 2)
+	is.Equal(p.RenditionGroups[0].GroupId, "aac")          // appended alternative must be added first
+	is.Equal(len(p.RenditionGroups[0].Renditions), 1)      // copies must be added once
+	is.Equal(len(p.Variants[0].Alternatives), 1)           // variant does not refer to subs
+	is.Equal(p.Variants[0].Alternatives[0].GroupId, "aac") // appended alternative must be kept
+	is.NoErr(p.ValidateRenditionGroups())                  // AUDIO must not dangle
+	is.NoErr(p.LinkRenditionGroup(p.Variants[1], RenditionSubtitles, "subs"))
+	is.Equal(len(p.Variants[1].Alternatives), 2)
+
+	out := p.String()
+	is.Equal(strings.Count(out, `GROUP-ID="aac"`), 1) // appended alternative must be written once
+	is.Equal(strings.Count(out, `GROUP-ID="subs"`), 1)
+}
+
+func TestAddRenditionRules(t *testing.T) {
+	is := is.New(t)
+	p := NewMasterPlaylist()
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionAudio, GroupId: "aac", Name: "English", Default: true,
+		Channels: &Channels{Amount: 2}}))
+
+	cases := []struct {
+		desc string
+		alt  Alternative
+	}{
+		{"unknown TYPE", Alternative{Type: "TEXT", GroupId: "aac", Name: "French"}},
+		{"no GROUP-ID", Alternative{Type: RenditionAudio, Name: "French"}},
+		{"no NAME", Alternative{Type: RenditionAudio, GroupId: "aac", Channels: &Channels{Amount: 2}}},
+		{"duplicate NAME", Alternative{Type: RenditionAudio, GroupId: "aac", Name: "English",
+			Channels: &Channels{Amount: 2}}},
+		{"second DEFAULT", Alternative{Type: RenditionAudio, GroupId: "aac", Name: "French", Default: true,
+			Channels: &Channels{Amount: 2}}},
+		{"other CHANNELS", Alternative{Type: RenditionAudio, GroupId: "aac", Name: "French",
+			Channels: &Channels{Amount: 6}}},
+		{"no CHANNELS", Alternative{Type: RenditionAudio, GroupId: "aac", Name: "French"}},
+		{"CHANNELS for video", Alternative{Type: RenditionVideo, GroupId: "v", Name: "Angle",
+			Channels: &Channels{Amount: 2}}},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			is := is.New(t)
+			err := p.AddRendition(&c.alt)
+			is.True(errors.Is(err, ErrInvalidRendition)) // rendition must be rejected
+		})
+	}
+	is.Equal(len(p.RenditionGroups), 1) // rejected renditions must not create groups
+	is.NoErr(p.AddRendition(&Alternative{Type: RenditionAudio, GroupId: "aac", Name: "French",
+		Channels: &Channels{Amount: 2}}))
+
+	// Changes made directly to the groups are only found by validation
+	p.RenditionGroups[0].Renditions[1].Default = true
+	p.Append("v.m3u8", nil, VariantParams{Bandwidth: 1000, Audio: "aac", Subtitles: "subs"})
+	err := p.ValidateRenditionGroups()
+	is.True(errors.Is(err, ErrInvalidRendition))       // two DEFAULT renditions
+	is.True(errors.Is(err, ErrRenditionGroupNotFound)) // missing subtitles group
+
+	iframe := &Variant{URI: "iframe.m3u8", VariantParams: VariantParams{Iframe: true}}
+	err = p.LinkRenditionGroup(iframe, RenditionAudio, "aac")
+	is.True(errors.Is(err, ErrInvalidRendition)) // I-frame variants only refer to VIDEO groups
+}
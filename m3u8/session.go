@@ -0,0 +1,104 @@
+package m3u8
+
+/*
+ This file defines population and validation of EXT-X-SESSION-KEY and EXT-X-SESSION-DATA tags.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidSessionKey = errors.New("invalid EXT-X-SESSION-KEY")
+var ErrInvalidSessionData = errors.New("invalid EXT-X-SESSION-DATA")
+
+// AddSessionKey adds an EXT-X-SESSION-KEY tag unless an equal one is already present.
+// This operation resets the cache.
+func (p *MasterPlaylist) AddSessionKey(key Key) {
+	for _, k := range p.SessionKeys {
+		if k.Equal(&key) {
+			return
+		}
+	}
+	p.SessionKeys = append(p.SessionKeys, &key)
+	if key.Keyformat != "" || key.Keyformatversions != "" {
+		updateVersion(&p.ver, 5) // [Protocol Version Compatibility]
+	}
+	p.buf.Reset()
+}
+
+// AddSessionData adds an EXT-X-SESSION-DATA tag after checking it, see
+// ValidateSessionData. This operation resets the cache.
+func (p *MasterPlaylist) AddSessionData(sd SessionData) error {
+	if err := checkSessionData(p.SessionDatas, &sd); err != nil {
+		return err
+	}
+	p.SessionDatas = append(p.SessionDatas, &sd)
+	p.buf.Reset()
+	return nil
+}
+
+// ValidateSessionKeys checks that no session key has METHOD=NONE, and that every session
+// key is used by the Chunklists of the variants: there is an EXT-X-KEY tag with the same
+// URI, and METHOD, KEYFORMAT and KEYFORMATVERSIONS match all EXT-X-KEY tags with that URI.
+// Session keys are only checked against the variants if some variant has a Chunklist.
+func (p *MasterPlaylist) ValidateSessionKeys() error {
+	var errs []error
+	loaded := slices.ContainsFunc(p.Variants, func(v *Variant) bool { return v.Chunklist != nil })
+	for _, sk := range p.SessionKeys {
+		if sk.Method == "" || sk.Method == "NONE" {
+			errs = append(errs, fmt.Errorf("%w: METHOD=%s for %q", ErrInvalidSessionKey, sk.Method, sk.URI))
+			continue
+		}
+		used := false
+		for _, v := range p.Variants {
+			for _, k := range SessionKeys(v.Chunklist) {
+				if k.URI != sk.URI {
+					continue
+				}
+				used = true
+				if k.Method != sk.Method || k.Keyformat != sk.Keyformat || k.Keyformatversions != sk.Keyformatversions {
+					errs = append(errs, fmt.Errorf("%w: %q does not match the key of %s",
+						ErrInvalidSessionKey, sk.URI, v.URI))
+				}
+			}
+		}
+		if loaded && !used {
+			errs = append(errs, fmt.Errorf("%w: %q is not used by any variant", ErrInvalidSessionKey, sk.URI))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateSessionData checks the EXT-X-SESSION-DATA tags: DATA-ID is set, the
+// combination of DATA-ID and LANGUAGE is unique, there is either a VALUE or a URI, and
+// FORMAT is only used with a URI.
+func (p *MasterPlaylist) ValidateSessionData() error {
+	var errs []error
+	for i, sd := range p.SessionDatas {
+		if err := checkSessionData(p.SessionDatas[:i], sd); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkSessionData checks sd on its own and against the preceding tags.
+func checkSessionData(preceding []*SessionData, sd *SessionData) error {
+	switch {
+	case sd.DataId == "":
+		return fmt.Errorf("%w: no DATA-ID", ErrInvalidSessionData)
+	case (sd.Value == "") == (sd.URI == ""):
+		return fmt.Errorf("%w: %q must have either VALUE or URI", ErrInvalidSessionData, sd.DataId)
+	case sd.URI == "" && sd.Format != "":
+		return fmt.Errorf("%w: %q has FORMAT=%s without URI", ErrInvalidSessionData, sd.DataId, sd.Format)
+	}
+	for _, o := range preceding {
+		if o.DataId == sd.DataId && o.Language == sd.Language {
+			return fmt.Errorf("%w: duplicate DATA-ID %q with LANGUAGE %q", ErrInvalidSessionData,
+				sd.DataId, sd.Language)
+		}
+	}
+	return nil
+}
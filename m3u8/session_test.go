@@ -0,0 +1,91 @@
+package m3u8
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestGenerateSessionKeysMerges(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 4)
+	is.NoErr(err)
+	is.NoErr(p.SetDefaultKey("AES-128", "https://key.example.com/1", "0x01", "", ""))
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+
+	m := NewMasterPlaylist()
+	m.Append("p.m3u8", p, VariantParams{Bandwidth: 1000000})
+	m.AddSessionKey(Key{Method: "AES-128", URI: "https://key.example.com/1"})
+	m.AddSessionKey(Key{Method: "SAMPLE-AES", URI: "skd://key", Keyformat: KeyformatFairPlay})
+	m.GenerateSessionKeys()
+	m.GenerateSessionKeys()
+	is.Equal(len(m.SessionKeys), 2) // derived key already present
+	is.Equal(m.Version(), uint8(5))
+	is.True(errors.Is(m.ValidateSessionKeys(), ErrInvalidSessionKey)) // skd://key not used by the variant
+	m.SessionKeys = m.SessionKeys[:1]
+	is.NoErr(m.ValidateSessionKeys())
+
+	m.SessionKeys[0].Method = "SAMPLE-AES"
+	is.True(errors.Is(m.ValidateSessionKeys(), ErrInvalidSessionKey)) // METHOD differs from EXT-X-KEY
+	m.SessionKeys[0].Method = "NONE"
+	is.True(errors.Is(m.ValidateSessionKeys(), ErrInvalidSessionKey)) // METHOD=NONE not allowed
+}
+
+func TestAddSessionData(t *testing.T) {
+	is := is.New(t)
+	m := NewMasterPlaylist()
+	is.NoErr(m.AddSessionData(SessionData{DataId: "com.example.title", Value: "Title", Language: "en"}))
+	is.NoErr(m.AddSessionData(SessionData{DataId: "com.example.title", Value: "Titel", Language: "sv"}))
+	is.NoErr(m.AddSessionData(SessionData{DataId: "com.example.lyrics", URI: "lyrics.txt", Format: "RAW"}))
+
+	for _, sd := range []SessionData{
+		{DataId: "com.example.title", Value: "Title", Language: "en"}, // duplicate DATA-ID and LANGUAGE
+		{DataId: "com.example.both", Value: "v", URI: "data.json"},    // VALUE and URI
+		{DataId: "com.example.none"},                                  // neither VALUE nor URI
+		{DataId: "com.example.format", Value: "v", Format: "RAW"},     // FORMAT without URI
+		{DataId: "com.example.json", Value: "v", Format: "JSON"},      // FORMAT=JSON without URI
+		{Value: "v"}, // no DATA-ID
+	} {
+		err := m.AddSessionData(sd)
+		is.True(errors.Is(err, ErrInvalidSessionData))
+	}
+	is.Equal(len(m.SessionDatas), 3)
+	is.NoErr(m.ValidateSessionData())
+	is.True(strings.Contains(m.String(),
+		`#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Title",LANGUAGE="en"`+"\n")) // no FORMAT written
+
+	m.SessionDatas = append(m.SessionDatas, &SessionData{DataId: "com.example.lyrics", URI: "other.txt"})
+	is.True(errors.Is(m.ValidateSessionData(), ErrInvalidSessionData))
+}
+
+func TestDecodeSessionDataDuplicate(t *testing.T) {
+	is := is.New(t)
+	const playlist = `#EXTM3U
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Title",LANGUAGE="en"
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Other",LANGUAGE="en"
+#EXT-X-STREAM-INF:BANDWIDTH=600000
+chunklist.m3u8
+`
+	m := NewMasterPlaylist()
+	is.NoErr(m.DecodeFrom(bytes.NewBufferString(playlist), true)) // decoding does not validate session data
+	is.Equal(len(m.SessionDatas), 2)
+	is.Equal(m.SessionDatas[0].Format, "")                             // FORMAT only applies to URI
+	is.True(errors.Is(m.ValidateSessionData(), ErrInvalidSessionData)) // duplicate DATA-ID and LANGUAGE
+}
+
+func TestDecodeSessionDataFormat(t *testing.T) {
+	is := is.New(t)
+	const playlist = `#EXTM3U
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Title",FORMAT=JSON
+#EXT-X-SESSION-DATA:DATA-ID="com.example.data",URI="data.json"
+#EXT-X-STREAM-INF:BANDWIDTH=600000
+chunklist.m3u8
+`
+	m := NewMasterPlaylist()
+	is.NoErr(m.DecodeFrom(bytes.NewBufferString(playlist), true))
+	is.Equal(m.SessionDatas[1].Format, "JSON")                         // default for URI
+	is.True(errors.Is(m.ValidateSessionData(), ErrInvalidSessionData)) // FORMAT with VALUE
+}
@@ -0,0 +1,177 @@
+package m3u8
+
+/*
+ This file defines URI signing, e.g. with expiring CDN tokens.
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrRelativeURI = errors.New("relative URI cannot be signed with a path token")
+
+// URISigner signs the URIs of a playlist, e.g. by adding an expiring token.
+type URISigner interface {
+	// SignURI returns the signed version of uri, which is of the given kind.
+	SignURI(kind URIKind, uri string) (string, error)
+}
+
+// URISignerFunc is an adapter to use an ordinary function as URISigner.
+type URISignerFunc func(kind URIKind, uri string) (string, error)
+
+// SignURI calls f(kind, uri).
+func (f URISignerFunc) SignURI(kind URIKind, uri string) (string, error) {
+	return f(kind, uri)
+}
+
+// Sign returns a copy of the media playlist with all URIs signed by s, see RewriteURIs
+// for the URIs covered. p itself is not changed, so a shared decoded playlist can be
+// signed per request. The copy may be returned to the pools with ReleasePlaylist.
+func (p *MediaPlaylist) Sign(s URISigner) (*MediaPlaylist, error) {
+	c := p.Clone()
+	var err error
+	c.RewriteURIs(signFunc(s, &err))
+	if err != nil {
+		c.ReleasePlaylist()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Sign returns a copy of the master playlist with all URIs signed by s, see RewriteURIs
+// for the URIs covered. p itself is not changed, so a shared decoded playlist can be
+// signed per request.
+func (p *MasterPlaylist) Sign(s URISigner) (*MasterPlaylist, error) {
+	c := p.Clone()
+	var err error
+	c.RewriteURIs(signFunc(s, &err))
+	if err != nil {
+		c.ReleasePlaylist()
+		return nil, err
+	}
+	return c, nil
+}
+
+// signFunc wraps s as a URIRewriteFunc that stores the first error in err.
+func signFunc(s URISigner, err *error) URIRewriteFunc {
+	return func(kind URIKind, uri string) string {
+		if *err != nil {
+			return uri
+		}
+		signed, signErr := s.SignURI(kind, uri)
+		if signErr != nil {
+			*err = fmt.Errorf("sign %s URI %q: %w", kind, uri, signErr)
+			return uri
+		}
+		return signed
+	}
+}
+
+// HMACQuerySigner signs URIs by appending an expiry time and an HMAC-SHA256 token
+// as query parameters, i.e. URI?<ExpiresParam>=<unix time>&<TokenParam>=<hex HMAC>.
+//
+// The HMAC is calculated over the escaped path and the query, up to and including the
+// expiry parameter, e.g. "/live/seg1.ts?expires=1700000000". A CDN verifies the token by
+// removing the token parameter and calculating the same HMAC. Since the path of a relative
+// URI differs from what the CDN sees, resolve the URIs first, e.g. with NewResolver.
+//
+// URIs with another scheme than http or https, e.g. data: or skd: key URIs, are not signed.
+type HMACQuerySigner struct {
+	Key          []byte    // Key is the secret HMAC key
+	Expires      time.Time // Expires is the expiry time of the token
+	ExpiresParam string    // ExpiresParam is the name of the expiry parameter, "expires" if empty
+	TokenParam   string    // TokenParam is the name of the token parameter, "token" if empty
+	Kinds        []URIKind // Kinds to sign. All kinds are signed if empty
+}
+
+// SignURI implements URISigner.
+func (s *HMACQuerySigner) SignURI(kind URIKind, uri string) (string, error) {
+	if !signKind(s.Kinds, kind) {
+		return uri, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if !signScheme(u) {
+		return uri, nil
+	}
+	expiresParam := s.ExpiresParam
+	if expiresParam == "" {
+		expiresParam = "expires"
+	}
+	tokenParam := s.TokenParam
+	if tokenParam == "" {
+		tokenParam = "token"
+	}
+	query := u.RawQuery
+	if query != "" {
+		query += "&"
+	}
+	query += expiresParam + "=" + strconv.FormatInt(s.Expires.Unix(), 10)
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(u.EscapedPath() + "?" + query))
+	u.RawQuery = query + "&" + tokenParam + "=" + hex.EncodeToString(mac.Sum(nil))
+	return u.String(), nil
+}
+
+// PathTokenSigner signs URIs by inserting a token as the first path element,
+// i.e. /<unix time>-<hex HMAC>/path. The HMAC-SHA256 is calculated over the expiry time
+// and the escaped path, e.g. "1700000000/live/seg1.ts". Query strings are kept as is.
+//
+// Only absolute and root-relative URIs can be signed, since a token cannot be inserted
+// in front of a path that is relative to the playlist. For such URIs ErrRelativeURI is
+// returned. Resolve the URIs first, e.g. with NewResolver.
+//
+// URIs with another scheme than http or https, e.g. data: or skd: key URIs, are not signed.
+type PathTokenSigner struct {
+	Key     []byte    // Key is the secret HMAC key
+	Expires time.Time // Expires is the expiry time of the token
+	Kinds   []URIKind // Kinds to sign. All kinds are signed if empty
+}
+
+// SignURI implements URISigner.
+func (s *PathTokenSigner) SignURI(kind URIKind, uri string) (string, error) {
+	if !signKind(s.Kinds, kind) {
+		return uri, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if !signScheme(u) {
+		return uri, nil
+	}
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") {
+		return "", ErrRelativeURI
+	}
+	expires := strconv.FormatInt(s.Expires.Unix(), 10)
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(expires + path))
+	signedPath := "/" + expires + "-" + hex.EncodeToString(mac.Sum(nil)) + path
+	u.Path, err = url.PathUnescape(signedPath)
+	if err != nil {
+		return "", err
+	}
+	u.RawPath = signedPath
+	return u.String(), nil
+}
+
+func signKind(kinds []URIKind, kind URIKind) bool {
+	return len(kinds) == 0 || slices.Contains(kinds, kind)
+}
+
+// signScheme reports whether URIs with the scheme of u are signed. Relative URIs have no scheme.
+func signScheme(u *url.URL) bool {
+	return u.Scheme == "" || u.Scheme == "http" || u.Scheme == "https"
+}
@@ -0,0 +1,101 @@
+package m3u8
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestHMACQuerySigner(t *testing.T) {
+	is := is.New(t)
+	s := &HMACQuerySigner{Key: []byte("secret"), Expires: time.Unix(1700000000, 0)}
+
+	signed, err := s.SignURI(URISegment, "https://cdn.example.com/live/seg1.ts?a=b")
+	is.NoErr(err)
+	u, err := url.Parse(signed)
+	is.NoErr(err)
+	is.Equal(u.Query().Get("expires"), "1700000000")
+	is.Equal(u.Query().Get("a"), "b") // existing query must be kept
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("/live/seg1.ts?a=b&expires=1700000000"))
+	is.Equal(u.Query().Get("token"), hex.EncodeToString(mac.Sum(nil))) // token must verify
+
+	for _, uri := range []string{"skd://key1", "data:text/plain;base64,AAAA"} {
+		signed, err = s.SignURI(URIKey, uri)
+		is.NoErr(err)
+		is.Equal(signed, uri) // non-http URIs must not be signed
+	}
+
+	s.Kinds = []URIKind{URIKey}
+	signed, err = s.SignURI(URISegment, "seg1.ts")
+	is.NoErr(err)
+	is.Equal(signed, "seg1.ts") // kind not selected
+	s.ExpiresParam, s.TokenParam = "e", "t"
+	signed, err = s.SignURI(URIKey, "key.bin")
+	is.NoErr(err)
+	is.True(strings.HasPrefix(signed, "key.bin?e=1700000000&t="))
+}
+
+func TestPathTokenSigner(t *testing.T) {
+	is := is.New(t)
+	s := &PathTokenSigner{Key: []byte("secret"), Expires: time.Unix(1700000000, 0)}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000/live/seg%201.ts"))
+	token := "1700000000-" + hex.EncodeToString(mac.Sum(nil))
+
+	signed, err := s.SignURI(URISegment, "https://cdn.example.com/live/seg%201.ts?a=b")
+	is.NoErr(err)
+	is.Equal(signed, "https://cdn.example.com/"+token+"/live/seg%201.ts?a=b")
+
+	_, err = s.SignURI(URISegment, "seg1.ts")
+	is.True(errors.Is(err, ErrRelativeURI)) // relative URI cannot get a path token
+}
+
+func TestMediaPlaylistSign(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-fmp4.m3u8")
+	is.NoErr(err) // must open file
+	p, _, err := DecodeFrom(bufio.NewReader(f), true)
+	is.NoErr(err) // must decode playlist
+	pl := p.(*MediaPlaylist)
+	want := pl.String()
+
+	signed, err := pl.Sign(URISignerFunc(func(kind URIKind, uri string) (string, error) {
+		return uri + "?sig=" + kind.String(), nil
+	}))
+	is.NoErr(err)
+	out := signed.String()
+	is.True(strings.Contains(out, "?sig=Segment\n"))
+	is.True(strings.Contains(out, `?sig=Map"`))
+	pl.ResetCache()
+	is.Equal(pl.String(), want) // shared playlist must not change
+
+	_, err = pl.Sign(&PathTokenSigner{Key: []byte("k")})
+	is.True(errors.Is(err, ErrRelativeURI)) // error must be returned
+}
+
+func TestMasterPlaylistSign(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-with-alternatives.m3u8")
+	is.NoErr(err) // must open file
+	p := NewMasterPlaylist()
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true)) // must decode playlist
+	want := p.String()
+
+	signed, err := p.Sign(&HMACQuerySigner{Key: []byte("k"), Expires: time.Unix(1, 0)})
+	is.NoErr(err)
+	for _, v := range signed.Variants {
+		is.True(strings.Contains(v.URI, "?expires=1&token="))
+	}
+	p.ResetCache()
+	is.Equal(p.String(), want) // shared playlist must not change
+}
@@ -7,6 +7,8 @@ package m3u8
 import (
 	"bytes"
 	"io"
+	"reflect"
+	"slices"
 	"time"
 )
 
@@ -52,6 +54,112 @@ type CustomTag interface {
 // CustomMap maps custom tags names to CustomTag
 type CustomMap map[string]CustomTag
 
+// CustomTags is a list of custom tags in the order they are written. A tag name may
+// occur more than once.
+type CustomTags []CustomTag
+
+// Get returns the first tag with the name, or nil if there is none.
+func (t CustomTags) Get(name string) CustomTag {
+	for _, tag := range t {
+		if tag.TagName() == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// GetAll returns all tags with the name.
+func (t CustomTags) GetAll(name string) []CustomTag {
+	var tags []CustomTag
+	for _, tag := range t {
+		if tag.TagName() == name {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// set replaces the tags with the same name by tag, at the position of the first of them,
+// or appends tag if there is none.
+func (t *CustomTags) set(tag CustomTag) {
+	i := slices.IndexFunc(*t, func(c CustomTag) bool { return c.TagName() == tag.TagName() })
+	if i < 0 {
+		*t = append(*t, tag)
+		return
+	}
+	(*t)[i] = tag
+	kept := (*t)[:i+1]
+	for _, c := range (*t)[i+1:] {
+		if c.TagName() != tag.TagName() {
+			kept = append(kept, c)
+		}
+	}
+	*t = kept
+}
+
+// orderedCustomTags returns the tags of m in the order they were added, as recorded in
+// order. A name whose tag in m is the last one added for it gets all its tags in order.
+// A tag that was put directly in m replaces the tags of its name, and tags only in m
+// follow the others, sorted by name.
+func orderedCustomTags(m CustomMap, order CustomTags) CustomTags {
+	if len(m) == 0 {
+		return nil
+	}
+	last := make(map[string]CustomTag, len(m))
+	for _, tag := range order {
+		last[tag.TagName()] = tag
+	}
+	tags := make(CustomTags, 0, max(len(order), len(m)))
+	written := make(map[string]bool, len(m))
+	for _, tag := range order {
+		name := tag.TagName()
+		current, ok := m[name]
+		switch {
+		case !ok:
+			// removed from the map
+		case sameCustomTag(current, last[name]):
+			tags = append(tags, tag)
+			written[name] = true
+		case !written[name]:
+			tags = append(tags, current)
+			written[name] = true
+		}
+	}
+	names := make([]string, 0, len(m)-len(written))
+	for name := range m {
+		if !written[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		tags = append(tags, m[name])
+	}
+	return tags
+}
+
+// sameCustomTag tells if a and b are the same tag, also for tag types that are not comparable.
+func sameCustomTag(a, b CustomTag) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// CustomTagPlacement is the position of the custom tags of a playlist among the header tags.
+type CustomTagPlacement uint
+
+const (
+	// CustomTagsDefault writes custom tags after EXT-X-INDEPENDENT-SEGMENTS in media
+	// playlists, and after EXT-X-SESSION-KEY in master playlists.
+	CustomTagsDefault CustomTagPlacement = iota
+	// CustomTagsAfterVersion writes custom tags right after EXT-X-VERSION.
+	CustomTagsAfterVersion
+	// CustomTagsEndOfHeader writes custom tags after all other header tags, before the
+	// first segment or variant.
+	CustomTagsEndOfHeader
+)
+
 const (
 	// minVer is the minimum version of the HLS protocol supported by this package.
 	// Version 3, means that floating point EXTINF durations are used.
@@ -128,67 +236,88 @@ const (
 // It is used for both VOD, EVENT and sliding window live media playlists with window size.
 // URI lines in the Playlist point to media segments.
 type MediaPlaylist struct {
-	TargetDuration      uint              // TargetDuration is max media segment duration. Rounding depends on version.
-	SeqNo               uint64            // EXT-X-MEDIA-SEQUENCE
-	Segments            []*MediaSegment   // List of segments in the playlist. Output may be limited by winsize.
-	Args                string            // optional query placed after URIs (URI?Args)
-	Defines             []Define          // EXT-X-DEFINE tags
-	Iframe              bool              // EXT-X-I-FRAMES-ONLY
-	Closed              bool              // is this VOD/EVENT (closed) or Live (sliding) playlist?
-	MediaType           MediaType         // EXT-X-PLAYLIST-TYPE (EVENT, VOD or empty)
-	DiscontinuitySeq    uint64            // EXT-X-DISCONTINUITY-SEQUENCE
-	StartTime           float64           // EXT-X-START:TIME-OFFSET=<n> (positive or negative)
-	StartTimePrecise    bool              // EXT-X-START:PRECISE=YES
-	Keys                []Key             // EXT-X-KEY is initial key tag for encrypted segments
-	Map                 *Map              // EXT-X-MAP provides a Media Initialization Section. Segments can redefine.
-	DateRanges          []*DateRange      // EXT-X-DATERANGE tags not associated with SCTE-35
-	TrailingDateRanges  []*DateRange      // EXT-X-DATERANGE tags (SCTE-35) after the last segment
-	AllowCache          *bool             // EXT-X-ALLOW-CACHE tag YES/NO, removed in version 7
-	Custom              CustomMap         // Custom-provided tags for encoding
-	customDecoders      []CustomDecoder   // customDecoders provides custom tags for decoding
-	winsize             uint              // max number of segments encoded sliding playlist, set to 0 for VOD and EVENT
-	capacity            uint              // total capacity of slice used for the playlist
-	head                uint              // head of FIFO (ring buffer), we remove segments from head
-	tail                uint              // tail of FIFO (ring buffer), we add segments to tail
-	count               uint              // number of segments added to the playlist
-	buf                 bytes.Buffer      // buffer used for encoding and caching playlist output
-	scte35Syntax        SCTE35Syntax      // SCTE-35 syntax used in the playlist
-	ver                 uint8             // protocol version of the playlist, 3 or higher
-	targetDurLocked     bool              // target duration is locked and cannot be changed
-	independentSegments bool              // Global tag for EXT-X-INDEPENDENT-SEGMENTS
-	PartTargetDuration  float64           // EXT-X-PART-INF:PART-TARGET
-	PartialSegments     []*PartialSegment // List of partial segments in the playlist.
-	SegmentIndexing     SegmentIndexing   // The indexing parameters for media and partial segments.
-	PreloadHints        *PreloadHint      // EXT-X-PRELOAD-HINT tags
-	ServerControl       *ServerControl    // EXT-X-SERVER-CONTROL tags, MAY appear in any Media Playlist
-	skippedSegments     uint64            // EXT-X-SKIP:SKIPPED-SEGMENTS tag parsed from the playlist. Read-only
-	writePrecision      int               // Output decimal places for float values (-1 provides necessary number)
+	TargetDuration      uint               // TargetDuration is max media segment duration. Rounding depends on version.
+	SeqNo               uint64             // EXT-X-MEDIA-SEQUENCE
+	Segments            []*MediaSegment    // List of segments in the playlist. Output may be limited by winsize.
+	Args                string             // optional query placed after URIs (URI?Args)
+	Defines             []Define           // EXT-X-DEFINE tags
+	Iframe              bool               // EXT-X-I-FRAMES-ONLY
+	Closed              bool               // is this VOD/EVENT (closed) or Live (sliding) playlist?
+	MediaType           MediaType          // EXT-X-PLAYLIST-TYPE (EVENT, VOD or empty)
+	DiscontinuitySeq    uint64             // EXT-X-DISCONTINUITY-SEQUENCE
+	StartTime           float64            // EXT-X-START:TIME-OFFSET=<n> (positive or negative)
+	StartTimePrecise    bool               // EXT-X-START:PRECISE=YES
+	StartUnknownAttrs   []Attribute        // Unknown attributes of EXT-X-START, kept in preserve mode
+	Keys                []Key              // EXT-X-KEY is initial key tag for encrypted segments
+	Map                 *Map               // EXT-X-MAP provides a Media Initialization Section. Segments can redefine.
+	DateRanges          []*DateRange       // EXT-X-DATERANGE tags not associated with SCTE-35
+	TrailingDateRanges  []*DateRange       // EXT-X-DATERANGE tags (SCTE-35) after the last segment
+	AllowCache          *bool              // EXT-X-ALLOW-CACHE tag YES/NO, removed in version 7
+	Custom              CustomMap          // Custom-provided tags for encoding
+	customOrder         CustomTags         // custom tags in insertion order, also repeated ones
+	customPlacement     CustomTagPlacement // position of the custom tags among the header tags
+	UnknownTags         []string           // Unknown header tags, kept when decoding in preserve mode
+	TrailingUnknownTags []string           // Unknown tags after the last segment, kept in preserve mode
+	customDecoders      []CustomDecoder    // customDecoders provides custom tags for decoding
+	preserveUnknown     bool               // keep unknown tags and attributes when decoding
+	winsize             uint               // max number of segments encoded sliding playlist, 0 for VOD and EVENT
+	windowDuration      float64            // duration in seconds of a sliding playlist, used instead of winsize if > 0
+	growable            bool               // capacity grows when a segment is appended to a full playlist
+	pdtClock            *dateTimeClock     // automatic EXT-X-PROGRAM-DATE-TIME, nil if off
+	keyRotator          *keyRotator        // automatic EXT-X-KEY, nil if off
+	capacity            uint               // total capacity of slice used for the playlist
+	head                uint               // head of FIFO (ring buffer), we remove segments from head
+	tail                uint               // tail of FIFO (ring buffer), we add segments to tail
+	count               uint               // number of segments added to the playlist
+	segmentsDuration    float64            // total duration of the segments in the ring buffer
+	buf                 bytes.Buffer       // buffer used for encoding and caching playlist output
+	scte35Syntax        SCTE35Syntax       // SCTE-35 syntax used in the playlist
+	ver                 uint8              // protocol version of the playlist, 3 or higher
+	targetDurLocked     bool               // target duration is locked and cannot be changed
+	independentSegments bool               // Global tag for EXT-X-INDEPENDENT-SEGMENTS
+	PartTargetDuration  float64            // EXT-X-PART-INF:PART-TARGET
+	PartialSegments     []*PartialSegment  // List of partial segments in the playlist.
+	SegmentIndexing     SegmentIndexing    // The indexing parameters for media and partial segments.
+	PreloadHints        *PreloadHint       // EXT-X-PRELOAD-HINT tag, of any type but MAP
+	PreloadMapHint      *PreloadHint       // EXT-X-PRELOAD-HINT tag of TYPE=MAP
+	ServerControl       *ServerControl     // EXT-X-SERVER-CONTROL tags, MAY appear in any Media Playlist
+	skippedSegments     uint64             // EXT-X-SKIP:SKIPPED-SEGMENTS tag parsed from the playlist. Read-only
+	writePrecision      int                // Output decimal places for float values (-1 provides necessary number)
 }
 
 // MasterPlaylist represents a master (multivariant) playlist which
 // provides parameters and lists one or more media playlists. URI lines in the
 // playlist identify media playlists.
 type MasterPlaylist struct {
-	Variants            []*Variant       // Variants is a list of media playlists
-	Args                string           // optional query placed after URI (URI?Args)
-	StartTime           float64          // EXT-X-START:TIME-OFFSET=<n> (positive or negative)
-	StartTimePrecise    bool             // EXT-X-START:PRECISE=YES
-	Defines             []Define         // EXT-X-DEFINE tags
-	SessionDatas        []*SessionData   // EXT-X-SESSION-DATA tags
-	SessionKeys         []*Key           // EXT-X-SESSION-KEY tags
-	ContentSteering     *ContentSteering // EXT-X-CONTENT-STEERING tag
-	buf                 bytes.Buffer     // buffer used for encoding and caching playlist
-	ver                 uint8            // protocol version of the playlist, 3 or higher
-	independentSegments bool             // Global tag for EXT-X-INDEPENDENT-SEGMENTS
-	Custom              CustomMap        // Custom-provided tags for encoding
-	customDecoders      []CustomDecoder  // customDecoders provided custom tags for decoding
-	writePrecision      int              // Output decimal places for float values (-1 provides necessary number)
+	Variants            []*Variant         // Variants is a list of media playlists
+	RenditionGroups     []*RenditionGroup  // EXT-X-MEDIA renditions by group, in declaration order
+	renditionOrder      []*Alternative     // renditions of all groups in declaration order
+	Args                string             // optional query placed after URI (URI?Args)
+	StartTime           float64            // EXT-X-START:TIME-OFFSET=<n> (positive or negative)
+	StartTimePrecise    bool               // EXT-X-START:PRECISE=YES
+	StartUnknownAttrs   []Attribute        // Unknown attributes of EXT-X-START, kept in preserve mode
+	Defines             []Define           // EXT-X-DEFINE tags
+	SessionDatas        []*SessionData     // EXT-X-SESSION-DATA tags
+	SessionKeys         []*Key             // EXT-X-SESSION-KEY tags
+	ContentSteering     *ContentSteering   // EXT-X-CONTENT-STEERING tag
+	buf                 bytes.Buffer       // buffer used for encoding and caching playlist
+	ver                 uint8              // protocol version of the playlist, 3 or higher
+	independentSegments bool               // Global tag for EXT-X-INDEPENDENT-SEGMENTS
+	Custom              CustomMap          // Custom-provided tags for encoding
+	customOrder         CustomTags         // custom tags in insertion order, also repeated ones
+	customPlacement     CustomTagPlacement // position of the custom tags among the header tags
+	UnknownTags         []string           // Unknown header tags, kept when decoding in preserve mode
+	TrailingUnknownTags []string           // Unknown tags after the last variant, kept in preserve mode
+	customDecoders      []CustomDecoder    // customDecoders provided custom tags for decoding
+	preserveUnknown     bool               // keep unknown tags and attributes when decoding
+	writePrecision      int                // Output decimal places for float values (-1 provides necessary number)
 }
 
 // Variant structure represents media playlist variants in master playlists.
 type Variant struct {
-	URI       string         // URI is the path to the media playlist. Parameter for I-frame playlist.
-	Chunklist *MediaPlaylist // Chunklist is the media playlist for the variant.
+	URI         string         // URI is the path to the media playlist. Parameter for I-frame playlist.
+	Chunklist   *MediaPlaylist // Chunklist is the media playlist for the variant.
+	UnknownTags []string       // Unknown tags before the variant, kept when decoding in preserve mode
 	VariantParams
 }
 
@@ -217,26 +346,81 @@ type VariantParams struct {
 	ProgramId          *int           // PROGRAM-ID parameter. Removed in version 6
 	Iframe             bool           // EXT-X-I-FRAME-STREAM-INF flag.
 	Alternatives       []*Alternative // EXT-X-MEDIA parameters
+	UnknownAttrs       []Attribute    // Unknown attributes, kept when decoding in preserve mode
+}
+
+// VideoRange is a VIDEO-RANGE value.
+type VideoRange string
+
+const (
+	VideoRangeSDR VideoRange = "SDR"
+	VideoRangeHLG VideoRange = "HLG"
+	VideoRangePQ  VideoRange = "PQ"
+)
+
+// Valid tells if r is a VIDEO-RANGE value defined by the specification.
+func (r VideoRange) Valid() bool {
+	return r == VideoRangeSDR || r == VideoRangeHLG || r == VideoRangePQ
+}
+
+// HDCPLevel is an HDCP-LEVEL value.
+type HDCPLevel string
+
+const (
+	HDCPLevelNone  HDCPLevel = "NONE"
+	HDCPLevelType0 HDCPLevel = "TYPE-0"
+	HDCPLevelType1 HDCPLevel = "TYPE-1"
+)
+
+// hdcpLevels lists the HDCP-LEVEL values from the lowest to the highest.
+var hdcpLevels = []HDCPLevel{HDCPLevelNone, HDCPLevelType0, HDCPLevelType1}
+
+// Valid tells if l is an HDCP-LEVEL value defined by the specification.
+func (l HDCPLevel) Valid() bool {
+	return slices.Contains(hdcpLevels, l)
 }
 
 // Alternative represents an EXT-X-MEDIA tag.
 // Attributes are listed in same order as in specification for easy comparison.
 type Alternative struct {
-	Type              string    // TYPE parameter
-	URI               string    // URI parameter
-	GroupId           string    // GROUP-ID parameter
-	Language          string    // LANGUAGE parameter
-	AssocLanguage     string    // ASSOC-LANGUAGE parameter
-	Name              string    // NAME parameter
-	StableRenditionId string    // STABLE-RENDITION-ID parameter
-	Default           bool      // DEFAULT parameter
-	Autoselect        bool      // AUTOSELECT parameter
-	Forced            bool      // FORCED parameter
-	InstreamId        string    // INSTREAM-ID parameter
-	BitDepth          byte      // BIT-DEPTH parameter
-	SampleRate        uint32    // SAMPLE-RATE parameter
-	Characteristics   string    // CHARACTERISTICS parameter
-	Channels          *Channels // CHANNELS parameter
+	Type              string         // TYPE parameter
+	URI               string         // URI parameter
+	GroupId           string         // GROUP-ID parameter
+	Language          string         // LANGUAGE parameter
+	AssocLanguage     string         // ASSOC-LANGUAGE parameter
+	Name              string         // NAME parameter
+	StableRenditionId string         // STABLE-RENDITION-ID parameter
+	Default           bool           // DEFAULT parameter
+	Autoselect        bool           // AUTOSELECT parameter
+	Forced            bool           // FORCED parameter
+	InstreamId        string         // INSTREAM-ID parameter
+	BitDepth          byte           // BIT-DEPTH parameter
+	SampleRate        uint32         // SAMPLE-RATE parameter
+	Characteristics   string         // CHARACTERISTICS parameter
+	Channels          *Channels      // CHANNELS parameter
+	UnknownAttrs      []Attribute    // Unknown attributes, kept when decoding in preserve mode
+	UnknownTags       []string       // Unknown tags before the rendition, kept when decoding in preserve mode
+	Chunklist         *MediaPlaylist // Chunklist is the media playlist of the rendition, if known
+}
+
+// RenditionGroup is a group of EXT-X-MEDIA renditions with the same TYPE and GROUP-ID.
+// Variants refer to groups by their VIDEO, AUDIO, SUBTITLES and CLOSED-CAPTIONS attributes.
+type RenditionGroup struct {
+	Type       string         // TYPE parameter of the renditions
+	GroupId    string         // GROUP-ID parameter of the renditions
+	Renditions []*Alternative // Renditions in declaration order
+}
+
+// Equal compares two keys for equality.
+func (k *Key) Equal(other *Key) bool {
+	return k.Method == other.Method && k.URI == other.URI && k.IV == other.IV &&
+		k.Keyformat == other.Keyformat && k.Keyformatversions == other.Keyformatversions &&
+		slices.Equal(k.UnknownAttrs, other.UnknownAttrs)
+}
+
+// keysEqual compares two lists of keys for equality.
+func keysEqual(a, b []Key) bool {
+	return slices.EqualFunc(a, b, func(x, y Key) bool { return x.Equal(&y) })
 }
 
 type Channels struct {
@@ -261,20 +445,23 @@ type MediaSegment struct {
 	SCTE35DateRanges []*DateRange // SCTE-35 date-range tags preceeding this segment
 	ProgramDateTime  time.Time    // EXT-X-PROGRAM-DATE-TIME associates first sample with an absolute date and/or time.
 	Custom           CustomMap    // Custom holds custom tags
+	customOrder      CustomTags   // custom tags in insertion order, also repeated ones
+	UnknownTags      []string     // Unknown tags before the segment, kept when decoding in preserve mode
 	Gap              bool
 }
 
 // PartialSegment represents a partial segment included in a low-latency
 // media playlist.
 type PartialSegment struct {
-	SeqID           uint64    // Sequence ID of the partial segment
-	URI             string    // EXT-X-PART:URI
-	Duration        float64   // EXT-X-PART:DURATION
-	Independent     bool      // EXT-X-PART:INDEPENDENT
-	ProgramDateTime time.Time // EXT-X-PROGRAM-DATE-TIME
-	Offset          int64     // EXT-X-PART:BYTERANGE [@o] is offset from the start of the file under URI.
-	Limit           int64     // EXT-X-PART:BYTERANGE <n> is length in bytes for the file under URI.
-	Gap             bool      // EXT-X-PART:GAP enumerated-string ("YES" if the Partial Segment is not available)
+	SeqID           uint64      // Sequence ID of the partial segment
+	URI             string      // EXT-X-PART:URI
+	Duration        float64     // EXT-X-PART:DURATION
+	Independent     bool        // EXT-X-PART:INDEPENDENT
+	ProgramDateTime time.Time   // EXT-X-PROGRAM-DATE-TIME
+	Offset          int64       // EXT-X-PART:BYTERANGE [@o] is offset from the start of the file under URI.
+	Limit           int64       // EXT-X-PART:BYTERANGE <n> is length in bytes for the file under URI.
+	Gap             bool        // EXT-X-PART:GAP enumerated-string ("YES" if the Partial Segment is not available)
+	UnknownAttrs    []Attribute // Unknown attributes, kept when decoding in preserve mode
 }
 
 // SegmentIndexing holds the indexing parameters for media and partial segments in the low-latency media playlist.
@@ -297,21 +484,37 @@ type SegmentIndexing struct {
 	MaxPartIndex uint64
 }
 
+// Types of EXT-X-MEDIA renditions.
+const (
+	RenditionAudio          = "AUDIO"           // RenditionAudio is an audio rendition
+	RenditionVideo          = "VIDEO"           // RenditionVideo is a video rendition
+	RenditionSubtitles      = "SUBTITLES"       // RenditionSubtitles is a subtitles rendition
+	RenditionClosedCaptions = "CLOSED-CAPTIONS" // RenditionClosedCaptions is a closed-captions rendition
+)
+
+// Types of EXT-X-PRELOAD-HINT tags.
+const (
+	PreloadHintPart = "PART" // PreloadHintPart hints a Partial Segment
+	PreloadHintMap  = "MAP"  // PreloadHintMap hints a Media Initialization Section
+)
+
 type PreloadHint struct {
 	// #EXT-X-PRELOAD-HINT:
-	Type   string // TYPE ("PART" -> Partial Segment; "MAP" -> Media Initialization Section)
-	URI    string // URI
-	Offset int64  // BYTERANGE-START
-	Limit  int64  // BYTERANGE-LENGTH
+	Type         string      // TYPE ("PART" -> Partial Segment; "MAP" -> Media Initialization Section)
+	URI          string      // URI
+	Offset       int64       // BYTERANGE-START
+	Limit        int64       // BYTERANGE-LENGTH, 0 if the range continues to the end of the resource
+	UnknownAttrs []Attribute // Unknown attributes, kept when decoding in preserve mode
 }
 
 type ServerControl struct {
 	// #EXT-X-SERVER-CONTROL:
-	CanSkipUntil      float64 // CAN-SKIP-UNTIL
-	CanSkipDateRanges bool    // CAN-SKIP-DATERANGES
-	HoldBack          float64 // HOLD-BACK
-	PartHoldBack      float64 // PART-HOLD-BACK
-	CanBlockReload    bool    // CAN-BLOCK-RELOAD
+	CanSkipUntil      float64     // CAN-SKIP-UNTIL
+	CanSkipDateRanges bool        // CAN-SKIP-DATERANGES
+	HoldBack          float64     // HOLD-BACK
+	PartHoldBack      float64     // PART-HOLD-BACK
+	CanBlockReload    bool        // CAN-BLOCK-RELOAD
+	UnknownAttrs      []Attribute // Unknown attributes, kept when decoding in preserve mode
 }
 
 // SCTE holds custom SCTE-35 tags.
@@ -327,11 +530,12 @@ type SCTE struct {
 
 // Key structure represents information about stream encryption (EXT-X-KEY tag)
 type Key struct {
-	Method            string // METHOD parameter
-	URI               string // URI parameter
-	IV                string // IV parameter
-	Keyformat         string // KEYFORMAT parameter
-	Keyformatversions string // KEYFORMATVERSIONS parameter
+	Method            string      // METHOD parameter
+	URI               string      // URI parameter
+	IV                string      // IV parameter
+	Keyformat         string      // KEYFORMAT parameter
+	Keyformatversions string      // KEYFORMATVERSIONS parameter
+	UnknownAttrs      []Attribute // Unknown attributes, kept when decoding in preserve mode
 }
 
 // Map (EXT-X-MAP tag) specifies how obtain the Media
@@ -342,9 +546,10 @@ type Key struct {
 // Playlist until the next EXT-X-MAP tag or until the end of the
 // playlist.
 type Map struct {
-	URI    string // URI is the path to the Media Initialization Section.
-	Limit  int64  // <n> is length in bytes for the file under URI
-	Offset int64  // [@o] is offset from the start of the file under URI
+	URI          string      // URI is the path to the Media Initialization Section.
+	Limit        int64       // <n> is length in bytes for the file under URI
+	Offset       int64       // [@o] is offset from the start of the file under URI
+	UnknownAttrs []Attribute // Unknown attributes, kept when decoding in preserve mode
 }
 
 // Equal compares two MediaSegment for equality.
@@ -355,7 +560,8 @@ func (m *Map) Equal(other *Map) bool {
 	if m == nil || other == nil {
 		return false
 	}
-	return m.URI == other.URI && m.Limit == other.Limit && m.Offset == other.Offset
+	return m.URI == other.URI && m.Limit == other.Limit && m.Offset == other.Offset &&
+		slices.Equal(m.UnknownAttrs, other.UnknownAttrs)
 }
 
 // Internal structure for decoding a line of input stream with a list type detection
@@ -390,6 +596,8 @@ type decodingState struct {
 	scte               *SCTE
 	scte35DateRanges   []*DateRange
 	custom             CustomMap
+	customOrder        CustomTags
+	unknownTags        []string // unknown tags waiting for the next segment or variant
 }
 
 // DateRange corresponds to EXT-X-DATERANGE tag.
@@ -432,11 +640,12 @@ type Define struct {
 
 // SessionData represents an EXT-X-SESSION-DATA tag.
 type SessionData struct {
-	DataId   string // DATA-ID is a mandatory quoted-string
-	Value    string // VALUE is a quoted-string
-	URI      string // URI is a quoted-string
-	Format   string // FORMAT is enumerated string. Values are JSON and RAW (default is JSON)
-	Language string // LANGUAGE is a quoted-string containing an [RFC5646] language tag
+	DataId       string      // DATA-ID is a mandatory quoted-string
+	Value        string      // VALUE is a quoted-string
+	URI          string      // URI is a quoted-string
+	Format       string      // FORMAT is enumerated string. Values are JSON and RAW (default is JSON), only with URI
+	Language     string      // LANGUAGE is a quoted-string containing an [RFC5646] language tag
+	UnknownAttrs []Attribute // Unknown attributes, kept when decoding in preserve mode
 }
 
 // ContentSteering represents an EXT-X-CONTENT-STEERING tag.
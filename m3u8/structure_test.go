@@ -54,6 +54,48 @@ func TestMapEqual(t *testing.T) {
 	}
 }
 
+func TestKeyEqual(t *testing.T) {
+	cases := []struct {
+		desc   string
+		k1, k2 Key
+		equal  bool
+	}{
+		{desc: "equal", k1: Key{Method: "AES-128", URI: "a"}, k2: Key{Method: "AES-128", URI: "a"}, equal: true},
+		{desc: "different URI", k1: Key{Method: "AES-128", URI: "a"}, k2: Key{Method: "AES-128", URI: "b"}, equal: false},
+		{desc: "different unknown attributes", k1: Key{URI: "a", UnknownAttrs: []Attribute{{Key: "X", Val: "1"}}},
+			k2: Key{URI: "a"}, equal: false},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if c.k1.Equal(&c.k2) != c.equal {
+				t.Fatalf("Expected %v, got %v for %s", c.equal, c.k1.Equal(&c.k2), c.desc)
+			}
+		})
+	}
+}
+
+func TestCustomTags(t *testing.T) {
+	a1 := &MockCustomTag{name: "#A", encodedString: "#A:1"}
+	a2 := &MockCustomTag{name: "#A", encodedString: "#A:2"}
+	b := &MockCustomTag{name: "#B", encodedString: "#B"}
+	tags := CustomTags{a1, b, a2}
+	if tags.Get("#A") != a1 || tags.Get("#C") != nil {
+		t.Fatalf("Get returned wrong tag")
+	}
+	if len(tags.GetAll("#A")) != 2 {
+		t.Fatalf("Expected 2 tags, got %d", len(tags.GetAll("#A")))
+	}
+	a3 := &MockCustomTag{name: "#A", encodedString: "#A:3"}
+	tags.set(a3)
+	if len(tags) != 2 || tags[0] != a3 || tags[1] != b {
+		t.Fatalf("set should replace all #A tags at the first position, got %v", tags)
+	}
+	tags.set(&MockCustomTag{name: "#C"})
+	if len(tags) != 3 || tags[2].TagName() != "#C" {
+		t.Fatalf("set should append a new tag, got %v", tags)
+	}
+}
+
 type MockCustomTag struct {
 	name          string
 	err           error
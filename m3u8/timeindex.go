@@ -0,0 +1,179 @@
+package m3u8
+
+/*
+ This file defines an index for looking up segments by media time or program date time.
+*/
+
+import (
+	"sort"
+	"time"
+)
+
+// TimeIndex maps media time and EXT-X-PROGRAM-DATE-TIME to the segments and partial
+// segments of a media playlist, with O(log n) lookups. Create it with
+// MediaPlaylist.TimeIndex. The index is a snapshot, so create a new one after the
+// playlist has changed.
+type TimeIndex struct {
+	segments []*MediaSegment
+	starts   []float64   // media time of the start of each segment
+	pdts     []time.Time // program date time of the start of each segment, nil if none
+	parts    []indexedPart
+	duration float64
+}
+
+// indexedPart is a partial segment with its position in the index.
+type indexedPart struct {
+	part    *PartialSegment
+	segment int     // index of the full segment, len(segments) for the next, not yet complete, one
+	start   float64 // media time of the start of the part
+}
+
+// TimePosition is the result of a TimeIndex lookup.
+type TimePosition struct {
+	// Segment is the segment covering the time. It is nil if the time is covered by a
+	// partial segment of the next, not yet complete, segment.
+	Segment *MediaSegment
+	// Index is the position of Segment in the playlist, 0 being the first segment.
+	Index int
+	// Start is the media time of the start of Segment, or of the next segment if Segment is nil.
+	Start float64
+	// Offset is the time from Start to the looked up time.
+	Offset float64
+	// ProgramDateTime is the program date time of Start, interpolated if the segment has no
+	// EXT-X-PROGRAM-DATE-TIME of its own. It is zero if the playlist has no program date time.
+	ProgramDateTime time.Time
+	// Part is the partial segment covering the time, if there is one.
+	Part *PartialSegment
+	// PartOffset is the time from the start of Part to the looked up time.
+	PartOffset float64
+}
+
+// TimeIndex returns an index of the segments written by Encode, i.e. the window of a
+// live playlist, and of the partial segments belonging to them. Media time
+// starts at 0 with the first of these segments, and continues with the partial segments of
+// the next, not yet complete, segment.
+//
+// Program date times are interpolated from the closest preceding segment with an
+// EXT-X-PROGRAM-DATE-TIME, or extrapolated backwards for leading segments, and are
+// assumed to increase throughout the playlist.
+func (p *MediaPlaylist) TimeIndex() *TimeIndex {
+	start, outputCount := p.outputWindow()
+	ix := &TimeIndex{
+		segments: make([]*MediaSegment, 0, outputCount),
+	}
+	for i := uint(0); i < outputCount; i++ {
+		if seg := p.Segments[(start+i)%p.capacity]; seg != nil {
+			ix.segments = append(ix.segments, seg)
+		}
+	}
+	ix.starts = make([]float64, len(ix.segments))
+	for i, seg := range ix.segments {
+		ix.starts[i] = ix.duration
+		ix.duration += seg.Duration
+	}
+	ix.pdts = programDateTimes(ix.segments)
+
+	// Partial segments carry the sequence number of the segment they belong to
+	segIdx := make(map[uint64]int, len(ix.segments)+1)
+	for i, seg := range ix.segments {
+		segIdx[seg.SeqId] = i
+	}
+	segIdx[p.nextSeqId()] = len(ix.segments)
+	partStarts := make(map[int]float64)
+	for _, ps := range p.PartialSegments {
+		i, ok := segIdx[ps.SeqID]
+		if !ok {
+			continue // belongs to a segment that is no longer in the window
+		}
+		start, ok := partStarts[i]
+		if !ok {
+			start = ix.duration
+			if i < len(ix.segments) {
+				start = ix.starts[i]
+			}
+		}
+		ix.parts = append(ix.parts, indexedPart{part: ps, segment: i, start: start})
+		partStarts[i] = start + ps.Duration
+	}
+	if end, ok := partStarts[len(ix.segments)]; ok {
+		ix.duration = end
+	}
+	return ix
+}
+
+// Duration returns the total duration of the indexed segments and partial segments.
+func (ix *TimeIndex) Duration() float64 {
+	return ix.duration
+}
+
+// Len returns the number of indexed full segments.
+func (ix *TimeIndex) Len() int {
+	return len(ix.segments)
+}
+
+// HasProgramDateTime tells whether the playlist has EXT-X-PROGRAM-DATE-TIME, so that
+// LookupDateTime can be used.
+func (ix *TimeIndex) HasProgramDateTime() bool {
+	return ix.pdts != nil
+}
+
+// Lookup returns the position of the media time t, which must be in [0, Duration()).
+func (ix *TimeIndex) Lookup(t float64) (TimePosition, bool) {
+	if t < 0 || t >= ix.duration {
+		return TimePosition{}, false
+	}
+	// The last segment starting at or before t
+	i := sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > t }) - 1
+	var pos TimePosition
+	if i >= 0 && t < ix.starts[i]+ix.segments[i].Duration {
+		pos = TimePosition{Segment: ix.segments[i], Index: i, Start: ix.starts[i], Offset: t - ix.starts[i]}
+		if ix.pdts != nil {
+			pos.ProgramDateTime = ix.pdts[i]
+		}
+	} else {
+		// Covered by the partial segments of the next, not yet complete, segment
+		pos = TimePosition{Index: len(ix.segments), Start: ix.endOfSegments(), Offset: t - ix.endOfSegments()}
+		if ix.pdts != nil {
+			last := len(ix.segments) - 1
+			pos.ProgramDateTime = ix.pdts[last].Add(durationOf(ix.segments[last].Duration))
+		}
+	}
+	// The last partial segment starting at or before t
+	j := sort.Search(len(ix.parts), func(j int) bool { return ix.parts[j].start > t }) - 1
+	if j >= 0 && ix.parts[j].segment == pos.Index && t < ix.parts[j].start+ix.parts[j].part.Duration {
+		pos.Part = ix.parts[j].part
+		pos.PartOffset = t - ix.parts[j].start
+	}
+	return pos, true
+}
+
+// LookupDateTime returns the position of the wall-clock time t as given by
+// EXT-X-PROGRAM-DATE-TIME. It returns false if t is outside the playlist, or if the
+// playlist has no program date time.
+func (ix *TimeIndex) LookupDateTime(t time.Time) (TimePosition, bool) {
+	if ix.pdts == nil || t.Before(ix.pdts[0]) {
+		return TimePosition{}, false
+	}
+	// The last segment starting at or before t
+	i := sort.Search(len(ix.pdts), func(i int) bool { return ix.pdts[i].After(t) }) - 1
+	offset := t.Sub(ix.pdts[i]).Seconds()
+	if offset >= ix.segments[i].Duration && i == len(ix.segments)-1 {
+		// Possibly in the partial segments after the last segment
+		return ix.Lookup(ix.endOfSegments() + offset - ix.segments[i].Duration)
+	}
+	if offset >= ix.segments[i].Duration {
+		// In a gap before a jump in program date time, use the next segment
+		i++
+		offset = 0
+	}
+	return ix.Lookup(ix.starts[i] + offset)
+}
+
+// endOfSegments returns the media time at the end of the last full segment.
+func (ix *TimeIndex) endOfSegments() float64 {
+	if len(ix.segments) == 0 {
+		return 0
+	}
+	last := len(ix.segments) - 1
+	return ix.starts[last] + ix.segments[last].Duration
+}
@@ -0,0 +1,105 @@
+package m3u8
+
+import (
+	"bufio"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestTimeIndexLowLatency(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-low-latency.m3u8")
+	is.NoErr(err) // must open file
+	p, _, err := DecodeFrom(bufio.NewReader(f), true)
+	is.NoErr(err) // must decode playlist
+	ix := p.(*MediaPlaylist).TimeIndex()
+
+	is.Equal(ix.Len(), 8)
+	is.Equal(ix.Duration(), 34.0) // 8 segments of 4s and 2 trailing parts of 1s
+	is.True(ix.HasProgramDateTime())
+
+	pos, ok := ix.Lookup(0)
+	is.True(ok)
+	is.Equal(pos.Segment.URI, "fileSequence243.m4s")
+	is.Equal(pos.ProgramDateTime, time.Date(2025, 2, 10, 14, 42, 58, 134e6, time.UTC)) // extrapolated backwards
+	is.True(pos.Part == nil)                                                           // first segment has no parts
+
+	pos, ok = ix.Lookup(29.5)
+	is.True(ok)
+	is.Equal(pos.Segment.URI, "fileSequence250.m4s")
+	is.Equal(pos.Index, 7)
+	is.Equal(pos.Start, 28.0)
+	is.Equal(pos.Offset, 1.5)
+	is.Equal(pos.Part.URI, "filePart250.2.m4s")
+	is.Equal(pos.PartOffset, 0.5)
+
+	pos, ok = ix.Lookup(33)
+	is.True(ok)
+	is.True(pos.Segment == nil) // only covered by partial segments
+	is.Equal(pos.Index, 8)
+	is.Equal(pos.Part.URI, "filePart251.2.m4s")
+	is.Equal(pos.ProgramDateTime, time.Date(2025, 2, 10, 14, 43, 30, 134e6, time.UTC))
+
+	_, ok = ix.Lookup(34)
+	is.True(!ok) // end of the playlist
+	_, ok = ix.Lookup(-1)
+	is.True(!ok) // before the playlist
+
+	pos, ok = ix.LookupDateTime(time.Date(2025, 2, 10, 14, 43, 11, 134e6, time.UTC))
+	is.True(ok)
+	is.Equal(pos.Segment.URI, "fileSequence246.m4s")
+	is.Equal(pos.Offset, 1.0)
+
+	pos, ok = ix.LookupDateTime(time.Date(2025, 2, 10, 14, 43, 31, 134e6, time.UTC))
+	is.True(ok)
+	is.Equal(pos.Part.URI, "filePart251.2.m4s")
+
+	_, ok = ix.LookupDateTime(time.Date(2025, 2, 10, 14, 0, 0, 0, time.UTC))
+	is.True(!ok) // before the playlist
+}
+
+func TestTimeIndexSlidingWindow(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(3, 5)
+	is.NoErr(err)
+	for i := 0; i < 7; i++ {
+		is.NoErr(p.Append("seg"+string(rune('0'+i))+".ts", float64(i+1), ""))
+		if p.Count() > 3 {
+			is.NoErr(p.Remove())
+		}
+	}
+	ix := p.TimeIndex()
+	is.Equal(ix.Len(), 3)
+	is.Equal(ix.Duration(), 18.0) // segments 4, 5 and 6
+	is.True(!ix.HasProgramDateTime())
+
+	pos, ok := ix.Lookup(10)
+	is.True(ok)
+	is.Equal(pos.Segment.URI, "seg5.ts")
+	is.Equal(pos.Offset, 5.0)
+	is.True(pos.ProgramDateTime.IsZero())
+
+	_, ok = ix.LookupDateTime(time.Now())
+	is.True(!ok) // no program date time
+}
+
+func TestTimeIndexProgramDateTimeJump(t *testing.T) {
+	is := is.New(t)
+	p := newClipTestPlaylist(t)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	p.Segments[3].ProgramDateTime = start.Add(time.Hour)
+	ix := p.TimeIndex()
+
+	pos, ok := ix.LookupDateTime(start.Add(30 * time.Minute))
+	is.True(ok)
+	is.Equal(pos.Segment.URI, "seg3.m4s") // gap before the jump resolves to the next segment
+	is.Equal(pos.Offset, 0.0)
+
+	pos, ok = ix.LookupDateTime(start.Add(time.Hour + 5*time.Second))
+	is.True(ok)
+	is.Equal(pos.Segment.URI, "seg4.m4s") // interpolated after the jump
+	is.Equal(pos.Offset, 1.0)
+}
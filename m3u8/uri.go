@@ -0,0 +1,289 @@
+package m3u8
+
+/*
+ This file defines functions for rewriting, resolving and relativizing the URIs of a playlist.
+*/
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URIKind tells which tag or attribute a URI passed to a URIRewriteFunc comes from.
+type URIKind uint
+
+const (
+	URISegment               URIKind = iota + 1 // URI line of a media segment
+	URIPartialSegment                           // EXT-X-PART:URI
+	URIMap                                      // EXT-X-MAP:URI
+	URIKey                                      // EXT-X-KEY:URI
+	URIPreloadHint                              // EXT-X-PRELOAD-HINT:URI
+	URIVariant                                  // URI line of an EXT-X-STREAM-INF
+	URIIFrameVariant                            // EXT-X-I-FRAME-STREAM-INF:URI
+	URIAlternative                              // EXT-X-MEDIA:URI
+	URISessionData                              // EXT-X-SESSION-DATA:URI
+	URISessionKey                               // EXT-X-SESSION-KEY:URI
+	URIContentSteering                          // EXT-X-CONTENT-STEERING:SERVER-URI
+	URIInterstitialAsset                        // EXT-X-DATERANGE:X-ASSET-URI
+	URIInterstitialAssetList                    // EXT-X-DATERANGE:X-ASSET-LIST
+)
+
+func (k URIKind) String() string {
+	switch k {
+	case URISegment:
+		return "Segment"
+	case URIPartialSegment:
+		return "PartialSegment"
+	case URIMap:
+		return "Map"
+	case URIKey:
+		return "Key"
+	case URIPreloadHint:
+		return "PreloadHint"
+	case URIVariant:
+		return "Variant"
+	case URIIFrameVariant:
+		return "IFrameVariant"
+	case URIAlternative:
+		return "Alternative"
+	case URISessionData:
+		return "SessionData"
+	case URISessionKey:
+		return "SessionKey"
+	case URIContentSteering:
+		return "ContentSteering"
+	case URIInterstitialAsset:
+		return "InterstitialAsset"
+	case URIInterstitialAssetList:
+		return "InterstitialAssetList"
+	}
+	return "Unknown"
+}
+
+// URIRewriteFunc returns the URI to use instead of uri, which is of the given kind.
+// Return uri unchanged to keep it.
+type URIRewriteFunc func(kind URIKind, uri string) string
+
+// RewriteURIs replaces every URI in the media playlist by the result of fn.
+// This covers segments, partial segments, EXT-X-MAP, EXT-X-KEY (both the default keys and
+// the segment keys), EXT-X-PRELOAD-HINT and the interstitial X-ASSET-URI and X-ASSET-LIST
+// attributes of EXT-X-DATERANGE tags. fn is not called for empty URIs.
+//
+// Maps and keys shared between the playlist and its segments are rewritten once, and stay shared.
+// Args is still appended to segment URIs when encoding.
+// This operation resets the playlist cache.
+func (p *MediaPlaylist) RewriteURIs(fn URIRewriteFunc) {
+	maps := make(map[*Map]*Map)
+	p.Map = rewriteMap(fn, p.Map, maps)
+	defaultKeys := p.Keys
+	p.Keys = rewriteKeys(fn, p.Keys)
+	for _, seg := range p.GetAllSegments() {
+		if seg == nil {
+			continue
+		}
+		seg.URI = rewriteURI(fn, URISegment, seg.URI)
+		seg.Map = rewriteMap(fn, seg.Map, maps)
+		if len(seg.Keys) > 0 && len(seg.Keys) == len(defaultKeys) && &seg.Keys[0] == &defaultKeys[0] {
+			seg.Keys = p.Keys // keep the shared default keys shared
+		} else {
+			seg.Keys = rewriteKeys(fn, seg.Keys)
+		}
+		rewriteDateRanges(fn, seg.SCTE35DateRanges)
+	}
+	for _, ps := range p.PartialSegments {
+		ps.URI = rewriteURI(fn, URIPartialSegment, ps.URI)
+	}
+	if p.PreloadHints != nil {
+		p.PreloadHints.URI = rewriteURI(fn, URIPreloadHint, p.PreloadHints.URI)
+	}
+	if p.PreloadMapHint != nil {
+		p.PreloadMapHint.URI = rewriteURI(fn, URIPreloadHint, p.PreloadMapHint.URI)
+	}
+	rewriteDateRanges(fn, p.DateRanges)
+	rewriteDateRanges(fn, p.TrailingDateRanges)
+	p.buf.Reset()
+}
+
+// RewriteURIs replaces every URI in the master playlist by the result of fn.
+// This covers variants, I-frame variants, EXT-X-MEDIA renditions, EXT-X-SESSION-DATA,
+// EXT-X-SESSION-KEY and EXT-X-CONTENT-STEERING. The media playlists in Variant.Chunklist
+// are not changed. fn is not called for empty URIs.
+//
+// Alternatives shared between variants and rendition groups are rewritten once.
+// Args is still appended to variant URIs when encoding.
+// This operation resets the playlist cache.
+func (p *MasterPlaylist) RewriteURIs(fn URIRewriteFunc) {
+	for _, v := range p.Variants {
+		if v.Iframe {
+			v.URI = rewriteURI(fn, URIIFrameVariant, v.URI)
+		} else {
+			v.URI = rewriteURI(fn, URIVariant, v.URI)
+		}
+	}
+	for _, alt := range p.allRenditions() {
+		alt.URI = rewriteURI(fn, URIAlternative, alt.URI)
+	}
+	for _, sd := range p.SessionDatas {
+		sd.URI = rewriteURI(fn, URISessionData, sd.URI)
+	}
+	for _, key := range p.SessionKeys {
+		key.URI = rewriteURI(fn, URISessionKey, key.URI)
+	}
+	if p.ContentSteering != nil {
+		p.ContentSteering.ServerURI = rewriteURI(fn, URIContentSteering, p.ContentSteering.ServerURI)
+	}
+	p.buf.Reset()
+}
+
+func rewriteURI(fn URIRewriteFunc, kind URIKind, uri string) string {
+	if uri == "" {
+		return uri
+	}
+	return fn(kind, uri)
+}
+
+// rewriteMap returns a rewritten copy of m. Copies are cached in done, so that a map
+// shared between the playlist and its segments is rewritten once and stays shared.
+func rewriteMap(fn URIRewriteFunc, m *Map, done map[*Map]*Map) *Map {
+	if m == nil {
+		return nil
+	}
+	if n, ok := done[m]; ok {
+		return n
+	}
+	n := *m
+	n.URI = rewriteURI(fn, URIMap, m.URI)
+	done[m] = &n
+	return &n
+}
+
+// rewriteKeys returns a rewritten copy of keys, since the decoder lets the
+// playlist and its first segment share the same key slice.
+func rewriteKeys(fn URIRewriteFunc, keys []Key) []Key {
+	if len(keys) == 0 {
+		return keys
+	}
+	out := make([]Key, len(keys))
+	for i, key := range keys {
+		key.URI = rewriteURI(fn, URIKey, key.URI)
+		out[i] = key
+	}
+	return out
+}
+
+// rewriteDateRanges rewrites the interstitial asset URIs of the date ranges.
+func rewriteDateRanges(fn URIRewriteFunc, drs []*DateRange) {
+	for _, dr := range drs {
+		for i, xa := range dr.XAttrs {
+			var kind URIKind
+			switch xa.Key {
+			case "X-ASSET-URI":
+				kind = URIInterstitialAsset
+			case "X-ASSET-LIST":
+				kind = URIInterstitialAssetList
+			default:
+				continue
+			}
+			dr.XAttrs[i].Val = `"` + rewriteURI(fn, kind, deQuote(xa.Val)) + `"`
+		}
+	}
+}
+
+// ResolveURI resolves uri against the base URL, as a client would.
+// Absolute URIs, including data: and skd: URIs, are returned unchanged.
+func ResolveURI(base, uri string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", base, err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid URI %q: %w", uri, err)
+	}
+	return b.ResolveReference(u).String(), nil
+}
+
+// RelativizeURI returns uri as a reference relative to the base URL, so that
+// resolving it against base gives back uri. If uri has another scheme or host than
+// base, or cannot be parsed, it is returned unchanged.
+func RelativizeURI(base, uri string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", base, err)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri, nil
+	}
+	return relativize(b, u), nil
+}
+
+func relativize(base, u *url.URL) string {
+	abs := base.ResolveReference(u)
+	if abs.Scheme != base.Scheme || abs.User.String() != base.User.String() || abs.Host != base.Host ||
+		abs.Opaque != "" {
+		return u.String()
+	}
+	// Directories of the base path, and the remaining path elements of abs
+	baseDirs := strings.Split(base.EscapedPath(), "/")
+	baseDirs = baseDirs[:len(baseDirs)-1]
+	target := strings.Split(abs.EscapedPath(), "/")
+	common := 0
+	for common < len(baseDirs) && common < len(target)-1 && baseDirs[common] == target[common] {
+		common++
+	}
+	var sb strings.Builder
+	for i := common; i < len(baseDirs); i++ {
+		sb.WriteString("../")
+	}
+	rest := strings.Join(target[common:], "/")
+	if sb.Len() == 0 && (rest == "" || strings.Contains(strings.SplitN(rest, "/", 2)[0], ":")) {
+		// An empty path would mean the base itself, and a colon in the first
+		// element would be taken for a scheme
+		sb.WriteString("./")
+	}
+	sb.WriteString(rest)
+	if abs.RawQuery != "" || abs.ForceQuery {
+		sb.WriteRune('?')
+		sb.WriteString(abs.RawQuery)
+	}
+	if abs.Fragment != "" {
+		sb.WriteRune('#')
+		sb.WriteString(abs.EscapedFragment())
+	}
+	return sb.String()
+}
+
+// NewResolver returns a URIRewriteFunc that resolves all URIs against the base URL,
+// e.g. to make the URIs of a playlist absolute before moving it to another location.
+// URIs that cannot be parsed are kept unchanged.
+func NewResolver(base string) (URIRewriteFunc, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", base, err)
+	}
+	return func(_ URIKind, uri string) string {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return uri
+		}
+		return b.ResolveReference(u).String()
+	}, nil
+}
+
+// NewRelativizer returns a URIRewriteFunc that makes all URIs relative to the
+// base URL where possible, see RelativizeURI.
+func NewRelativizer(base string) (URIRewriteFunc, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", base, err)
+	}
+	return func(_ URIKind, uri string) string {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return uri
+		}
+		return relativize(b, u)
+	}, nil
+}
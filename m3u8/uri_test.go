@@ -0,0 +1,166 @@
+package m3u8
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestMediaPlaylistRewriteURIs(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-low-latency.m3u8")
+	is.NoErr(err) // must open file
+	p, _, err := DecodeFrom(bufio.NewReader(f), true)
+	is.NoErr(err) // must decode playlist
+	pl := p.(*MediaPlaylist)
+	before := pl.String()
+
+	kinds := make(map[URIKind]int)
+	pl.RewriteURIs(func(kind URIKind, uri string) string {
+		kinds[kind]++
+		return "https://cdn.example.com/live/" + uri + "?token=abc"
+	})
+	is.Equal(kinds[URISegment], 8)        // all segments rewritten
+	is.Equal(kinds[URIMap], 1)            // shared map rewritten once
+	is.Equal(kinds[URIPreloadHint], 1)    // preload hint rewritten
+	is.True(kinds[URIPartialSegment] > 0) // partial segments rewritten
+
+	out := pl.String()
+	is.True(out != before) // cache must be reset
+	is.True(strings.Contains(out, `#EXT-X-MAP:URI="https://cdn.example.com/live/fileSequence0.mp4?token=abc"`))
+	is.True(strings.Contains(out, "\nhttps://cdn.example.com/live/fileSequence249.m4s?token=abc\n"))
+	is.True(strings.Contains(out,
+		`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="https://cdn.example.com/live/filePart251.3.m4s?token=abc"`))
+	// Parts must stay with their full segments
+	is.Equal(strings.Count(out, "#EXT-X-PART:"), strings.Count(before, "#EXT-X-PART:"))
+	is.True(strings.Index(out, "filePart250.4.m4s") < strings.Index(out, "\nhttps://cdn.example.com/live/fileSequence250.m4s"))
+}
+
+func TestMediaPlaylistRewriteURIsKeysAndInterstitials(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/media-playlist-with-key.m3u8")
+	is.NoErr(err) // must open file
+	p, _, err := DecodeFrom(bufio.NewReader(f), true)
+	is.NoErr(err) // must decode playlist
+	pl := p.(*MediaPlaylist)
+	nrKeys := strings.Count(pl.String(), "#EXT-X-KEY:")
+	pl.RewriteURIs(func(kind URIKind, uri string) string {
+		if kind == URIKey {
+			return "https://keys.example.com/" + uri
+		}
+		return uri
+	})
+	out := pl.String()
+	is.Equal(strings.Count(out, "#EXT-X-KEY:"), nrKeys) // no extra key tags
+	is.Equal(strings.Count(out, "https://keys.example.com/"), nrKeys)
+
+	f, err = os.Open("sample-playlists/media-playlist-with-interstitial.m3u8")
+	is.NoErr(err) // must open file
+	p, _, err = DecodeFrom(bufio.NewReader(f), true)
+	is.NoErr(err) // must decode playlist
+	pl = p.(*MediaPlaylist)
+	pl.RewriteURIs(func(kind URIKind, uri string) string {
+		if kind == URIInterstitialAsset {
+			return strings.Replace(uri, "example.com", "ads.example.com", 1)
+		}
+		return uri
+	})
+	out = pl.String()
+	is.True(strings.Contains(out, `X-ASSET-URI="http://ads.example.com/ad1.m3u8"`))
+	is.True(strings.Contains(out, `X-URI="http://example.com/ad1.m3u8"`)) // not an asset URI
+}
+
+func TestMasterPlaylistRewriteURIs(t *testing.T) {
+	is := is.New(t)
+	f, err := os.Open("sample-playlists/master-groups-and-iframe.m3u8")
+	is.NoErr(err) // must open file
+	p := NewMasterPlaylist()
+	is.NoErr(p.DecodeFrom(bufio.NewReader(f), true)) // must decode playlist
+	p.SessionDatas = append(p.SessionDatas, &SessionData{DataId: "com.example", URI: "data.json", Format: "JSON"})
+	p.SessionKeys = append(p.SessionKeys, &Key{Method: "SAMPLE-AES", URI: "skd://key"})
+	p.ContentSteering = &ContentSteering{ServerURI: "steering.json"}
+
+	resolve, err := NewResolver("https://example.com/vod/master.m3u8")
+	is.NoErr(err) // must create resolver
+	seen := make(map[string]bool)
+	p.RewriteURIs(func(kind URIKind, uri string) string {
+		is.True(!seen[kind.String()+uri]) // each URI must be rewritten once
+		seen[kind.String()+uri] = true
+		return resolve(kind, uri)
+	})
+	for _, v := range p.Variants {
+		is.True(strings.HasPrefix(v.URI, "https://example.com/vod/"))
+		for _, alt := range v.Alternatives {
+			is.True(alt.URI == "" || strings.HasPrefix(alt.URI, "https://example.com/vod/"))
+		}
+	}
+	is.Equal(p.SessionDatas[0].URI, "https://example.com/vod/data.json")
+	is.Equal(p.SessionKeys[0].URI, "skd://key") // absolute URI is kept
+	is.Equal(p.ContentSteering.ServerURI, "https://example.com/vod/steering.json")
+}
+
+func TestResolveURI(t *testing.T) {
+	cases := []struct {
+		base, uri, want string
+	}{
+		{"https://a.com/x/y/master.m3u8", "seg.ts", "https://a.com/x/y/seg.ts"},
+		{"https://a.com/x/y/master.m3u8", "../z/seg.ts?q=1", "https://a.com/x/z/seg.ts?q=1"},
+		{"https://a.com/x/y/master.m3u8", "/root.ts", "https://a.com/root.ts"},
+		{"https://a.com/x/y/master.m3u8", "https://b.com/seg.ts", "https://b.com/seg.ts"},
+		{"https://a.com/x/y/master.m3u8", "data:text/plain;base64,AAAA", "data:text/plain;base64,AAAA"},
+	}
+	for _, c := range cases {
+		t.Run(c.uri, func(t *testing.T) {
+			is := is.New(t)
+			got, err := ResolveURI(c.base, c.uri)
+			is.NoErr(err)
+			is.Equal(got, c.want)
+		})
+	}
+}
+
+func TestRelativizeURI(t *testing.T) {
+	cases := []struct {
+		base, uri, want string
+	}{
+		{"https://a.com/x/y/master.m3u8", "https://a.com/x/y/seg.ts", "seg.ts"},
+		{"https://a.com/x/y/master.m3u8", "https://a.com/x/z/seg.ts?q=1", "../z/seg.ts?q=1"},
+		{"https://a.com/x/y/master.m3u8", "https://a.com/x/y/v/seg.ts", "v/seg.ts"},
+		{"https://a.com/x/y/master.m3u8", "https://a.com/x/y/", "./"},
+		{"https://a.com/x/y/master.m3u8", "https://b.com/x/y/seg.ts", "https://b.com/x/y/seg.ts"},
+		{"https://a.com/x/y/master.m3u8", "http://a.com/x/y/seg.ts", "http://a.com/x/y/seg.ts"},
+		{"https://a.com/x/y/master.m3u8", "skd://key", "skd://key"},
+		{"https://a.com/x/y/master.m3u8", "seg.ts", "seg.ts"},
+	}
+	for _, c := range cases {
+		t.Run(c.uri, func(t *testing.T) {
+			is := is.New(t)
+			got, err := RelativizeURI(c.base, c.uri)
+			is.NoErr(err)
+			is.Equal(got, c.want)
+			resolved, err := ResolveURI(c.base, got)
+			is.NoErr(err)
+			want, _ := ResolveURI(c.base, c.uri)
+			is.Equal(resolved, want) // relative URI must resolve to the original
+		})
+	}
+}
+
+func TestNewRelativizer(t *testing.T) {
+	is := is.New(t)
+	_, err := NewRelativizer("://bad")
+	is.True(err != nil) // bad base URL must fail
+	rel, err := NewRelativizer("https://a.com/x/index.m3u8")
+	is.NoErr(err)
+	is.Equal(rel(URISegment, "https://a.com/x/1.ts"), "1.ts")
+}
+
+func TestURIKindString(t *testing.T) {
+	is := is.New(t)
+	is.Equal(URISegment.String(), "Segment")
+	is.Equal(URIInterstitialAssetList.String(), "InterstitialAssetList")
+	is.Equal(URIKind(0).String(), "Unknown")
+}
@@ -22,6 +22,12 @@ var ErrPlaylistFull = errors.New("playlist is full")
 var ErrPlaylistEmpty = errors.New("playlist is empty")
 var ErrWinSizeTooSmall = errors.New("window size must be >= capacity")
 var ErrAlreadySkipped = errors.New("can not change the existing skip tag in a playlist")
+var ErrInvalidPreloadHint = errors.New("invalid EXT-X-PRELOAD-HINT")
+var ErrInvalidByteRange = errors.New("invalid byte range")
+var ErrNoPartialSegments = errors.New("no partial segments")
+
+// minGrowCapacity is the capacity a growable playlist with a smaller capacity grows to.
+const minGrowCapacity = 16
 
 var segmentSlices = sync.Pool{}
 var segments = sync.Pool{}
@@ -147,6 +153,9 @@ func (p *MasterPlaylist) Encode() *bytes.Buffer {
 	p.buf.WriteString("#EXTM3U\n#EXT-X-VERSION:")
 	p.buf.WriteString(strVer(p.ver))
 	p.buf.WriteRune('\n')
+	if p.customPlacement == CustomTagsAfterVersion {
+		writeCustomTags(&p.buf, p.CustomTags())
+	}
 	if p.ContentSteering != nil {
 		writeContentSteering(&p.buf, p.ContentSteering)
 	}
@@ -156,7 +165,7 @@ func (p *MasterPlaylist) Encode() *bytes.Buffer {
 	}
 
 	if p.StartTime != 0.0 { // Both negative and positive values are allowed. Negative values are relative to the end.
-		writeExtXStart(&p.buf, p.StartTime, p.StartTimePrecise, p.WritePrecision())
+		writeExtXStart(&p.buf, p.StartTime, p.StartTimePrecise, p.StartUnknownAttrs, p.WritePrecision())
 	}
 
 	if len(p.Defines) > 0 {
@@ -171,21 +180,24 @@ func (p *MasterPlaylist) Encode() *bytes.Buffer {
 	}
 
 	// Write any custom master tags
-	if p.Custom != nil {
-		for _, v := range p.Custom {
-			if customBuf := v.Encode(); customBuf != nil {
-				p.buf.WriteString(customBuf.String())
-				p.buf.WriteRune('\n')
-			}
-		}
+	if p.customPlacement == CustomTagsDefault {
+		writeCustomTags(&p.buf, p.CustomTags())
 	}
 
+	writeUnknownTags(&p.buf, p.UnknownTags)
+
 	alts := p.GetAllAlternatives()
 	for _, alt := range alts {
+		writeUnknownTags(&p.buf, alt.UnknownTags)
 		writeExtXMedia(&p.buf, alt)
 	}
 
+	if p.customPlacement == CustomTagsEndOfHeader {
+		writeCustomTags(&p.buf, p.CustomTags())
+	}
+
 	for _, vnt := range p.Variants {
+		writeUnknownTags(&p.buf, vnt.UnknownTags)
 		if vnt.Iframe {
 			writeExtXIFrameStreamInf(&p.buf, vnt, p.WritePrecision())
 		} else {
@@ -202,6 +214,7 @@ func (p *MasterPlaylist) Encode() *bytes.Buffer {
 			p.buf.WriteRune('\n')
 		}
 	}
+	writeUnknownTags(&p.buf, p.TrailingUnknownTags)
 
 	return &p.buf
 }
@@ -249,6 +262,7 @@ func writeExtXMedia(buf *bytes.Buffer, alt *Alternative) {
 	if alt.URI != "" {
 		writeQuoted(buf, "URI", alt.URI)
 	}
+	writeAttributes(buf, alt.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -315,6 +329,7 @@ func writeExtXStreamInf(buf *bytes.Buffer, vnt *Variant, writePrecision int) {
 	if vnt.Name != "" {
 		writeQuoted(buf, "NAME", vnt.Name)
 	}
+	writeAttributes(buf, vnt.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -366,6 +381,7 @@ func writeExtXIFrameStreamInf(buf *bytes.Buffer, vnt *Variant, writePrecision in
 		writeQuoted(buf, "NAME", vnt.Name)
 	}
 	writeQuoted(buf, "URI", vnt.URI) // Mandatory
+	writeAttributes(buf, vnt.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -391,6 +407,7 @@ func writePartialSegment(buf *bytes.Buffer, ps *PartialSegment, writePrecision i
 	buf.WriteString(",URI=\"")
 	buf.WriteString(ps.URI)
 	buf.WriteRune('"')
+	writeAttributes(buf, ps.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -401,12 +418,15 @@ func writePreloadHint(buf *bytes.Buffer, ph *PreloadHint) {
 	buf.WriteString(",URI=\"")
 	buf.WriteString(ph.URI)
 	buf.WriteRune('"')
-	if ph.Limit > 0 {
+	if ph.Offset > 0 || ph.Limit > 0 {
 		buf.WriteString(",BYTERANGE-START=")
 		buf.WriteString(strconv.FormatInt(ph.Offset, 10))
+	}
+	if ph.Limit > 0 {
 		buf.WriteString(",BYTERANGE-LENGTH=")
 		buf.WriteString(strconv.FormatInt(ph.Limit, 10))
 	}
+	writeAttributes(buf, ph.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -438,6 +458,9 @@ func writeServerControl(buf *bytes.Buffer, sc *ServerControl, writePrecision int
 	if sc.CanBlockReload {
 		stringsToWrite = append(stringsToWrite, ("CAN-BLOCK-RELOAD=YES"))
 	}
+	for _, a := range sc.UnknownAttrs {
+		stringsToWrite = append(stringsToWrite, a.Key+"="+a.Val)
+	}
 
 	joinedString := strings.Join(stringsToWrite, ",")
 	buf.WriteString(joinedString)
@@ -518,21 +541,24 @@ func writeSessionData(buf *bytes.Buffer, sd *SessionData) {
 	if sd.URI != "" {
 		writeQuoted(buf, "URI", sd.URI)
 	}
-	if sd.Format != "JSON" {
+	if sd.Format != "" && sd.Format != "JSON" {
 		writeUnQuoted(buf, "FORMAT", sd.Format)
 	}
 	if sd.Language != "" {
 		writeQuoted(buf, "LANGUAGE", sd.Language)
 	}
+	writeAttributes(buf, sd.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
-func writeExtXStart(buf *bytes.Buffer, startTime float64, precise bool, writePrecision int) {
+func writeExtXStart(buf *bytes.Buffer, startTime float64, precise bool, unknownAttrs []Attribute,
+	writePrecision int) {
 	buf.WriteString("#EXT-X-START:TIME-OFFSET=")
 	writeFloatValue(buf, startTime, writePrecision)
 	if precise {
 		buf.WriteString(",PRECISE=YES")
 	}
+	writeAttributes(buf, unknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -544,6 +570,7 @@ func writeExtXMap(buf *bytes.Buffer, m *Map) {
 	if m.Limit > 0 {
 		writeRange(buf, ",BYTERANGE=", true, m.Limit, m.Offset, true)
 	}
+	writeAttributes(buf, m.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
@@ -563,9 +590,35 @@ func writeKey(tag string, buf *bytes.Buffer, key *Key) {
 			writeQuoted(buf, "KEYFORMATVERSIONS", key.Keyformatversions)
 		}
 	}
+	writeAttributes(buf, key.UnknownAttrs)
 	buf.WriteRune('\n')
 }
 
+// writeAttributes writes raw attributes, such as unknown attributes kept in preserve mode.
+func writeAttributes(buf *bytes.Buffer, attrs []Attribute) {
+	for _, a := range attrs {
+		writeUnQuoted(buf, a.Key, a.Val)
+	}
+}
+
+// writeCustomTags writes custom tags in order, skipping tags that encode to nil.
+func writeCustomTags(buf *bytes.Buffer, tags CustomTags) {
+	for _, tag := range tags {
+		if customBuf := tag.Encode(); customBuf != nil {
+			buf.WriteString(customBuf.String())
+			buf.WriteRune('\n')
+		}
+	}
+}
+
+// writeUnknownTags writes unknown tags kept in preserve mode, one per line.
+func writeUnknownTags(buf *bytes.Buffer, tags []string) {
+	for _, tag := range tags {
+		buf.WriteString(tag)
+		buf.WriteRune('\n')
+	}
+}
+
 func writeContentSteering(buf *bytes.Buffer, cs *ContentSteering) {
 	buf.WriteString(`#EXT-X-CONTENT-STEERING:SERVER-URI="`)
 	buf.WriteString(cs.ServerURI)
@@ -654,13 +707,39 @@ func writeChannels(buf *bytes.Buffer, channels *Channels) {
 	buf.WriteRune('"')
 }
 
-// SetCustomTag sets the provided tag on the master playlist for its TagName
+// SetCustomTag sets the provided tag on the master playlist for its TagName,
+// replacing tags with the same name.
 func (p *MasterPlaylist) SetCustomTag(tag CustomTag) {
 	if p.Custom == nil {
 		p.Custom = make(CustomMap)
 	}
 
 	p.Custom[tag.TagName()] = tag
+	p.customOrder.set(tag)
+	p.buf.Reset()
+}
+
+// AppendCustomTag appends the provided tag to the custom tags of the master playlist,
+// also if there are tags with the same name. Custom then holds the last of them.
+func (p *MasterPlaylist) AppendCustomTag(tag CustomTag) {
+	if p.Custom == nil {
+		p.Custom = make(CustomMap)
+	}
+	p.Custom[tag.TagName()] = tag
+	p.customOrder = append(p.customOrder, tag)
+	p.buf.Reset()
+}
+
+// CustomTags returns the custom tags of the master playlist in the order they are
+// written, see MediaPlaylist.CustomTags.
+func (p *MasterPlaylist) CustomTags() CustomTags {
+	return orderedCustomTags(p.Custom, p.customOrder)
+}
+
+// SetCustomTagPlacement sets the position of the custom tags among the header tags.
+func (p *MasterPlaylist) SetCustomTagPlacement(placement CustomTagPlacement) {
+	p.customPlacement = placement
+	p.buf.Reset()
 }
 
 // IndependentSegments returns true if all media samples in a segment can be
@@ -679,31 +758,47 @@ func (p *MasterPlaylist) String() string {
 	return p.Encode().String()
 }
 
-// GetAllAlternatives returns all alternative renditions sorted by
-// groupID, type, name, and language.
+// GetAllAlternatives returns all alternative renditions. The renditions of
+// RenditionGroups come first in declaration order, followed by the alternatives
+// of variants that are in no group, sorted by groupID, type, name, and language.
 func (p *MasterPlaylist) GetAllAlternatives() []*Alternative {
+	alts := p.groupRenditions()
 	added := make(map[string]*Alternative)
+	inGroup := make(map[*Alternative]bool)
+	for _, alt := range alts {
+		inGroup[alt] = true
+		added[alternativeKey(alt)] = alt
+	}
 
+	others := make(map[string]*Alternative)
 	for _, v := range p.Variants {
 		for _, alt := range v.Alternatives {
-			key := fmt.Sprintf("%s-%s-%s-%s", alt.GroupId, alt.Type, alt.Name, alt.Language)
+			if alt == nil || inGroup[alt] {
+				continue
+			}
+			key := alternativeKey(alt)
 			if _, ok := added[key]; !ok {
 				added[key] = alt
+				others[key] = alt
 			}
 		}
 	}
-	alts := make([]*Alternative, 0, len(added))
-	keys := make([]string, 0, len(added))
-	for k := range added {
+	keys := make([]string, 0, len(others))
+	for k := range others {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 	for _, key := range keys {
-		alts = append(alts, added[key])
+		alts = append(alts, others[key])
 	}
 	return alts
 }
 
+// alternativeKey identifies an alternative rendition when listing them once.
+func alternativeKey(alt *Alternative) string {
+	return fmt.Sprintf("%s-%s-%s-%s", alt.GroupId, alt.Type, alt.Name, alt.Language)
+}
+
 // NewMediaPlaylist creates a new media playlist structure.
 // Winsize defines live window for playlist generation. Set to zero for VOD or EVENT
 // playlists.  Capacity is the total size of the backing segment list..
@@ -751,20 +846,93 @@ func (p *MediaPlaylist) IndependentSegments() bool {
 
 // Remove removes the first/oldest segment which is at the head of chunk slice form a media playlist.
 // Useful for sliding playlists.
+//
+// The state declared by the removed segment is carried forward, so that the playlist stays
+// self-contained: SeqNo is incremented, a departing EXT-X-DISCONTINUITY increments
+// DiscontinuitySeq, and Map and Keys are set to the EXT-X-MAP and EXT-X-KEY in effect for
+// the new first segment: its own if it declares them, else those of the removed segment,
+// which are then attached to it. This is done for closed playlists as well.
+// EXT-X-DATERANGE tags in DateRanges that ended before the new first segment are removed.
 // This operation resets playlist cache.
 func (p *MediaPlaylist) Remove() (err error) {
 	if p.count == 0 {
 		return ErrPlaylistEmpty
 	}
+	removed := p.Segments[p.head]
 	p.head = (p.head + 1) % p.capacity
 	p.count--
-	if !p.Closed {
-		p.SeqNo++
+	p.SeqNo++
+	if p.count == 0 {
+		p.segmentsDuration = 0
+	}
+	if removed != nil {
+		if p.count > 0 {
+			p.segmentsDuration -= removed.Duration
+		}
+		p.carryState(removed)
 	}
 	p.buf.Reset()
 	return nil
 }
 
+// carryState moves the state declared by the removed segment to the playlist and
+// its new first segment.
+func (p *MediaPlaylist) carryState(removed *MediaSegment) {
+	if removed.Discontinuity {
+		p.DiscontinuitySeq++
+	}
+	var first *MediaSegment
+	if p.count > 0 {
+		first = p.Segments[p.head]
+	}
+	switch {
+	case first != nil && first.Map != nil:
+		p.Map = first.Map
+	case removed.Map != nil:
+		p.Map = removed.Map
+		if first != nil {
+			first.Map = removed.Map
+		}
+	}
+	switch {
+	case first != nil && len(first.Keys) > 0:
+		p.Keys = first.Keys
+	case len(removed.Keys) > 0:
+		p.Keys = removed.Keys
+		if first != nil {
+			first.Keys = removed.Keys
+		}
+	}
+	if first != nil && len(p.DateRanges) > 0 {
+		p.expireDateRanges()
+	}
+}
+
+// expireDateRanges removes the date ranges in DateRanges that ended before the program
+// date time of the first segment. Nothing is removed without a program date time.
+func (p *MediaPlaylist) expireDateRanges() {
+	var windowStart time.Time
+	var offset float64 // duration from the first segment to the one with a program date time
+	for i := uint(0); i < p.count; i++ {
+		seg := p.Segments[(p.head+i)%p.capacity]
+		if seg == nil {
+			continue
+		}
+		if !seg.ProgramDateTime.IsZero() {
+			windowStart = seg.ProgramDateTime.Add(-durationOf(offset))
+			break
+		}
+		offset += seg.Duration
+	}
+	if windowStart.IsZero() {
+		return
+	}
+	p.DateRanges = slices.DeleteFunc(p.DateRanges, func(dr *DateRange) bool {
+		end := dateRangeEnd(dr)
+		return end != nil && end.Before(windowStart)
+	})
+}
+
 // Append general chunk to the tail of chunk slice for a media playlist.
 // This operation resets playlist cache.
 func (p *MediaPlaylist) Append(uri string, duration float64, title string) error {
@@ -777,17 +945,49 @@ func (p *MediaPlaylist) Append(uri string, duration float64, title string) error
 
 // AppendSegment appends a MediaSegment to the tail of chunk slice for
 // a media playlist.  This operation resets playlist cache.
+//
+// With a window duration, the segments leaving the window are removed first. The playlist
+// is not changed if an error is returned.
 func (p *MediaPlaylist) AppendSegment(seg *MediaSegment) error {
-	if p.head == p.tail && p.count > 0 {
+	return p.appendSegment(seg, p.growable)
+}
+
+// appendSegment appends a segment, growing the capacity of a full playlist if grow is set.
+func (p *MediaPlaylist) appendSegment(seg *MediaSegment, grow bool) error {
+	var evicted uint
+	if p.windowDuration > 0 && !p.Closed {
+		evicted = p.outsideWindow(seg.Duration)
+	}
+	if p.count-evicted == p.capacity && !grow {
 		return ErrPlaylistFull
 	}
-	seg.SeqId = p.SeqNo
-	if p.count > 0 {
-		seg.SeqId = p.Segments[(p.capacity+p.tail-1)%p.capacity].SeqId + 1
+	seqId := p.nextSeqId()
+	if p.keyRotator != nil {
+		keys, err := p.keyRotator.nextKeys(seg, seqId)
+		if err != nil {
+			return err
+		}
+		seg.Keys = keys
+		for _, key := range seg.Keys {
+			if key.Keyformat != "" || key.Keyformatversions != "" {
+				updateVersion(&p.ver, 5) // [Protocol Version Compatibility]
+			}
+		}
+	}
+	for ; evicted > 0; evicted-- {
+		_ = p.Remove()
+	}
+	if p.count == p.capacity {
+		p.grow()
+	}
+	seg.SeqId = seqId
+	if p.pdtClock != nil {
+		p.pdtClock.stampSegment(seg)
 	}
 	p.Segments[p.tail] = seg
 	p.tail = (p.tail + 1) % p.capacity
 	p.count++
+	p.segmentsDuration += seg.Duration
 	p.SegmentIndexing.NextMSNIndex++
 	p.SegmentIndexing.NextPartIndex = 0
 	if !p.targetDurLocked {
@@ -806,6 +1006,35 @@ func (p *MediaPlaylist) AppendSegment(seg *MediaSegment) error {
 	return nil
 }
 
+// Growable tells whether the capacity of the playlist grows when it is full.
+func (p *MediaPlaylist) Growable() bool {
+	return p.growable
+}
+
+// SetGrowable makes the capacity of the playlist grow when a segment is appended to a
+// full playlist, instead of AppendSegment returning ErrPlaylistFull. This is useful for
+// EVENT and VOD playlists, where the number of segments is not known in advance. The
+// capacity doubles, so appending takes amortized constant time, and the capacity given
+// to NewMediaPlaylist is only the initial one, which may be 0.
+func (p *MediaPlaylist) SetGrowable(growable bool) {
+	p.growable = growable
+}
+
+// grow doubles the capacity of the segment ring buffer. The segments are moved to the
+// start of the new buffer in playlist order, so that the head is at index 0.
+func (p *MediaPlaylist) grow() {
+	capacity := max(2*p.capacity, minGrowCapacity)
+	segments := getSegmentSlice(capacity)
+	for i := uint(0); i < p.count; i++ {
+		segments[i] = p.Segments[(p.head+i)%p.capacity]
+	}
+	putSegmentSlice(&p.Segments)
+	p.Segments = segments
+	p.capacity = capacity
+	p.head = 0
+	p.tail = p.count
+}
+
 // AppendPartial creates and and appends a partial segment to the media playlist.
 func (p *MediaPlaylist) AppendPartial(uri string, duration float64, independent bool) error {
 	seg := new(PartialSegment)
@@ -826,24 +1055,109 @@ func (p *MediaPlaylist) AppendPartialSegment(ps *PartialSegment) error {
 	}
 
 	// Check if the partial segment belongs to the last full segment
-	fullSegUri := p.Segments[p.last()].URI
-	if isPartOf(ps.URI, fullSegUri) {
+	if isPartOf(ps.URI, p.Segments[p.last()].URI) {
 		ps.SeqID = p.Segments[p.last()].SeqId
 	} else {
 		// It belongs to the next segment
-		ps.SeqID = p.Segments[p.last()].SeqId + 1
+		ps.SeqID = p.nextSeqId()
+	}
+	if p.pdtClock != nil {
+		p.pdtClock.stampPartialSegment(p, ps)
 	}
 
 	p.PartialSegments = append(p.PartialSegments, ps)
+	// The hinted part is no longer a hint, once it is available
+	if ph := p.GetPreloadHint(PreloadHintPart); ph != nil && ph.URI == ps.URI && ph.Offset == ps.Offset {
+		p.RemovePreloadHint(PreloadHintPart)
+	}
 	if p.SegmentIndexing.MaxPartIndex < p.SegmentIndexing.NextPartIndex {
 		p.SegmentIndexing.MaxPartIndex = p.SegmentIndexing.NextPartIndex
 	}
 	p.SegmentIndexing.NextPartIndex++
 	p.removeExpiredPartials()
+	p.buf.Reset()
 
 	return nil
 }
 
+// nextSeqId returns the sequence number of the next segment to be appended.
+func (p *MediaPlaylist) nextSeqId() uint64 {
+	if p.count == 0 {
+		return p.SeqNo
+	}
+	return p.Segments[p.last()].SeqId + 1
+}
+
+// AppendPartialByteRange appends a partial segment for single-file low-latency playlists,
+// where the next full segment is one file under uri and its partial segments are byte
+// ranges of it. The partial segment is the length bytes following the previous partial
+// segment of uri, or starting at 0 for the first one. The PART preload hint is set to the
+// open-ended range following it, i.e. the next partial segment. Call
+// AppendByteRangeSegment once the file is complete. Like AppendPartialSegment, it
+// returns ErrPlaylistEmpty if the playlist has no full segment yet.
+func (p *MediaPlaylist) AppendPartialByteRange(uri string, duration float64, length int64, independent bool) error {
+	if length <= 0 {
+		return fmt.Errorf("partial segment length %d: %w", length, ErrInvalidByteRange)
+	}
+	var offset int64
+	if parts := p.nextSegmentParts(uri); len(parts) > 0 {
+		last := parts[len(parts)-1]
+		offset = last.Offset + last.Limit
+	}
+	ps := &PartialSegment{
+		URI:         uri,
+		Duration:    duration,
+		Independent: independent,
+		Offset:      offset,
+		Limit:       length,
+	}
+	if err := p.AppendPartialSegment(ps); err != nil {
+		return err
+	}
+	updateVersion(&p.ver, 4) // EXT-X-BYTERANGE
+	return p.SetPreloadHintByteRange(PreloadHintPart, uri, offset+length, 0)
+}
+
+// AppendByteRangeSegment appends the full segment under uri, whose partial segments were
+// appended with AppendPartialByteRange. Its byte range covers all of them, its duration is
+// the sum of theirs, and it gets the program date time of the first one. A PART preload
+// hint for uri is removed. ErrNoPartialSegments is returned if there are none for uri.
+func (p *MediaPlaylist) AppendByteRangeSegment(uri, title string) error {
+	parts := p.nextSegmentParts(uri)
+	if len(parts) == 0 {
+		return fmt.Errorf("segment %q: %w", uri, ErrNoPartialSegments)
+	}
+	first, last := parts[0], parts[len(parts)-1]
+	seg := GetSegment()
+	seg.URI = uri
+	seg.Title = title
+	seg.Offset = first.Offset
+	seg.Limit = last.Offset + last.Limit - first.Offset
+	seg.ProgramDateTime = first.ProgramDateTime
+	for _, ps := range parts {
+		seg.Duration += ps.Duration
+	}
+	if err := p.AppendSegment(seg); err != nil {
+		return err
+	}
+	if ph := p.GetPreloadHint(PreloadHintPart); ph != nil && ph.URI == uri {
+		p.RemovePreloadHint(PreloadHintPart)
+	}
+	return nil
+}
+
+// nextSegmentParts returns the partial segments under uri of the next full segment.
+func (p *MediaPlaylist) nextSegmentParts(uri string) []*PartialSegment {
+	next := p.nextSeqId()
+	var parts []*PartialSegment
+	for _, ps := range p.PartialSegments {
+		if ps.SeqID == next && ps.URI == uri {
+			parts = append(parts, ps)
+		}
+	}
+	return parts
+}
+
 // minRetainedPartSeqID returns the lowest segment sequence number for which partial
 // segments are kept, see partRetentionSegments. Partial segments of the next, not yet
 // appended, segment have a higher sequence number and are always kept.
@@ -870,11 +1184,55 @@ func (p *MediaPlaylist) removeExpiredPartials() {
 	})
 }
 
+// SetPreloadHint sets the EXT-X-PRELOAD-HINT of type hintType, replacing an earlier
+// hint of the same type. A MAP hint is set as PreloadMapHint, other types as PreloadHints.
 func (p *MediaPlaylist) SetPreloadHint(hintType, uri string) {
-	preloadHint := new(PreloadHint)
-	preloadHint.Type = hintType
-	preloadHint.URI = uri
-	p.PreloadHints = preloadHint
+	p.setPreloadHint(&PreloadHint{Type: hintType, URI: uri})
+}
+
+// SetPreloadHintByteRange sets the EXT-X-PRELOAD-HINT of type hintType, PreloadHintPart or
+// PreloadHintMap, for the byte range of uri starting at start with the given length, like
+// SetPreloadHint. A length of 0 leaves the range open-ended, i.e. to the end of the resource.
+func (p *MediaPlaylist) SetPreloadHintByteRange(hintType, uri string, start, length int64) error {
+	if hintType != PreloadHintPart && hintType != PreloadHintMap {
+		return fmt.Errorf("type %q: %w", hintType, ErrInvalidPreloadHint)
+	}
+	if start < 0 || length < 0 {
+		return fmt.Errorf("byte range %d@%d: %w", length, start, ErrInvalidPreloadHint)
+	}
+	p.setPreloadHint(&PreloadHint{Type: hintType, URI: uri, Offset: start, Limit: length})
+	return nil
+}
+
+// setPreloadHint sets ph as PreloadMapHint or PreloadHints, depending on its type.
+func (p *MediaPlaylist) setPreloadHint(ph *PreloadHint) {
+	if ph.Type == PreloadHintMap {
+		p.PreloadMapHint = ph
+	} else {
+		p.PreloadHints = ph
+	}
+	p.buf.Reset()
+}
+
+// GetPreloadHint returns the EXT-X-PRELOAD-HINT of type hintType, or nil if there is none.
+func (p *MediaPlaylist) GetPreloadHint(hintType string) *PreloadHint {
+	if hintType == PreloadHintMap {
+		return p.PreloadMapHint
+	}
+	if p.PreloadHints != nil && p.PreloadHints.Type == hintType {
+		return p.PreloadHints
+	}
+	return nil
+}
+
+// RemovePreloadHint removes the EXT-X-PRELOAD-HINT of type hintType.
+func (p *MediaPlaylist) RemovePreloadHint(hintType string) {
+	if hintType == PreloadHintMap {
+		p.PreloadMapHint = nil
+	} else if p.PreloadHints != nil && p.PreloadHints.Type == hintType {
+		p.PreloadHints = nil
+	}
+	p.buf.Reset()
 }
 
 func (p *MediaPlaylist) AppendDefine(d Define) {
@@ -885,11 +1243,21 @@ func (p *MediaPlaylist) AppendDefine(d Define) {
 // the head of chunk slice and move pointer to next chunk. Secondly it
 // appends one chunk to the tail of chunk slice. Useful for sliding
 // playlists.  This operation resets the cache.
+//
+// With a window duration, the segments leaving the window are removed instead, and the
+// capacity grows if the window needs more segments than it holds, so that no segment
+// is dropped. Use AppendSegment to get the errors of appending.
 func (p *MediaPlaylist) Slide(uri string, duration float64, title string) {
-	if !p.Closed && p.count >= p.winsize {
+	// with a window duration, Append removes the segments that leave the window
+	if !p.Closed && p.windowDuration == 0 && p.count >= p.winsize {
 		_ = p.Remove()
 	}
-	_ = p.Append(uri, duration, title)
+	seg := GetSegment()
+	seg.URI = uri
+	seg.Duration = duration
+	seg.Title = title
+	// with a window duration, the capacity grows if the window needs more segments
+	_ = p.appendSegment(seg, p.growable || p.windowDuration > 0)
 }
 
 // ResetCache resets playlist cache (internal buffer).
@@ -900,7 +1268,8 @@ func (p *MediaPlaylist) ResetCache() {
 
 // outputWindow returns the segments to include in the encoded playlist as the
 // ring-buffer index of the first segment and the number of segments to output.
-// For live playlists (winsize > 0) that is the last winsize segments, for
+// For live playlists (winsize > 0) that is the last winsize segments, for live
+// playlists with a window duration the latest segments covering it, and for
 // VoD/EVENT playlists (winsize == 0) all segments. Since the segment slice is a
 // ring buffer, the window may wrap, so indices must be taken modulo capacity
 // when walking it.
@@ -908,6 +1277,18 @@ func (p *MediaPlaylist) outputWindow() (start, outputCount uint) {
 	if p.count == 0 {
 		return p.head, 0
 	}
+	if p.windowDuration > 0 {
+		// the latest segments that together cover the window duration
+		window := p.minWindowDuration()
+		var total float64
+		for outputCount < p.count && total < window {
+			if seg := p.Segments[(p.head+p.count-outputCount-1)%p.capacity]; seg != nil {
+				total += seg.Duration
+			}
+			outputCount++
+		}
+		return (p.head + p.count - outputCount) % p.capacity, outputCount
+	}
 	if p.winsize == 0 { // VoD or EVENT playlist: output all segments
 		return p.head, p.count
 	}
@@ -915,6 +1296,58 @@ func (p *MediaPlaylist) outputWindow() (start, outputCount uint) {
 	return (p.head + p.count - outputCount) % p.capacity, outputCount
 }
 
+// windowState returns the EXT-X-MAP, the EXT-X-KEY tags and the discontinuity sequence
+// number in effect for the segment at ring-buffer index start. They differ from Map, Keys
+// and DiscontinuitySeq if segments before start, but still in the playlist, declare them.
+func (p *MediaPlaylist) windowState(start uint) (m *Map, keys []Key, discontinuitySeq uint64) {
+	m, keys, discontinuitySeq = p.Map, p.Keys, p.DiscontinuitySeq
+	if p.count == 0 {
+		return m, keys, discontinuitySeq
+	}
+	for i := p.head; i != start; i = (i + 1) % p.capacity {
+		seg := p.Segments[i]
+		if seg == nil {
+			continue
+		}
+		if seg.Discontinuity {
+			discontinuitySeq++
+		}
+		if seg.Map != nil {
+			m = seg.Map
+		}
+		if len(seg.Keys) > 0 {
+			keys = seg.Keys
+		}
+	}
+	return m, keys, discontinuitySeq
+}
+
+// minWindowDuration returns the duration a sliding playlist with a window duration must
+// cover, which is at least three target durations (rfc8216bis Section 6.2.2).
+func (p *MediaPlaylist) minWindowDuration() float64 {
+	return max(p.windowDuration, 3*float64(p.TargetDuration))
+}
+
+// outsideWindow returns the number of oldest segments to remove, so that the remaining
+// ones, together with a segment of the given duration about to be appended, still cover
+// the window duration. It walks only the segments to remove, using segmentsDuration.
+func (p *MediaPlaylist) outsideWindow(appendDuration float64) uint {
+	window := p.minWindowDuration()
+	total := p.segmentsDuration + appendDuration
+	var n uint
+	for ; n < p.count; n++ {
+		var headDuration float64
+		if seg := p.Segments[(p.head+n)%p.capacity]; seg != nil {
+			headDuration = seg.Duration
+		}
+		if total-headDuration < window {
+			break
+		}
+		total -= headDuration
+	}
+	return n
+}
+
 // mediaSequence returns the value for the EXT-X-MEDIA-SEQUENCE tag, which is the
 // sequence number of the first segment appearing in the encoded playlist. Segments
 // replaced by an EXT-X-SKIP tag still count, so this is the start of the output
@@ -939,24 +1372,27 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 		return &p.buf
 	}
 
+	// start index and number of segments to output, and the state in effect for the
+	// first of them, which may have been declared by segments before the output window
+	start, outputCount := p.outputWindow()
+	windowMap, windowKeys, discontinuitySeq := p.windowState(start)
 	var lastMap *Map
+	lastKeys := windowKeys
 
 	p.buf.WriteString("#EXTM3U\n#EXT-X-VERSION:")
 	p.buf.WriteString(strVer(p.ver))
 	p.buf.WriteRune('\n')
+	if p.customPlacement == CustomTagsAfterVersion {
+		writeCustomTags(&p.buf, p.CustomTags())
+	}
 
 	if p.IndependentSegments() {
 		p.buf.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
 	}
 
-	// Write any custom master tags
-	if p.Custom != nil {
-		for _, v := range p.Custom {
-			if customBuf := v.Encode(); customBuf != nil {
-				p.buf.WriteString(customBuf.String())
-				p.buf.WriteRune('\n')
-			}
-		}
+	// Write any custom header tags
+	if p.customPlacement == CustomTagsDefault {
+		writeCustomTags(&p.buf, p.CustomTags())
 	}
 
 	if p.AllowCache != nil {
@@ -970,8 +1406,8 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 	}
 
 	// default key before any segment
-	if len(p.Keys) != 0 {
-		for _, key := range p.Keys {
+	if len(windowKeys) != 0 {
+		for _, key := range windowKeys {
 			writeKey("#EXT-X-KEY:", &p.buf, &key)
 		}
 	}
@@ -995,9 +1431,6 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 		p.buf.WriteString(strconv.FormatFloat(p.PartTargetDuration, 'f', p.WritePrecision(), 64))
 		p.buf.WriteRune('\n')
 	}
-	// start index and number of segments to output. Needed already here, since
-	// EXT-X-MEDIA-SEQUENCE refers to the first segment of the output window.
-	start, outputCount := p.outputWindow()
 	p.buf.WriteString("#EXT-X-MEDIA-SEQUENCE:")
 	p.buf.WriteString(strconv.FormatUint(p.mediaSequence(start, outputCount), 10))
 	p.buf.WriteRune('\n')
@@ -1005,11 +1438,11 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 	p.buf.WriteString(strconv.FormatInt(int64(p.TargetDuration), 10))
 	p.buf.WriteRune('\n')
 	if p.StartTime != 0.0 { // Both negative and positive values are allowed. Negative values are relative to the end.
-		writeExtXStart(&p.buf, p.StartTime, p.StartTimePrecise, p.WritePrecision())
+		writeExtXStart(&p.buf, p.StartTime, p.StartTimePrecise, p.StartUnknownAttrs, p.WritePrecision())
 	}
-	if p.DiscontinuitySeq != 0 {
+	if discontinuitySeq != 0 {
 		p.buf.WriteString("#EXT-X-DISCONTINUITY-SEQUENCE:")
-		p.buf.WriteString(strconv.FormatUint(uint64(p.DiscontinuitySeq), 10))
+		p.buf.WriteString(strconv.FormatUint(discontinuitySeq, 10))
 		p.buf.WriteRune('\n')
 	}
 	if p.Iframe {
@@ -1021,11 +1454,16 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 	} else {
 		// Ignore the Media Initialization Section (EXT-X-MAP) tag
 		// in presence of skip (EXT-X-SKIP) tag
-		if p.Map != nil {
-			writeExtXMap(&p.buf, p.Map)
+		if windowMap != nil {
+			writeExtXMap(&p.buf, windowMap)
 		}
-		lastMap = p.Map
+		lastMap = windowMap
+	}
+
+	if p.customPlacement == CustomTagsEndOfHeader {
+		writeCustomTags(&p.buf, p.CustomTags())
 	}
+	writeUnknownTags(&p.buf, p.UnknownTags)
 
 	var (
 		seg           *MediaSegment
@@ -1041,7 +1479,7 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 		partWritten = make([]bool, len(p.PartialSegments))
 	}
 	var lastSegId uint64 = 0 // last segment sequence number in live playlist
-	if p.winsize > 0 && p.count > 0 {
+	if (p.winsize > 0 || p.windowDuration > 0) && p.count > 0 {
 		// p.last() handles a tail that has wrapped around to the start of the slice
 		if last := p.Segments[p.last()]; last != nil {
 			lastSegId = last.SeqId
@@ -1111,11 +1549,13 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 			writeDateRange(&p.buf, seg.SCTE35DateRanges[i], p.WritePrecision())
 		}
 
-		// check for key change
-		if len(seg.Keys) != 0 && (p.Keys == nil || !slices.Equal(seg.Keys, p.Keys)) {
+		// check for key change. Keys equal to the default keys must still be written
+		// if other keys are in effect, e.g. when returning to the default keys.
+		if len(seg.Keys) != 0 && (!keysEqual(seg.Keys, windowKeys) || !keysEqual(seg.Keys, lastKeys)) {
 			for _, key := range seg.Keys {
 				writeKey("#EXT-X-KEY:", &p.buf, &key)
 			}
+			lastKeys = seg.Keys
 		}
 		if seg.Gap {
 			p.buf.WriteString("#EXT-X-GAP\n")
@@ -1154,14 +1594,8 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 		}
 
 		// Add Custom Segment Tags here
-		if seg.Custom != nil {
-			for _, v := range seg.Custom {
-				if customBuf := v.Encode(); customBuf != nil {
-					p.buf.WriteString(customBuf.String())
-					p.buf.WriteRune('\n')
-				}
-			}
-		}
+		writeCustomTags(&p.buf, seg.CustomTags())
+		writeUnknownTags(&p.buf, seg.UnknownTags)
 
 		writeExtInfWithCache(&p.buf, seg.Duration, seg.Title, p.WritePrecision(), durationCache)
 
@@ -1187,10 +1621,14 @@ func (p *MediaPlaylist) encode(segmentsToSkipInTotal uint64) *bytes.Buffer {
 	if p.PreloadHints != nil {
 		writePreloadHint(&p.buf, p.PreloadHints)
 	}
+	if p.PreloadMapHint != nil {
+		writePreloadHint(&p.buf, p.PreloadMapHint)
+	}
 
 	for _, dr := range p.TrailingDateRanges {
 		writeDateRange(&p.buf, dr, p.WritePrecision())
 	}
+	writeUnknownTags(&p.buf, p.TrailingUnknownTags)
 
 	if p.Closed {
 		p.buf.WriteString("#EXT-X-ENDLIST\n")
@@ -1256,7 +1694,12 @@ func (p *MediaPlaylist) TotalDuration() float64 {
 }
 
 // Close sliding playlist and by setting the EXT-X-ENDLIST tag and setting the Closed flag.
+// An EVENT playlist becomes a VOD playlist, since it can no longer change.
 func (p *MediaPlaylist) Close() {
+	if p.MediaType == EVENT {
+		p.MediaType = VOD
+		p.buf.Reset()
+	}
 	if p.buf.Len() > 0 {
 		p.buf.WriteString("#EXT-X-ENDLIST\n")
 	}
@@ -1272,22 +1715,10 @@ func (p *MediaPlaylist) CalculateTargetDuration(hlsVer uint8) uint {
 		return 0
 	}
 	var max float64
-	if p.tail >= p.head {
-		for i := p.head; i < p.tail; i++ {
-			if p.Segments[i].Duration > max {
-				max = p.Segments[i].Duration
-			}
-		}
-	} else {
-		for i := p.head; i < p.capacity; i++ {
-			if p.Segments[i].Duration > max {
-				max = p.Segments[i].Duration
-			}
-		}
-		for i := uint(0); i < p.tail; i++ {
-			if p.Segments[i].Duration > max {
-				max = p.Segments[i].Duration
-			}
+	// walk count segments from head, since head == tail both for an empty and a full ring buffer
+	for i := uint(0); i < p.count; i++ {
+		if seg := p.Segments[(p.head+i)%p.capacity]; seg != nil && seg.Duration > max {
+			max = seg.Duration
 		}
 	}
 	return calcNewTargetDuration(max, hlsVer, 0)
@@ -1328,7 +1759,8 @@ func (p *MediaPlaylist) SetDefaultKey(method, uri, iv, keyformat, keyformatversi
 	if keyformat != "" || keyformatversions != "" {
 		updateVersion(&p.ver, 5) // [Protocol Version Compatibility]
 	}
-	p.Keys = append(p.Keys, Key{method, uri, iv, keyformat, keyformatversions})
+	p.Keys = append(p.Keys, Key{Method: method, URI: uri, IV: iv, Keyformat: keyformat,
+		Keyformatversions: keyformatversions})
 	return nil
 }
 
@@ -1336,7 +1768,7 @@ func (p *MediaPlaylist) SetDefaultKey(method, uri, iv, keyformat, keyformatversi
 // at start of playlist. May be overridden by individual segments.
 func (p *MediaPlaylist) SetDefaultMap(uri string, limit, offset int64) {
 	updateVersion(&p.ver, 5) // [Protocol Version Compatibility]
-	p.Map = &Map{uri, limit, offset}
+	p.Map = &Map{URI: uri, Limit: limit, Offset: offset}
 }
 
 // SetIframeOnly marks medialist of only I-frames (Intra frames).
@@ -1355,7 +1787,8 @@ func (p *MediaPlaylist) SetKey(method, uri, iv, keyformat, keyformatversions str
 		updateVersion(&p.ver, 5) // [Protocol Version Compatibility]
 	}
 
-	p.Segments[p.last()].Keys = append(p.Segments[p.last()].Keys, Key{method, uri, iv, keyformat, keyformatversions})
+	p.Segments[p.last()].Keys = append(p.Segments[p.last()].Keys,
+		Key{Method: method, URI: uri, IV: iv, Keyformat: keyformat, Keyformatversions: keyformatversions})
 	return nil
 }
 
@@ -1365,7 +1798,7 @@ func (p *MediaPlaylist) SetMap(uri string, limit, offset int64) error {
 		return ErrPlaylistEmpty
 	}
 	updateVersion(&p.ver, 5) // [Protocol Version Compatibility]
-	p.Segments[p.last()].Map = &Map{uri, limit, offset}
+	p.Segments[p.last()].Map = &Map{URI: uri, Limit: limit, Offset: offset}
 	return nil
 }
 
@@ -1414,6 +1847,9 @@ func (p *MediaPlaylist) SetDiscontinuity() error {
 		return ErrPlaylistEmpty
 	}
 	p.Segments[p.last()].Discontinuity = true
+	if p.pdtClock != nil {
+		p.pdtClock.stampDiscontinuity(p.Segments[p.last()])
+	}
 	return nil
 }
 
@@ -1439,16 +1875,47 @@ func (p *MediaPlaylist) SetProgramDateTime(value time.Time) error {
 		return ErrPlaylistEmpty
 	}
 	p.Segments[p.last()].ProgramDateTime = value
+	if p.pdtClock != nil {
+		p.pdtClock.resync(p.Segments[p.last()])
+	}
 	return nil
 }
 
-// SetCustomTag sets the provided tag on the media playlist for its TagName.
+// SetCustomTag sets the provided tag on the media playlist for its TagName,
+// replacing tags with the same name.
 func (p *MediaPlaylist) SetCustomTag(tag CustomTag) {
 	if p.Custom == nil {
 		p.Custom = make(CustomMap)
 	}
 
 	p.Custom[tag.TagName()] = tag
+	p.customOrder.set(tag)
+	p.buf.Reset()
+}
+
+// AppendCustomTag appends the provided tag to the custom tags of the media playlist,
+// also if there are tags with the same name. Custom then holds the last of them.
+func (p *MediaPlaylist) AppendCustomTag(tag CustomTag) {
+	if p.Custom == nil {
+		p.Custom = make(CustomMap)
+	}
+	p.Custom[tag.TagName()] = tag
+	p.customOrder = append(p.customOrder, tag)
+	p.buf.Reset()
+}
+
+// CustomTags returns the custom tags of the media playlist in the order they are
+// written, which is the order they were set, appended or decoded in, including
+// repeated tags. Tags put directly into Custom replace those with the same name,
+// and tags only added to Custom come last, sorted by name.
+func (p *MediaPlaylist) CustomTags() CustomTags {
+	return orderedCustomTags(p.Custom, p.customOrder)
+}
+
+// SetCustomTagPlacement sets the position of the custom tags among the header tags.
+func (p *MediaPlaylist) SetCustomTagPlacement(placement CustomTagPlacement) {
+	p.customPlacement = placement
+	p.buf.Reset()
 }
 
 // SetSkipped sets the number of segments that have been skipped in the playlist.
@@ -1458,7 +1925,8 @@ func (p *MediaPlaylist) SetSkipped(skipped uint64) {
 	p.skippedSegments = skipped
 }
 
-// SetCustomSegmentTag sets the provided tag on the current media segment for its TagName.
+// SetCustomSegmentTag sets the provided tag on the current media segment for its TagName,
+// replacing tags with the same name.
 func (p *MediaPlaylist) SetCustomSegmentTag(tag CustomTag) error {
 	if p.count == 0 {
 		return ErrPlaylistEmpty
@@ -1471,31 +1939,83 @@ func (p *MediaPlaylist) SetCustomSegmentTag(tag CustomTag) error {
 	}
 
 	last.Custom[tag.TagName()] = tag
+	last.customOrder.set(tag)
+	p.buf.Reset()
 
 	return nil
 }
 
+// AppendCustomSegmentTag appends the provided tag to the custom tags of the current media
+// segment, also if there are tags with the same name. Custom then holds the last of them.
+func (p *MediaPlaylist) AppendCustomSegmentTag(tag CustomTag) error {
+	if p.count == 0 {
+		return ErrPlaylistEmpty
+	}
+	last := p.Segments[p.last()]
+	if last.Custom == nil {
+		last.Custom = make(CustomMap)
+	}
+	last.Custom[tag.TagName()] = tag
+	last.customOrder = append(last.customOrder, tag)
+	p.buf.Reset()
+	return nil
+}
+
+// CustomTags returns the custom tags of the segment in the order they are written,
+// see MediaPlaylist.CustomTags.
+func (seg *MediaSegment) CustomTags() CustomTags {
+	return orderedCustomTags(seg.Custom, seg.customOrder)
+}
+
 // WinSize returns the playlist's window size.
 func (p *MediaPlaylist) WinSize() uint {
 	return p.winsize
 }
 
 // SetWinSize overwrites the playlist's window size.
+// A window size > 0 replaces a window duration set by SetWindowDuration.
 func (p *MediaPlaylist) SetWinSize(winsize uint) error {
 	if winsize > p.capacity {
 		return fmt.Errorf("capacity=%d < winsize=%d: %w", p.capacity, winsize, ErrWinSizeTooSmall)
 	}
 	p.winsize = winsize
+	if winsize > 0 {
+		p.windowDuration = 0
+	}
 	return nil
 }
 
+// WindowDuration returns the playlist's window duration in seconds, 0 if not set.
+func (p *MediaPlaylist) WindowDuration() float64 {
+	return p.windowDuration
+}
+
+// SetWindowDuration makes the playlist a sliding playlist covering the latest segments
+// with a total duration of at least seconds, e.g. 7200 for a two hour DVR window,
+// instead of a fixed number of segments. The window covers at least three target
+// durations. Segments leaving the window are removed from the playlist when new
+// segments are appended, so capacity only needs to exceed the number of segments in
+// the window. It replaces the window size, and a value <= 0 turns the window off.
+func (p *MediaPlaylist) SetWindowDuration(seconds float64) {
+	p.windowDuration = max(seconds, 0)
+	if p.windowDuration > 0 {
+		p.winsize = 0
+	}
+	p.buf.Reset()
+}
+
 func (p *MediaPlaylist) SetServerControl(control *ServerControl) error {
 	if control.CanSkipUntil > 0 {
 		skipUntil := control.CanSkipUntil
 		skippedSegments := uint(math.Floor(skipUntil / float64(p.TargetDuration)))
 		if skippedSegments > 0 {
 			// if we want to skip the first N segments, we need to have at least N+1 winsize
-			if p.winsize <= skippedSegments {
+			if p.windowDuration > 0 {
+				if p.windowDuration <= skipUntil {
+					return fmt.Errorf("window duration=%g <= CAN-SKIP-UNTIL=%g: %w",
+						p.windowDuration, skipUntil, ErrWinSizeTooSmall)
+				}
+			} else if p.winsize <= skippedSegments {
 				return fmt.Errorf("winsize=%d <= skippedSegments=%d: %w",
 					p.winsize, skippedSegments, ErrWinSizeTooSmall)
 			}
@@ -1591,8 +2111,16 @@ func getSequenceNum(uriPrefix string) (num uint64, ok bool) {
 	return num, ok
 }
 
-// isPartOf checks if partialSegUri matches segUri after removing the file extension
+// isPartOf checks if partialSegUri matches segUri after removing the file extension.
+// Only the file names are compared, so directories, query strings and fragments,
+// e.g. added by RewriteURIs, do not matter.
 func isPartOf(partialSegUri, segUri string) bool {
+	partialSegUri = uriFileName(partialSegUri)
+	segUri = uriFileName(segUri)
+	// partial segments may be byte ranges of the segment file
+	if partialSegUri == segUri {
+		return partialSegUri != ""
+	}
 	// check if the extension is the same
 	if filepath.Ext(partialSegUri) != filepath.Ext(segUri) {
 		return false
@@ -1610,6 +2138,14 @@ func isPartOf(partialSegUri, segUri string) bool {
 	return parSegNumExist && segNumExist && parSegNum == segNum
 }
 
+// uriFileName returns the last path element of uri without query string and fragment.
+func uriFileName(uri string) string {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	return uri[strings.LastIndexByte(uri, '/')+1:]
+}
+
 func min(a, b uint) uint {
 	if a < b {
 		return a
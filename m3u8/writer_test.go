@@ -7,6 +7,7 @@ Playlist generation tests.
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
@@ -422,6 +423,80 @@ func TestEncodeMediaPlaylistWithCustomTags(t *testing.T) {
 	}
 }
 
+func TestEncodeCustomTagsInOrder(t *testing.T) {
+	is := is.New(t)
+	p, e := NewMediaPlaylist(0, 2)
+	is.NoErr(e)
+	var want []string
+	for i := 0; i < 20; i++ {
+		tag := fmt.Sprintf("#X-TAG-%02d", 19-i)
+		p.AppendCustomTag(&MockCustomTag{name: tag, encodedString: tag})
+		want = append(want, tag)
+	}
+	p.AppendCustomTag(&MockCustomTag{name: "#X-TAG-00", encodedString: "#X-TAG-00:again"})
+	want = append(want, "#X-TAG-00:again")
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+	is.NoErr(p.AppendCustomSegmentTag(&MockCustomTag{name: "#X-SEG", encodedString: "#X-SEG:1"}))
+	is.NoErr(p.AppendCustomSegmentTag(&MockCustomTag{name: "#X-SEG", encodedString: "#X-SEG:2"}))
+
+	out := p.String()
+	for i := 0; i < 10; i++ {
+		p.ResetCache()
+		is.Equal(p.String(), out) // same output for every encode
+	}
+	is.True(strings.Contains(out, strings.Join(want, "\n")+"\n"))                   // insertion order
+	is.True(strings.Contains(out, "#X-SEG:1\n#X-SEG:2\n#EXTINF:4.000,\nseg0.ts\n")) // both instances
+	is.Equal(len(p.Custom), 20)
+	is.Equal(len(p.CustomTags()), 21)
+	is.Equal(p.Custom["#X-TAG-00"].String(), "#X-TAG-00:again") // the map holds the last instance
+
+	p.SetCustomTag(&MockCustomTag{name: "#X-TAG-00", encodedString: "#X-TAG-00:set"})
+	tags := p.CustomTags()
+	is.Equal(len(tags), 20) // replaces both instances at the position of the first
+	is.Equal(tags[19].String(), "#X-TAG-00:set")
+
+	p.Custom["#X-TAG-19"] = &MockCustomTag{name: "#X-TAG-19", encodedString: "#X-TAG-19:direct"}
+	p.ResetCache()
+	is.True(strings.Contains(p.String(), "#EXT-X-VERSION:3\n#X-TAG-19:direct\n#X-TAG-18\n"))
+
+	pl, _, e := DecodeWith(bytes.NewBufferString(out), true,
+		[]CustomDecoder{&MockCustomTag{name: "#X-SEG", segment: true, encodedString: "#X-SEG"}})
+	is.NoErr(e)
+	is.Equal(len(pl.(*MediaPlaylist).Segments[0].CustomTags()), 2) // both instances decoded
+
+	d, e := NewMediaPlaylist(0, 2)
+	is.NoErr(e)
+	d.WithCustomDecoders([]CustomDecoder{&MockCustomTag{name: "#X-SEG", segment: true, encodedString: "#X-SEG"}})
+	is.NoErr(d.DecodeFrom(bytes.NewBufferString(out), true)) // no decode state from DecodeWith
+	is.Equal(len(d.Segments[0].CustomTags()), 2)
+}
+
+func TestEncodeCustomTagPlacement(t *testing.T) {
+	is := is.New(t)
+	tag := &MockCustomTag{name: "#X-TAG", encodedString: "#X-TAG"}
+	p, e := NewMediaPlaylist(0, 2)
+	is.NoErr(e)
+	p.SetIndependentSegments(true)
+	p.SetDefaultMap("init.mp4", 0, 0)
+	p.AppendCustomTag(tag)
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+
+	is.True(strings.Contains(p.String(), "#EXT-X-INDEPENDENT-SEGMENTS\n#X-TAG\n")) // default
+	p.SetCustomTagPlacement(CustomTagsAfterVersion)
+	is.True(strings.Contains(p.String(), "#EXT-X-VERSION:5\n#X-TAG\n#EXT-X-INDEPENDENT-SEGMENTS\n"))
+	p.SetCustomTagPlacement(CustomTagsEndOfHeader)
+	is.True(strings.Contains(p.String(), "#EXT-X-MAP:URI=\"init.mp4\"\n#X-TAG\n#EXTINF:"))
+
+	m := NewMasterPlaylist()
+	m.AppendCustomTag(tag)
+	m.Append("low.m3u8", nil, VariantParams{Bandwidth: 100000, Alternatives: []*Alternative{
+		{GroupId: "aud", Type: "AUDIO", Name: "English", URI: "audio.m3u8"}}, Audio: "aud"})
+	m.SetCustomTagPlacement(CustomTagsEndOfHeader)
+	out := m.String()
+	is.True(strings.Index(out, "#X-TAG") > strings.Index(out, "#EXT-X-MEDIA:"))
+	is.True(strings.Index(out, "#X-TAG") < strings.Index(out, "#EXT-X-STREAM-INF:"))
+}
+
 // Create new media playlist
 // Add two segments to media playlist
 // Encode structures to HLS
@@ -612,7 +687,7 @@ func TestEncodeLowLatencyMediaPlaylist(t *testing.T) {
 	p.SetPreloadHint("PART", "test05.2.m4s")
 
 	partTargetDuration := p.PartTargetDuration
-	serverControl := ServerControl{0.0, false, 0.0, partTargetDuration * 3, true}
+	serverControl := ServerControl{PartHoldBack: partTargetDuration * 3, CanBlockReload: true}
 	e = p.SetServerControl(&serverControl)
 	is.NoErr(e) // Set server control should be successful
 
@@ -690,6 +765,88 @@ func TestEncodeDecodedLowLatencyMediaPlaylistIdempotent(t *testing.T) {
 // Partial segments must be dropped as the end of the playlist moves forward, also when
 // it moves by full segments only. Otherwise they linger for a stream that stops adding
 // partial segments, and IsSegmentReady keeps reporting them as available.
+func TestEncodePreloadHints(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 3)
+	is.NoErr(err)
+	p.PartTargetDuration = 1
+	is.NoErr(p.Append("seg0.m4s", 4, ""))
+
+	p.SetPreloadHint(PreloadHintPart, "seg1.0.m4s")
+	is.NoErr(p.SetPreloadHintByteRange(PreloadHintPart, "seg1.m4s", 0, 0)) // replaces the PART hint
+	is.NoErr(p.SetPreloadHintByteRange(PreloadHintMap, "init.mp4", 100, 500))
+	is.True(errors.Is(p.SetPreloadHintByteRange("SEGMENT", "x.m4s", 0, 0), ErrInvalidPreloadHint))       // unknown type
+	is.True(errors.Is(p.SetPreloadHintByteRange(PreloadHintMap, "x.mp4", -1, 0), ErrInvalidPreloadHint)) // bad range
+	is.Equal(p.PreloadHints.URI, "seg1.m4s")
+	is.Equal(p.PreloadMapHint.URI, "init.mp4")
+
+	out := p.String()
+	is.True(strings.HasSuffix(out, "seg0.m4s\n"+
+		`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="seg1.m4s"`+"\n"+
+		`#EXT-X-PRELOAD-HINT:TYPE=MAP,URI="init.mp4",BYTERANGE-START=100,BYTERANGE-LENGTH=500`+"\n"))
+
+	is.NoErr(p.SetPreloadHintByteRange(PreloadHintPart, "seg1.m4s", 1000, 0))
+	is.True(strings.Contains(p.String(), `TYPE=PART,URI="seg1.m4s",BYTERANGE-START=1000`+"\n")) // open-ended range
+
+	// appending another part of the same file keeps the hint
+	is.NoErr(p.AppendPartialSegment(&PartialSegment{URI: "seg1.m4s", Duration: 1, Limit: 1000}))
+	is.True(p.GetPreloadHint(PreloadHintPart) != nil)
+	// appending the hinted part clears the hint
+	is.NoErr(p.AppendPartialSegment(&PartialSegment{URI: "seg1.m4s", Duration: 1, Offset: 1000, Limit: 800}))
+	is.True(p.GetPreloadHint(PreloadHintPart) == nil)
+	is.True(p.GetPreloadHint(PreloadHintMap) != nil) // MAP hint is kept
+	is.True(!strings.Contains(p.String(), "TYPE=PART"))
+
+	p.RemovePreloadHint(PreloadHintMap)
+	is.True(p.PreloadMapHint == nil)
+}
+
+// Single-file low-latency playlist with partial segments as byte ranges of the segment files
+func TestEncodeByteRangePartialSegments(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 5)
+	is.NoErr(err)
+	p.PartTargetDuration = 1
+	is.NoErr(p.Append("seg0.m4s", 2, ""))
+	err = p.AppendByteRangeSegment("seg1.m4s", "")
+	is.True(errors.Is(err, ErrNoPartialSegments)) // no parts appended yet
+	err = p.AppendPartialByteRange("seg1.m4s", 1, 0, true)
+	is.True(errors.Is(err, ErrInvalidByteRange)) // empty part
+
+	is.NoErr(p.AppendPartialByteRange("seg1.m4s", 1, 1000, true))
+	is.NoErr(p.AppendPartialByteRange("seg1.m4s", 1, 800, false))
+	is.Equal(*p.GetPreloadHint(PreloadHintPart), PreloadHint{Type: PreloadHintPart, URI: "seg1.m4s", Offset: 1800})
+	is.True(strings.HasSuffix(p.String(), "seg0.m4s\n"+
+		`#EXT-X-PART:DURATION=1.000,INDEPENDENT=YES,BYTERANGE="1000@0",URI="seg1.m4s"`+"\n"+
+		`#EXT-X-PART:DURATION=1.000,BYTERANGE="800@1000",URI="seg1.m4s"`+"\n"+
+		`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="seg1.m4s",BYTERANGE-START=1800`+"\n"))
+
+	is.NoErr(p.AppendByteRangeSegment("seg1.m4s", ""))
+	is.True(p.GetPreloadHint(PreloadHintPart) == nil) // hint of the completed file removed
+	seg := p.Segments[p.last()]
+	is.Equal(seg.Offset, int64(0))
+	is.Equal(seg.Limit, int64(1800))
+	is.Equal(seg.Duration, 2.0)
+
+	is.NoErr(p.AppendPartialByteRange("seg2.m4s", 1, 500, true)) // next file starts at 0
+	is.Equal(p.PartialSegments[2].Offset, int64(0))
+	is.Equal(p.PartialSegments[2].SeqID, uint64(2))
+
+	out := p.String()
+	is.True(strings.HasSuffix(out, "seg0.m4s\n"+
+		`#EXT-X-PART:DURATION=1.000,INDEPENDENT=YES,BYTERANGE="1000@0",URI="seg1.m4s"`+"\n"+
+		`#EXT-X-PART:DURATION=1.000,BYTERANGE="800@1000",URI="seg1.m4s"`+"\n"+
+		"#EXT-X-BYTERANGE:1800@0\n#EXTINF:2.000,\nseg1.m4s\n"+
+		`#EXT-X-PART:DURATION=1.000,INDEPENDENT=YES,BYTERANGE="500@0",URI="seg2.m4s"`+"\n"+
+		`#EXT-X-PRELOAD-HINT:TYPE=PART,URI="seg2.m4s",BYTERANGE-START=500`+"\n"))
+
+	// the parts must be matched to their segments when decoding
+	d, err := NewMediaPlaylist(0, 5)
+	is.NoErr(err)
+	is.NoErr(d.DecodeFrom(bytes.NewBufferString(out), true))
+	is.Equal(d.String(), out)
+}
+
 func TestRemoveExpiredPartialsOnAppendSegment(t *testing.T) {
 	is := is.New(t)
 	p, e := NewMediaPlaylist(3, 5)
@@ -753,7 +910,7 @@ func TestEncodePartialSegments(t *testing.T) {
 	}
 
 	partTargetDuration := p.PartTargetDuration
-	serverControl := ServerControl{0.0, false, 0.0, partTargetDuration * 3, true}
+	serverControl := ServerControl{PartHoldBack: partTargetDuration * 3, CanBlockReload: true}
 	e = p.SetServerControl(&serverControl)
 	is.NoErr(e) // Set server control should be successful
 
@@ -1038,7 +1195,7 @@ func TestEncodeMediaPlaylistWithSkipUntil(t *testing.T) {
 	skipped := uint64(6)
 	canSkipUntil := float64(4.0 * skipped) // skip 6 segment
 	holdBack := 4.0 * 3                    // hold back 3 segment
-	serverControl := ServerControl{canSkipUntil, false, holdBack, 0.0, true}
+	serverControl := ServerControl{CanSkipUntil: canSkipUntil, HoldBack: holdBack, CanBlockReload: true}
 	e = p.SetServerControl(&serverControl)
 	is.NoErr(e) // Set server control should be successful
 
@@ -1048,7 +1205,7 @@ func TestEncodeMediaPlaylistWithSkipUntil(t *testing.T) {
 		skipped := uint64(16)
 		canSkipUntil := float64(4.0 * skipped)
 		holdBack := 4.0 * 3
-		serverControl := ServerControl{canSkipUntil, false, holdBack, 0.0, true}
+		serverControl := ServerControl{CanSkipUntil: canSkipUntil, HoldBack: holdBack, CanBlockReload: true}
 		e = p.SetServerControl(&serverControl)
 		is.True(e != nil) // Set server control should fail
 	}
@@ -1189,6 +1346,238 @@ func TestMediaPlaylistWinsize(t *testing.T) {
 	is.Equal(p.SeqNo, uint64(3)) // SeqNo of media playlist does not match expected 3
 }
 
+// Slide a live playlist through a discontinuity, a map change and a key change,
+// wrapping around the ring buffer several times
+func TestMediaPlaylistSlideCarriesState(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(3, 3)
+	p.SetDefaultMap("init1.mp4", 0, 0)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	adDuration := 30.0
+	p.DateRanges = []*DateRange{
+		{ID: "ad1", StartDate: start, EndDate: ptrTime(start.Add(8 * time.Second))},
+		{ID: "ad2", StartDate: start.Add(20 * time.Second), Duration: &adDuration},
+		{ID: "open", StartDate: start},
+	}
+	for i := 0; i < 10; i++ {
+		p.Slide(fmt.Sprintf("seg%d.m4s", i), 4, "")
+		switch i {
+		case 0:
+			is.NoErr(p.SetProgramDateTime(start))
+		case 2:
+			is.NoErr(p.SetDiscontinuity())
+			is.NoErr(p.SetMap("init2.mp4", 0, 0))
+		case 3:
+			is.NoErr(p.SetKey("AES-128", "key1", "", "", ""))
+		case 5:
+			is.NoErr(p.SetProgramDateTime(start.Add(20 * time.Second)))
+		}
+		if i == 4 {
+			// window is seg2..seg4, so the discontinuity is still in it
+			is.Equal(p.DiscontinuitySeq, uint64(0))
+			is.True(strings.Contains(p.String(), "#EXT-X-DISCONTINUITY\n")) // discontinuity in window
+		}
+	}
+	// window is seg7..seg9
+	is.Equal(p.SeqNo, uint64(7))
+	is.Equal(p.DiscontinuitySeq, uint64(1)) // departed discontinuity counted
+	is.Equal(p.Map.URI, "init2.mp4")        // map of removed segment carried forward
+	is.Equal(p.Keys[0].URI, "key1")         // keys of removed segment carried forward
+	is.Equal(len(p.DateRanges), 2)          // ad1 ended before the window
+	is.Equal(p.DateRanges[0].ID, "ad2")
+
+	out := p.String()
+	is.True(strings.Contains(out, "#EXT-X-DISCONTINUITY-SEQUENCE:1\n"))
+	is.True(strings.Contains(out, `#EXT-X-MAP:URI="init2.mp4"`))
+	is.Equal(strings.Count(out, "#EXT-X-MAP:"), 1)
+	is.Equal(strings.Count(out, "#EXT-X-KEY:"), 1)
+	is.True(!strings.Contains(out, "#EXT-X-DISCONTINUITY\n"))
+}
+
+// Keys declared by the new first segment replace the playlist keys instead of those
+// of the removed segment
+func TestMediaPlaylistRemoveKeepsOwnKeys(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 3)
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+	is.NoErr(p.SetKey("AES-128", "key0", "", "", ""))
+	is.NoErr(p.SetMap("init0.mp4", 0, 0))
+	is.NoErr(p.Append("seg1.ts", 4, ""))
+	is.NoErr(p.SetKey("AES-128", "key1", "", "", ""))
+	is.NoErr(p.Remove())
+	is.Equal(p.Keys[0].URI, "key1")      // own key of the first segment
+	is.Equal(p.Map.URI, "init0.mp4")     // map of removed segment carried forward
+	is.Equal(p.Segments[1].Map, p.Map)   // and attached to the first segment
+	is.Equal(p.Segments[1].Keys, p.Keys) // first segment keys unchanged
+	out := p.String()
+	is.Equal(strings.Count(out, "#EXT-X-KEY:"), 1)
+	is.True(!strings.Contains(out, "key0"))
+}
+
+// Removing from a closed playlist updates SeqNo and DiscontinuitySeq alike
+func TestClosedMediaPlaylistRemove(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 3)
+	is.NoErr(p.Append("seg0.ts", 4, ""))
+	is.NoErr(p.SetDiscontinuity())
+	is.NoErr(p.Append("seg1.ts", 4, ""))
+	p.Close()
+	is.NoErr(p.Remove())
+	is.Equal(p.SeqNo, uint64(1))
+	is.Equal(p.DiscontinuitySeq, uint64(1))
+	is.True(strings.Contains(p.String(), "#EXT-X-MEDIA-SEQUENCE:1\n"))
+}
+
+// State declared by segments before the output window, but still in the ring buffer,
+// must be written for the window
+func TestMediaPlaylistWindowState(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(2, 4)
+	for i := 0; i < 6; i++ {
+		if p.Count() == 4 {
+			is.NoErr(p.Remove())
+		}
+		is.NoErr(p.Append(fmt.Sprintf("seg%d.ts", i), 4, ""))
+		if i == 3 {
+			is.NoErr(p.SetDiscontinuity())
+			is.NoErr(p.SetKey("AES-128", "key1", "", "", ""))
+		}
+	}
+	// buffer holds seg2..seg5 from index 2, wrapped, and the window is seg4..seg5
+	is.Equal(p.DiscontinuitySeq, uint64(0))
+	out := p.String()
+	is.True(strings.Contains(out, "#EXT-X-MEDIA-SEQUENCE:4\n"))
+	is.True(strings.Contains(out, "#EXT-X-DISCONTINUITY-SEQUENCE:1\n"))
+	is.Equal(strings.Count(out, "#EXT-X-KEY:"), 1)
+	is.True(strings.Index(out, "#EXT-X-KEY:") < strings.Index(out, "seg4.ts"))
+}
+
+// Create new media playlist with a window duration and append more segments than its capacity
+func TestMediaPlaylistWindowDuration(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 6)
+	p.SetWindowDuration(20)
+	is.Equal(p.WindowDuration(), 20.0)
+	for i := 0; i < 100; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("test%d.ts", i), 4, "")) // old segments must be removed
+	}
+	is.Equal(p.Count(), uint(5)) // 5 segments of 4s cover the window
+	is.Equal(p.SeqNo, uint64(95))
+
+	// a long segment raises the target duration, and the window must cover 3 of them
+	is.NoErr(p.Append("ad.ts", 10, ""))
+	is.Equal(p.Count(), uint(4)) // 3*4s + 10s still covers 20s
+	is.NoErr(p.Append("test100.ts", 4, ""))
+	is.NoErr(p.Append("test101.ts", 4, ""))
+	is.Equal(p.Count(), uint(6)) // 30s needed with a target duration of 10
+	out := p.String()
+	is.True(strings.Contains(out, "#EXT-X-MEDIA-SEQUENCE:97\n"))
+	is.Equal(strings.Count(out, "#EXTINF:"), 6)
+
+	// Slide must not remove more than the window allows
+	p.Slide("test102.ts", 4, "")
+	is.Equal(p.Count(), uint(6))
+
+	is.NoErr(p.SetWinSize(3))
+	is.Equal(p.WindowDuration(), 0.0) // window size replaces window duration
+	is.Equal(strings.Count(p.String(), "#EXTINF:"), 3)
+}
+
+// A failing append with a window duration must not remove any segment
+func TestMediaPlaylistWindowDurationAppendFails(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 3)
+	p.SetWindowDuration(20)
+	for i := 0; i < 3; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("test%d.ts", i), 4, ""))
+	}
+	want := p.String()
+	err := p.Append("test3.ts", 4, "")
+	is.Equal(err, ErrPlaylistFull) // the window needs more segments than the capacity
+	is.Equal(p.Count(), uint(3))
+	is.Equal(p.SeqNo, uint64(0))
+	is.Equal(p.String(), want)
+
+	errNoKey := errors.New("no key")
+	p.SetKeyRotationPolicy(KeyRotationPolicy{Keys: func(_, _ uint64) ([]Key, error) { return nil, errNoKey }})
+	p.SetWindowDuration(4)
+	err = p.Append("test3.ts", 4, "")
+	is.True(errors.Is(err, errNoKey))
+	is.Equal(p.Count(), uint(3)) // nothing removed for the failed append
+	is.Equal(p.SeqNo, uint64(0))
+}
+
+// Slide grows the capacity when the window duration needs more segments
+func TestMediaPlaylistWindowDurationSlideGrows(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 2)
+	p.SetWindowDuration(20)
+	for i := 0; i < 10; i++ {
+		p.Slide(fmt.Sprintf("test%d.ts", i), 4, "")
+	}
+	is.Equal(p.Count(), uint(5)) // 5 segments of 4s cover the window
+	is.Equal(p.SeqNo, uint64(5))
+	is.True(strings.Contains(p.String(), "test9.ts"))
+}
+
+// Only the latest segments covering the window duration are encoded, when more are in the playlist
+func TestMediaPlaylistWindowDurationOutput(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 10)
+	for i := 0; i < 8; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("test%d.ts", i), float64(2+i%2), ""))
+	}
+	p.SetWindowDuration(10)
+	out := p.String()
+	is.True(strings.Contains(out, "#EXT-X-MEDIA-SEQUENCE:4\n")) // 2+3+2+3 seconds
+	is.Equal(strings.Count(out, "#EXTINF:"), 4)
+	is.Equal(p.Count(), uint(8)) // nothing removed before the next append
+}
+
+// Create growable EVENT playlist without capacity, append segments and close it
+func TestMediaPlaylistGrowableEvent(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 0)
+	err := p.Append("test0.ts", 4, "")
+	is.Equal(err, ErrPlaylistFull) // playlist without capacity is full
+	p.SetGrowable(true)
+	is.True(p.Growable())
+	p.MediaType = EVENT
+	for i := 0; i < 100; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("test%d.ts", i), 4, "")) // Append to growable playlist
+	}
+	is.Equal(p.Count(), uint(100))
+	is.True(strings.Contains(p.String(), "#EXT-X-PLAYLIST-TYPE:EVENT\n"))
+
+	p.Close()
+	is.Equal(p.MediaType, VOD) // closed EVENT playlist becomes VOD
+	out := p.String()
+	is.True(strings.Contains(out, "#EXT-X-PLAYLIST-TYPE:VOD\n"))
+	is.True(strings.HasSuffix(out, "test99.ts\n#EXT-X-ENDLIST\n"))
+	is.Equal(strings.Count(out, "#EXT-X-ENDLIST"), 1)
+}
+
+// Growing a ring buffer that has wrapped around must keep the segment order
+func TestMediaPlaylistGrowWrapped(t *testing.T) {
+	is := is.New(t)
+	p, _ := NewMediaPlaylist(0, 4)
+	p.SetGrowable(true)
+	for i := 0; i < 4; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("test%d.ts", i), 4, ""))
+	}
+	is.NoErr(p.Remove())
+	is.NoErr(p.Remove())
+	for i := 4; i < 7; i++ {
+		is.NoErr(p.Append(fmt.Sprintf("test%d.ts", i), 4, ""))
+	}
+	segs := p.GetAllSegments()
+	is.Equal(len(segs), 5)
+	for i, seg := range segs {
+		is.Equal(seg.URI, fmt.Sprintf("test%d.ts", i+2)) // segments in order after growth
+		is.Equal(seg.SeqId, uint64(i+2))
+	}
+}
+
 // Create new media playlist as sliding playlist.
 // Close it.
 func TestClosedMediaPlaylist(t *testing.T) {
@@ -1560,6 +1949,16 @@ func TestCalculateTargetDuration(t *testing.T) {
 	}
 }
 
+// TestCalculateTargetDurationFullPlaylist checks a full ring buffer, where head == tail.
+func TestCalculateTargetDurationFullPlaylist(t *testing.T) {
+	is := is.New(t)
+	p, err := NewMediaPlaylist(0, 2)
+	is.NoErr(err) // Create media playlist should be successful
+	is.NoErr(p.Append("a.ts", 4.2, ""))
+	is.NoErr(p.Append("b.ts", 6.2, ""))
+	is.Equal(p.CalculateTargetDuration(5), uint(7)) // all segments of a full playlist must be considered
+}
+
 // TestSetTargetDurationMinimum verifies that SetTargetDuration enforces the
 // rfc8216bis rule that the EXT-X-TARGETDURATION value MUST be at least 1.
 func TestSetTargetDurationMinimum(t *testing.T) {
@@ -1608,6 +2007,8 @@ func TestIsPartOf(t *testing.T) {
 		{"filePart249.2.m4s", "fileSequence249.m4s", true},
 		{"chunk249.1.m4s", "fileSequence249.m4s", true},
 		{"filePart0249.1.m4s", "fileSequence249.m4s", true},
+		{"https://cdn.example.com/a/filePart249.1.m4s?t=1", "https://cdn.example.com/a/fileSequence249.m4s?t=2", true},
+		{"fileSequence249.m4s", "fileSequence249.m4s", true}, // byte range of the segment file
 
 		{"filePart249.1.m4s", "fileSequence2490.m4s", false},
 		{"filePart2490.1.m4s", "fileSequence249.m4s", false},